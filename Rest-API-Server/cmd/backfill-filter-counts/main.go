@@ -0,0 +1,82 @@
+// Command backfill-filter-counts sets the per filter value counts of active
+// listings behind GET /facets from the stored filters. Counters can only be changed
+// by a difference, so each one is moved by what it is off by; run it while
+// no listings are being changed, or counts for the values they touch may end
+// up off by those changes. Running it again is safe.
+package main
+
+import (
+	"github.com/gocql/gocql"
+	"log"
+	"marketplace_project/internal/db"
+	"marketplace_project/internal/models"
+)
+
+type filterValue struct {
+	categoryID    gocql.UUID
+	subcategoryID gocql.UUID
+	name          string
+	value         string
+}
+
+func main() {
+	session := db.Connection()
+	defer session.Close()
+
+	unlisted := make(map[gocql.UUID]bool)
+	iter := session.Query("SELECT product_id, status FROM marketplace_keyspace.product_by_id").PageSize(500).Iter()
+	var productID gocql.UUID
+	var status string
+	for iter.Scan(&productID, &status) {
+		if !models.ListingStatusOf(status).Listed() {
+			unlisted[productID] = true
+		}
+	}
+	if err := iter.Close(); err != nil {
+		log.Fatalf("Failed to read products: %v", err)
+	}
+
+	want := make(map[filterValue]int64)
+	iter = session.Query("SELECT category_id, sub_category_id, filter_name, filter_value, product_id FROM marketplace_keyspace.product_filters").PageSize(500).Iter()
+	var key filterValue
+	for iter.Scan(&key.categoryID, &key.subcategoryID, &key.name, &key.value, &productID) {
+		if !unlisted[productID] {
+			want[key]++
+		}
+	}
+	if err := iter.Close(); err != nil {
+		log.Fatalf("Failed to read filters: %v", err)
+	}
+
+	have := make(map[filterValue]int64)
+	iter = session.Query("SELECT category_id, sub_category_id, filter_name, filter_value, listings FROM marketplace_keyspace.product_filter_counts").PageSize(500).Iter()
+	var listings int64
+	for iter.Scan(&key.categoryID, &key.subcategoryID, &key.name, &key.value, &listings) {
+		have[key] = listings
+	}
+	if err := iter.Close(); err != nil {
+		log.Fatalf("Failed to read filter counts: %v", err)
+	}
+	for key := range have {
+		if _, ok := want[key]; !ok {
+			want[key] = 0
+		}
+	}
+
+	var corrected, failed int
+	query := "UPDATE marketplace_keyspace.product_filter_counts SET listings = listings + ? WHERE category_id = ? AND sub_category_id = ? AND filter_name = ? AND filter_value = ?"
+	for key, count := range want {
+		delta := count - have[key]
+		if delta == 0 {
+			continue
+		}
+		if err := session.Query(query, delta, key.categoryID, key.subcategoryID, key.name, key.value).Exec(); err != nil {
+			failed++
+			log.Printf("Failed to count %s=%s in %s/%s: %v", key.name, key.value, key.categoryID, key.subcategoryID, err)
+			continue
+		}
+		corrected++
+	}
+
+	log.Printf("Backfill finished: %d counts corrected, %d failed", corrected, failed)
+}
@@ -0,0 +1,69 @@
+// Command backfill-listing-expiry gives active listings published before
+// expiry was introduced a full period of their owner's plan, starting now,
+// and schedules their expiry. Listings that already expire are left alone,
+// so running it again is safe.
+package main
+
+import (
+	"context"
+	"github.com/gocql/gocql"
+	"log"
+	"marketplace_project/config"
+	"marketplace_project/internal/db"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/payment"
+	"marketplace_project/internal/repository"
+	"marketplace_project/internal/service"
+	"time"
+)
+
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	session := db.Connection()
+	defer session.Close()
+
+	provider, err := payment.New(cfg.Payment)
+	if err != nil {
+		log.Fatalf("Failed to configure payment provider: %v", err)
+	}
+	subscriptions := service.NewSubscriptionService(repository.NewSubscriptionRepository(session), provider, cfg.Payment)
+	expiry := service.NewListingExpiry(repository.NewExpiryRepository(session), subscriptions, cfg.Expiry)
+	products := repository.NewProductRepository(session)
+
+	var pending []gocql.UUID
+	iter := session.Query("SELECT product_id, status, expires_at FROM marketplace_keyspace.product_by_id").PageSize(500).Iter()
+	var productID gocql.UUID
+	var status string
+	var expiresAt *time.Time
+	for iter.Scan(&productID, &status, &expiresAt) {
+		if models.ListingStatusOf(status).Listed() && expiresAt == nil {
+			pending = append(pending, productID)
+		}
+	}
+	if err := iter.Close(); err != nil {
+		log.Fatalf("Failed to read products: %v", err)
+	}
+
+	ctx := context.Background()
+	var scheduled, failed int
+	for _, productID := range pending {
+		product, _, err := products.ProductInfoByID(ctx, productID)
+		if err == nil {
+			err = expiry.Start(ctx, product)
+		}
+		if err == nil {
+			err = products.SetStatus(ctx, product, models.StatusActive)
+		}
+		if err != nil {
+			failed++
+			log.Printf("Failed to schedule expiry of product %s: %v", productID, err)
+			continue
+		}
+		scheduled++
+	}
+
+	log.Printf("Backfill finished: %d listings scheduled to expire, %d failed", scheduled, failed)
+}
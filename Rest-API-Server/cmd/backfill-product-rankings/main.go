@@ -0,0 +1,38 @@
+// Command backfill-product-rankings ranks the listings that were created
+// before sorted listing was introduced. It is safe to run more than once:
+// every listing's ranking rows are rebuilt from its current data.
+package main
+
+import (
+	"context"
+	"github.com/gocql/gocql"
+	"log"
+	"marketplace_project/internal/db"
+	"marketplace_project/internal/repository"
+)
+
+func main() {
+	session := db.Connection()
+	defer session.Close()
+
+	products := repository.NewProductRepository(session)
+	ctx := context.Background()
+
+	iter := session.Query("SELECT product_id FROM marketplace_keyspace.product_by_id").PageSize(500).Iter()
+
+	var productID gocql.UUID
+	var ranked, failed int
+	for iter.Scan(&productID) {
+		if err := products.RebuildRankings(ctx, productID); err != nil {
+			failed++
+			log.Printf("Failed to rank product %s: %v", productID, err)
+			continue
+		}
+		ranked++
+	}
+	if err := iter.Close(); err != nil {
+		log.Fatalf("Failed to read products: %v", err)
+	}
+
+	log.Printf("Backfill finished: %d ranked, %d failed", ranked, failed)
+}
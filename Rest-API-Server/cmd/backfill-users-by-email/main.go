@@ -0,0 +1,50 @@
+// Command backfill-users-by-email fills the users_by_email lookup table from
+// the existing userdata rows. It is safe to run more than once: addresses that
+// are already claimed are left alone, and addresses claimed by two accounts
+// are reported so they can be resolved by hand.
+package main
+
+import (
+	"github.com/gocql/gocql"
+	"log"
+	"marketplace_project/internal/db"
+	"strings"
+)
+
+func main() {
+	session := db.Connection()
+	defer session.Close()
+
+	iter := session.Query("SELECT id, email FROM marketplace_keyspace.userdata").PageSize(500).Iter()
+
+	var userID gocql.UUID
+	var email string
+	var claimed, skipped, conflicts int
+	for iter.Scan(&userID, &email) {
+		key := strings.ToLower(strings.TrimSpace(email))
+		if key == "" {
+			skipped++
+			continue
+		}
+
+		existing := map[string]interface{}{}
+		applied, err := session.Query("INSERT INTO marketplace_keyspace.users_by_email(email, user_id) VALUES (?, ?) IF NOT EXISTS", key, userID).MapScanCAS(existing)
+		if err != nil {
+			log.Fatalf("Failed to claim %s for user %s: %v", key, userID, err)
+		}
+		switch {
+		case applied:
+			claimed++
+		case existing["user_id"] == userID:
+			skipped++
+		default:
+			conflicts++
+			log.Printf("Email %s of user %s is already claimed by user %v", key, userID, existing["user_id"])
+		}
+	}
+	if err := iter.Close(); err != nil {
+		log.Fatalf("Failed to read users: %v", err)
+	}
+
+	log.Printf("Backfill finished: %d claimed, %d skipped, %d conflicts", claimed, skipped, conflicts)
+}
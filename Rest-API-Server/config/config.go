@@ -1,5 +1,477 @@
 package config
 
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	Server     ServerConfig
+	JWT        JWTConfig
+	Mail       MailConfig
+	TwoFactor  TwoFactorConfig
+	OIDC       OIDCConfig
+	LoginGuard LoginGuardConfig
+	Payment    PaymentConfig
+	Stats      StatsConfig
+	Facets     FacetsConfig
+	Images     ImagesConfig
+	Expiry     ExpiryConfig
+}
+
 type ServerConfig struct {
 	Port string
+	// AppURL is the address of the web client, used to build links in emails.
+	AppURL string
+	// ChatURL is the address of the chat server, asked whether a buyer has
+	// contacted a seller before a review is accepted.
+	ChatURL string
+	// InternalSecret is shared with the chat server and authenticates the
+	// calls the two servers make to each other. Internal routes refuse every
+	// request while it is empty.
+	InternalSecret string
+}
+
+// JWTConfig describes how access and refresh tokens are signed and verified.
+// Algorithm is the algorithm of the active key and the default for previous
+// keys that do not name their own.
+type JWTConfig struct {
+	Algorithm    string
+	ActiveKey    KeyConfig
+	PreviousKeys []KeyConfig
+	// RotatedAt is when the active key replaced the previous ones. They are
+	// accepted until RotationWindow after it, however often the server is
+	// restarted in between.
+	RotatedAt       time.Time
+	RotationWindow  time.Duration
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+}
+
+// KeyConfig is one signing key. Algorithm is one of HS256, RS256 or EdDSA.
+// For HS256 Material is the shared secret; for RS256 and EdDSA it is the path
+// to a PEM file holding the private key, or only the public key for keys that
+// are kept for verification.
+type KeyConfig struct {
+	ID        string
+	Algorithm string
+	Material  string
+}
+
+// MailConfig selects how outgoing mail is delivered. Transport is one of smtp,
+// file or memory; file writes every message into Dir for local development.
+type MailConfig struct {
+	Transport           string
+	From                string
+	SMTPHost            string
+	SMTPPort            string
+	SMTPUsername        string
+	SMTPPassword        string
+	Dir                 string
+	VerificationLinkTTL time.Duration
+	ResetLinkTTL        time.Duration
+}
+
+// TwoFactorConfig configures TOTP sign-in. Issuer is the account name shown in
+// authenticator apps; ChallengeTTL is how long the second step may take.
+type TwoFactorConfig struct {
+	Issuer       string
+	ChallengeTTL time.Duration
+}
+
+// OIDCConfig lists the external identity providers users can sign in with,
+// keyed by the name used in /oauth/:provider routes.
+type OIDCConfig struct {
+	Providers map[string]OIDCProviderConfig
+	// StateTTL is how long a started sign-in may take to come back.
+	StateTTL time.Duration
+}
+
+type OIDCProviderConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the page of the web client that receives the
+	// authorization code and posts it to the API.
+	RedirectURL string
+	Scopes      []string
+}
+
+// LoginGuardConfig configures brute-force protection of sign-in and
+// registration. Store is memory for a single instance or cassandra when
+// several API instances must share the counters.
+type LoginGuardConfig struct {
+	Store            string
+	AccountThreshold int
+	IPThreshold      int
+	// Window is how long failed attempts are remembered.
+	Window          time.Duration
+	LockoutDuration time.Duration
+	// BaseDelay is the wait enforced after the first failure; it doubles with
+	// every further failure up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// PaymentConfig selects the payment provider and the billing terms of
+// subscriptions. Provider is fake for local development.
+type PaymentConfig struct {
+	Provider string
+	// FakeDeclineAbove makes the fake provider decline charges above this
+	// many cents, to try out failed renewals; zero accepts everything.
+	FakeDeclineAbove int
+	// Period is the length of one paid subscription period.
+	Period time.Duration
+	// GracePeriod is how long a plan stays usable after a renewal failed.
+	GracePeriod time.Duration
+}
+
+// StatsConfig configures listing statistics.
+type StatsConfig struct {
+	// ViewDedupWindow is how long repeated views of a listing by the same
+	// visitor count as one.
+	ViewDedupWindow time.Duration
+}
+
+// FacetsConfig configures the filter value counts of the filter panel.
+type FacetsConfig struct {
+	// CacheTTL is how long counts computed for a filtered query are reused.
+	CacheTTL time.Duration
+}
+
+// ImagesConfig configures uploaded images. Store is local, which keeps the
+// files under Dir, or s3 for an S3-compatible object store. PublicURL is the
+// address the stored files are reachable under; the API serves them itself
+// at /uploads.
+type ImagesConfig struct {
+	Store     string
+	Dir       string
+	PublicURL string
+	// MaxBytes is the largest upload accepted.
+	MaxBytes int
+	S3       S3Config
+}
+
+// ExpiryConfig configures the scheduler that expires listings whose plan
+// period has run out. Every replica runs it, but only the one holding the
+// lease does the work; a zero CheckInterval turns it off on this replica.
+type ExpiryConfig struct {
+	CheckInterval time.Duration
+	// NoticePeriod is how long before expiry the seller is told.
+	NoticePeriod time.Duration
+	// LeaseTTL is how long a replica keeps the lease after it last renewed
+	// it. It must exceed CheckInterval.
+	LeaseTTL time.Duration
+}
+
+// S3Config locates the bucket of the s3 image store. Objects are addressed
+// path-style, which S3 and compatible stores such as MinIO accept.
+type S3Config struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// Load reads the configuration from environment variables, falling back to
+// defaults that are suitable for local development.
+func Load() (*Config, error) {
+	accessTTL, err := durationEnv("JWT_ACCESS_TTL", 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	refreshTTL, err := durationEnv("JWT_REFRESH_TTL", 7*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	rotationWindow, err := durationEnv("JWT_ROTATION_WINDOW", refreshTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without any key configured tokens are signed with a throwaway Ed25519
+	// key, which unlike a random secret is published for the chat server.
+	defaultAlgorithm := "EdDSA"
+	if os.Getenv("JWT_SECRET") != "" {
+		defaultAlgorithm = "HS256"
+	}
+	algorithm := stringEnv("JWT_ALGORITHM", defaultAlgorithm)
+	activeMaterial := os.Getenv("JWT_SECRET")
+	if algorithm != "HS256" {
+		activeMaterial = os.Getenv("JWT_PRIVATE_KEY_FILE")
+	}
+
+	previousKeys, err := parseKeyList(os.Getenv("JWT_PREVIOUS_KEYS"), algorithm)
+	if err != nil {
+		return nil, err
+	}
+	var rotatedAt time.Time
+	if value := os.Getenv("JWT_ROTATED_AT"); value != "" {
+		if rotatedAt, err = time.Parse(time.RFC3339, value); err != nil {
+			return nil, fmt.Errorf("invalid JWT_ROTATED_AT: %w", err)
+		}
+	} else if len(previousKeys) > 0 {
+		return nil, fmt.Errorf("JWT_ROTATED_AT is required with JWT_PREVIOUS_KEYS")
+	}
+
+	verificationTTL, err := durationEnv("MAIL_VERIFICATION_TTL", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	resetTTL, err := durationEnv("MAIL_RESET_TTL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	challengeTTL, err := durationEnv("TOTP_CHALLENGE_TTL", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	oidcProviders, err := loadOIDCProviders(os.Getenv("OIDC_PROVIDERS"))
+	if err != nil {
+		return nil, err
+	}
+	oidcStateTTL, err := durationEnv("OIDC_STATE_TTL", 10*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	loginGuard, err := loadLoginGuard()
+	if err != nil {
+		return nil, err
+	}
+
+	fakeDeclineAbove, err := intEnv("PAYMENT_FAKE_DECLINE_ABOVE", 0)
+	if err != nil {
+		return nil, err
+	}
+	subscriptionPeriod, err := durationEnv("SUBSCRIPTION_PERIOD", 30*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	gracePeriod, err := durationEnv("SUBSCRIPTION_GRACE_PERIOD", 3*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	viewDedupWindow, err := durationEnv("VIEW_DEDUP_WINDOW", 30*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	facetsCacheTTL, err := durationEnv("FACETS_CACHE_TTL", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	imageMaxBytes, err := intEnv("IMAGES_MAX_BYTES", 10<<20)
+	if err != nil {
+		return nil, err
+	}
+
+	expiry, err := loadExpiry()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Config{
+		Server: ServerConfig{
+			Port:           stringEnv("PORT", ":3001"),
+			AppURL:         strings.TrimRight(stringEnv("APP_URL", "http://localhost:5173"), "/"),
+			ChatURL:        stringEnv("CHAT_URL", "http://localhost:3000"),
+			InternalSecret: os.Getenv("INTERNAL_API_SECRET"),
+		},
+		JWT: JWTConfig{
+			Algorithm: algorithm,
+			ActiveKey: KeyConfig{
+				ID:        stringEnv("JWT_KEY_ID", "default"),
+				Algorithm: algorithm,
+				Material:  activeMaterial,
+			},
+			PreviousKeys:    previousKeys,
+			RotatedAt:       rotatedAt,
+			RotationWindow:  rotationWindow,
+			AccessTokenTTL:  accessTTL,
+			RefreshTokenTTL: refreshTTL,
+		},
+		Mail: MailConfig{
+			Transport:           stringEnv("MAIL_TRANSPORT", "file"),
+			From:                stringEnv("MAIL_FROM", "Marketplace <no-reply@localhost>"),
+			SMTPHost:            os.Getenv("SMTP_HOST"),
+			SMTPPort:            stringEnv("SMTP_PORT", "587"),
+			SMTPUsername:        os.Getenv("SMTP_USERNAME"),
+			SMTPPassword:        os.Getenv("SMTP_PASSWORD"),
+			Dir:                 stringEnv("MAIL_DIR", "mail"),
+			VerificationLinkTTL: verificationTTL,
+			ResetLinkTTL:        resetTTL,
+		},
+		TwoFactor: TwoFactorConfig{
+			Issuer:       stringEnv("TOTP_ISSUER", "Marketplace"),
+			ChallengeTTL: challengeTTL,
+		},
+		OIDC: OIDCConfig{
+			Providers: oidcProviders,
+			StateTTL:  oidcStateTTL,
+		},
+		LoginGuard: *loginGuard,
+		Payment: PaymentConfig{
+			Provider:         stringEnv("PAYMENT_PROVIDER", "fake"),
+			FakeDeclineAbove: fakeDeclineAbove,
+			Period:           subscriptionPeriod,
+			GracePeriod:      gracePeriod,
+		},
+		Stats: StatsConfig{
+			ViewDedupWindow: viewDedupWindow,
+		},
+		Facets: FacetsConfig{
+			CacheTTL: facetsCacheTTL,
+		},
+		Images: ImagesConfig{
+			Store:     stringEnv("IMAGES_STORE", "local"),
+			Dir:       stringEnv("IMAGES_DIR", "uploads"),
+			PublicURL: strings.TrimRight(stringEnv("IMAGES_PUBLIC_URL", "http://localhost:3001/uploads"), "/"),
+			MaxBytes:  imageMaxBytes,
+			S3: S3Config{
+				Endpoint:        strings.TrimRight(os.Getenv("S3_ENDPOINT"), "/"),
+				Region:          stringEnv("S3_REGION", "us-east-1"),
+				Bucket:          os.Getenv("S3_BUCKET"),
+				AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+				SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			},
+		},
+		Expiry: *expiry,
+	}, nil
+}
+
+func loadExpiry() (*ExpiryConfig, error) {
+	var cfg ExpiryConfig
+	var err error
+	if cfg.CheckInterval, err = durationEnv("LISTING_EXPIRY_INTERVAL", time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.NoticePeriod, err = durationEnv("LISTING_EXPIRY_NOTICE", 3*24*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.LeaseTTL, err = durationEnv("LISTING_EXPIRY_LEASE", 3*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.CheckInterval > 0 && cfg.LeaseTTL <= cfg.CheckInterval {
+		return nil, fmt.Errorf("LISTING_EXPIRY_LEASE must be longer than LISTING_EXPIRY_INTERVAL")
+	}
+	return &cfg, nil
+}
+
+func loadLoginGuard() (*LoginGuardConfig, error) {
+	cfg := LoginGuardConfig{Store: stringEnv("LOGIN_GUARD_STORE", "memory")}
+	var err error
+	if cfg.AccountThreshold, err = intEnv("LOGIN_ACCOUNT_THRESHOLD", 5); err != nil {
+		return nil, err
+	}
+	if cfg.IPThreshold, err = intEnv("LOGIN_IP_THRESHOLD", 50); err != nil {
+		return nil, err
+	}
+	if cfg.Window, err = durationEnv("LOGIN_WINDOW", 15*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.LockoutDuration, err = durationEnv("LOGIN_LOCKOUT", 15*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.BaseDelay, err = durationEnv("LOGIN_BASE_DELAY", time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.MaxDelay, err = durationEnv("LOGIN_MAX_DELAY", 30*time.Second); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// loadOIDCProviders reads OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID,
+// OIDC_<NAME>_CLIENT_SECRET, OIDC_<NAME>_REDIRECT_URL and OIDC_<NAME>_SCOPES
+// for every name in the comma separated list.
+func loadOIDCProviders(names string) (map[string]OIDCProviderConfig, error) {
+	providers := make(map[string]OIDCProviderConfig)
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		provider := OIDCProviderConfig{
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(stringEnv(prefix+"SCOPES", "openid email profile")),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" || provider.RedirectURL == "" {
+			return nil, fmt.Errorf("OIDC provider %q needs %sISSUER, %sCLIENT_ID and %sREDIRECT_URL", name, prefix, prefix, prefix)
+		}
+		providers[name] = provider
+	}
+	return providers, nil
+}
+
+// parseKeyList parses a comma separated list of kid:algorithm:material or
+// kid:material entries; the latter use defaultAlgorithm.
+func parseKeyList(value, defaultAlgorithm string) ([]KeyConfig, error) {
+	var keys []KeyConfig
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, material, ok := strings.Cut(entry, ":")
+		if !ok || id == "" || material == "" {
+			return nil, fmt.Errorf("invalid key entry %q, expected kid:algorithm:material", entry)
+		}
+		algorithm := defaultAlgorithm
+		if prefix, rest, ok := strings.Cut(material, ":"); ok && isJWTAlgorithm(prefix) {
+			algorithm, material = prefix, rest
+		}
+		if material == "" {
+			return nil, fmt.Errorf("invalid key entry %q, expected kid:algorithm:material", entry)
+		}
+		keys = append(keys, KeyConfig{ID: id, Algorithm: algorithm, Material: material})
+	}
+	return keys, nil
+}
+
+func isJWTAlgorithm(name string) bool {
+	return name == "HS256" || name == "RS256" || name == "EdDSA"
+}
+
+func stringEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func intEnv(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return n, nil
+}
+
+func durationEnv(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return d, nil
 }
@@ -1,36 +1,131 @@
 package app
 
 import (
+	"context"
 	"github.com/gin-gonic/gin"
 	"log"
 	"marketplace_project/config"
+	"marketplace_project/internal/blobstore"
+	"marketplace_project/internal/chat"
 	"marketplace_project/internal/db"
 	"marketplace_project/internal/handler"
+	"marketplace_project/internal/mailer"
 	"marketplace_project/internal/middleware"
+	"marketplace_project/internal/payment"
 	"marketplace_project/internal/repository"
 	"marketplace_project/internal/service"
+	"marketplace_project/internal/utils"
 )
 
 type App struct {
 	Router *gin.Engine
-	cfg    config.ServerConfig
+	cfg    *config.Config
+	expiry *service.ExpiryScheduler
 }
 
 func (a *App) Initialize() {
 	a.Router = gin.Default()
 
 	a.Router.Use(middleware.CORSMiddleware())
-	a.cfg = config.ServerConfig{Port: ":3001"}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	a.cfg = cfg
+	if a.cfg.Server.InternalSecret == "" {
+		log.Fatal("INTERNAL_API_SECRET is not set; the chat server cannot reach the internal routes without it")
+	}
+
+	if err := utils.ConfigureTokens(a.cfg.JWT); err != nil {
+		log.Fatalf("Failed to configure token keys: %v", err)
+	}
 
 	session := db.Connection()
 
+	paymentProvider, err := payment.New(a.cfg.Payment)
+	if err != nil {
+		log.Fatalf("Failed to configure payment provider: %v", err)
+	}
+	subscriptionRepo := repository.NewSubscriptionRepository(session)
+	subscriptionService := service.NewSubscriptionService(subscriptionRepo, paymentProvider, a.cfg.Payment)
+	subscriptionHandler := handler.NewSubscriptionHandler(subscriptionService)
+
+	userRepo := repository.NewUserRepository(session)
 	productRepo := repository.NewProductRepository(session)
-	productService := service.NewProductService(productRepo)
+
+	followRepo := repository.NewFollowRepository(session)
+	feedRepo := repository.NewFeedRepository(session)
+	blockRepo := repository.NewBlockRepository(session)
+	feedService := service.NewFeedService(followRepo, feedRepo, productRepo, userRepo, blockRepo)
+	followHandler := handler.NewFollowHandler(feedService)
+	chatClient := chat.NewClient(a.cfg.Server.ChatURL, a.cfg.Server.InternalSecret)
+	blockService := service.NewBlockService(blockRepo, userRepo, feedService, chatClient)
+	blockHandler := handler.NewBlockHandler(blockService)
+
+	statsService := service.NewStatsService(repository.NewStatsRepository(session), productRepo, chatClient, a.cfg.Stats)
+	favoriteService := service.NewFavoriteService(repository.NewFavoriteRepository(session), productRepo, statsService)
+	favoriteHandler := handler.NewFavoriteHandler(favoriteService)
+
+	imageStore, err := blobstore.New(a.cfg.Images)
+	if err != nil {
+		log.Fatalf("Failed to configure image store: %v", err)
+	}
+	imageService := service.NewImageService(repository.NewImageRepository(session), imageStore, a.cfg.Images)
+	imageHandler := handler.NewImageHandler(imageService)
+
+	auditRepo := repository.NewAuditRepository(session)
+	expiryRepo := repository.NewExpiryRepository(session)
+	listingExpiry := service.NewListingExpiry(expiryRepo, subscriptionService, a.cfg.Expiry)
+	productService := service.NewProductService(productRepo, auditRepo, subscriptionService, feedService, blockService, statsService, imageService, listingExpiry)
 	productHandler := handler.NewProductHandler(productService)
+	facetHandler := handler.NewFacetHandler(service.NewFacetService(repository.NewFacetRepository(session), a.cfg.Facets))
 
-	userRepo := repository.NewUserRepository(session)
-	userService := service.NewUserService(userRepo)
-	userHandler := handler.NewUserHandler(userService)
+	mail, err := mailer.New(a.cfg.Mail)
+	if err != nil {
+		log.Fatalf("Failed to configure mailer: %v", err)
+	}
+
+	a.expiry = service.NewExpiryScheduler(productService, expiryRepo, repository.NewLeaseRepository(session), userRepo, mail, a.cfg.Server.AppURL, a.cfg.Expiry)
+
+	sessionRepo := repository.NewSessionRepository(session)
+	sessionService := service.NewSessionService(sessionRepo, userRepo)
+	sessionHandler := handler.NewSessionHandler(sessionService)
+
+	actionTokenRepo := repository.NewActionTokenRepository(session)
+	verificationService := service.NewVerificationService(userRepo, sessionRepo, actionTokenRepo, mail, a.cfg.Server.AppURL, a.cfg.Mail)
+	verificationHandler := handler.NewVerificationHandler(verificationService)
+
+	twoFactorRepo := repository.NewTwoFactorRepository(session)
+	twoFactorService := service.NewTwoFactorService(twoFactorRepo, userRepo, actionTokenRepo, a.cfg.TwoFactor)
+
+	oidcRepo := repository.NewOIDCRepository(session)
+	oidcService := service.NewOIDCService(oidcRepo, userRepo, a.cfg.OIDC)
+
+	var loginAttemptRepo repository.LoginAttemptRepository
+	switch a.cfg.LoginGuard.Store {
+	case "memory":
+		loginAttemptRepo = repository.NewLoginAttemptMemoryRepository()
+	case "cassandra":
+		loginAttemptRepo = repository.NewLoginAttemptRepository(session)
+	default:
+		log.Fatalf("Unknown login guard store %q", a.cfg.LoginGuard.Store)
+	}
+	securityEventRepo := repository.NewSecurityEventRepository(session)
+	loginGuard := service.NewLoginGuard(loginAttemptRepo, securityEventRepo, userRepo, mail, a.cfg.LoginGuard)
+
+	userService := service.NewUserService(userRepo, sessionRepo, imageService)
+	userHandler := handler.NewUserHandler(userService, sessionService, verificationService, twoFactorService, oidcService, loginGuard)
+	twoFactorHandler := handler.NewTwoFactorHandler(twoFactorService, userService)
+
+	apiKeyRepo := repository.NewAPIKeyRepository(session)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo, userRepo, subscriptionService)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService)
+	middleware.ConfigureAPIKeys(apiKeyService)
+
+	reviewRepo := repository.NewReviewRepository(session)
+	reviewService := service.NewReviewService(reviewRepo, productRepo, chatClient)
+	reviewHandler := handler.NewReviewHandler(reviewService)
 
 	categoryRepo := repository.NewCategoryRepository(session)
 	categoryService := service.NewCategoryService(categoryRepo)
@@ -40,17 +135,29 @@ func (a *App) Initialize() {
 	//productService := service.NewProductService(productRepo)
 	//productHandler := handler.NewProductHandler(productService)
 
-	sectionService := service.NewSectionsService(productRepo, categoryRepo, userRepo)
+	sectionService := service.NewSectionsService(productRepo, categoryRepo, userRepo, feedRepo)
 	sectionHandler := handler.NewSectionsHandler(sectionService)
 
 	a.setRoutersForUser(userHandler)
+	a.setRoutersForSessions(sessionHandler)
+	a.setRoutersForVerification(verificationHandler)
+	a.setRoutersForTwoFactor(twoFactorHandler)
+	a.setRoutersForAPIKeys(apiKeyHandler)
+	a.setRoutersForReviews(reviewHandler)
+	a.setRoutersForSubscriptions(subscriptionHandler)
+	a.setRoutersForFollows(followHandler)
+	a.setRoutersForBlocks(blockHandler)
 	a.setRoutersForCategory(categoryHandler)
 	a.setRoutersForProduct(productHandler)
+	a.setRoutersForFacets(facetHandler)
+	a.setRoutersForImages(imageHandler)
+	a.setRoutersForFavorites(favoriteHandler)
 	a.setRoutersForSections(sectionHandler)
 }
 
 func (a *App) Run() {
-	if err := a.Router.Run(a.cfg.Port); err != nil {
+	go a.expiry.Run(context.Background())
+	if err := a.Router.Run(a.cfg.Server.Port); err != nil {
 		log.Fatalf("Failed to run server: %v", err)
 	}
 }
@@ -2,22 +2,92 @@ package app
 
 import (
 	"marketplace_project/internal/handler"
+	"marketplace_project/internal/middleware"
+	"marketplace_project/internal/models"
 )
 
 func (a *App) setRoutersForUser(userHandler *handler.UserHandler) {
 	a.Router.POST("/register", userHandler.Register)
 	a.Router.POST("/signIn", userHandler.SignIn)
+	a.Router.POST("/signIn/2fa", userHandler.SignInTwoFactor)
+	a.Router.GET("/oauth/providers", userHandler.OIDCProviders)
+	a.Router.GET("/oauth/:provider/authorize", userHandler.OIDCAuthorize)
+	a.Router.POST("/oauth/:provider/callback", userHandler.OIDCCallback)
 	a.Router.POST("/token", userHandler.RefreshToken)
 	a.Router.GET("/profileData", userHandler.UserDataByID)
+	a.Router.GET("/.well-known/jwks.json", userHandler.PublicKeys)
+	a.Router.PUT("/profile", middleware.AuthMiddleware(), userHandler.UpdateProfile)
+	a.Router.DELETE("/profile", middleware.AuthMiddleware(), userHandler.DeleteAccount)
+	a.Router.POST("/changeEmail", middleware.AuthMiddleware(), userHandler.ChangeEmail)
+	a.Router.POST("/confirmEmailChange", userHandler.ConfirmEmailChange)
+	a.Router.POST("/changePassword", middleware.AuthMiddleware(), userHandler.ChangePassword)
+	a.Router.GET("/securityEvents", middleware.AuthMiddleware(), userHandler.SecurityEvents)
+	a.Router.POST("/setAccountType", middleware.AuthMiddleware(), middleware.Authorize(middleware.Admins), userHandler.SetAccountType)
+}
+
+func (a *App) setRoutersForSessions(sessionHandler *handler.SessionHandler) {
+	a.Router.POST("/logout", middleware.AuthMiddleware(), sessionHandler.Logout)
+	a.Router.POST("/logoutAll", middleware.AuthMiddleware(), sessionHandler.LogoutAll)
+	a.Router.GET("/sessions", middleware.AuthMiddleware(), sessionHandler.Sessions)
+	a.Router.DELETE("/sessions", middleware.AuthMiddleware(), sessionHandler.RevokeSession)
+}
+
+func (a *App) setRoutersForVerification(verificationHandler *handler.VerificationHandler) {
+	a.Router.POST("/verifyEmail", verificationHandler.VerifyEmail)
+	a.Router.POST("/resendVerification", middleware.AuthMiddleware(), verificationHandler.ResendVerification)
+	a.Router.POST("/forgotPassword", verificationHandler.ForgotPassword)
+	a.Router.POST("/resetPassword", verificationHandler.ResetPassword)
+}
+
+func (a *App) setRoutersForTwoFactor(twoFactorHandler *handler.TwoFactorHandler) {
+	a.Router.POST("/2fa/setup", middleware.AuthMiddleware(), twoFactorHandler.Setup)
+	a.Router.POST("/2fa/enable", middleware.AuthMiddleware(), twoFactorHandler.Enable)
+	a.Router.POST("/2fa/disable", middleware.AuthMiddleware(), twoFactorHandler.Disable)
+	a.Router.POST("/2fa/recoveryCodes", middleware.AuthMiddleware(), twoFactorHandler.RecoveryCodes)
+}
+
+func (a *App) setRoutersForAPIKeys(apiKeyHandler *handler.APIKeyHandler) {
+	a.Router.POST("/apiKeys", middleware.AuthMiddleware(), middleware.Authorize(middleware.BusinessAccounts), apiKeyHandler.CreateKey)
+	a.Router.GET("/apiKeys", middleware.AuthMiddleware(), apiKeyHandler.ListKeys)
+	a.Router.DELETE("/apiKeys/:id", middleware.AuthMiddleware(), apiKeyHandler.RevokeKey)
+}
+
+func (a *App) setRoutersForReviews(reviewHandler *handler.ReviewHandler) {
+	a.Router.POST("/reviews", middleware.AuthMiddleware(), middleware.RequireVerifiedEmail(), reviewHandler.CreateReview)
+	a.Router.POST("/reviews/:id/reply", middleware.AuthMiddleware(), reviewHandler.ReplyToReview)
+	a.Router.GET("/reviews", reviewHandler.ListReviews)
+}
+
+func (a *App) setRoutersForSubscriptions(subscriptionHandler *handler.SubscriptionHandler) {
+	a.Router.GET("/plans", subscriptionHandler.Plans)
+	a.Router.GET("/subscription", middleware.AuthMiddleware(), subscriptionHandler.Subscription)
+	a.Router.POST("/subscription", middleware.AuthMiddleware(), subscriptionHandler.ChangePlan)
+	a.Router.DELETE("/subscription", middleware.AuthMiddleware(), subscriptionHandler.Cancel)
+}
+
+func (a *App) setRoutersForFollows(followHandler *handler.FollowHandler) {
+	a.Router.POST("/users/:id/follow", middleware.AuthMiddleware(), followHandler.Follow)
+	a.Router.DELETE("/users/:id/follow", middleware.AuthMiddleware(), followHandler.Unfollow)
+	a.Router.GET("/users/:id/followers", followHandler.Followers)
+	a.Router.GET("/users/:id/following", followHandler.Following)
+	a.Router.GET("/feed", middleware.AuthMiddleware(), followHandler.Feed)
+}
+
+func (a *App) setRoutersForBlocks(blockHandler *handler.BlockHandler) {
+	a.Router.POST("/users/:id/block", middleware.AuthMiddleware(), blockHandler.Block)
+	a.Router.DELETE("/users/:id/block", middleware.AuthMiddleware(), blockHandler.Unblock)
+	a.Router.GET("/blocks", middleware.AuthMiddleware(), blockHandler.Blocked)
+	a.Router.GET("/blockedUsers", middleware.InternalMiddleware(a.cfg.Server.InternalSecret), blockHandler.RelatedUsers)
 }
 
 func (a *App) setRoutersForCategory(categoryHandler *handler.CategoryHandler) {
-	a.Router.POST("/addCategory", categoryHandler.AddCategory)
-	a.Router.POST("/addGroup", categoryHandler.InsertSubcategoriesToGroup)
+	a.Router.POST("/addCategory", middleware.AuthMiddleware(), middleware.Authorize(middleware.CatalogManagers), categoryHandler.AddCategory)
+	a.Router.POST("/addGroup", middleware.AuthMiddleware(), middleware.Authorize(middleware.CatalogManagers), categoryHandler.InsertSubcategoriesToGroup)
+	a.Router.DELETE("/deleteCategory", middleware.AuthMiddleware(), middleware.Authorize(middleware.Admins), categoryHandler.DeleteCategory)
 	a.Router.GET("/mainCategories", categoryHandler.ListMainCategories)
 	a.Router.GET("/categories", categoryHandler.ListAllCategories)
 	a.Router.GET("/catalog", categoryHandler.GroupSubcategoriesByCategory)
-	a.Router.POST("/addSubcategory", categoryHandler.AddSubcategory)
+	a.Router.POST("/addSubcategory", middleware.AuthMiddleware(), middleware.Authorize(middleware.CatalogManagers), categoryHandler.AddSubcategory)
 	a.Router.GET("/popularCategories", categoryHandler.PopularCategories)
 	a.Router.GET("/subcategoryFields", categoryHandler.FieldsBySubcategory)
 	a.Router.GET("/brands", categoryHandler.BrandsBySubcategory)
@@ -27,17 +97,40 @@ func (a *App) setRoutersForCategory(categoryHandler *handler.CategoryHandler) {
 }
 
 func (a *App) setRoutersForProduct(productHandler *handler.ProductHandler) {
-	a.Router.POST("/addProduct", productHandler.AddProduct)
-	a.Router.DELETE("/deleteProduct", productHandler.DeleteProduct)
+	a.Router.POST("/addProduct", middleware.AuthMiddleware(models.ScopeProductsWrite), middleware.RequireVerifiedEmail(), productHandler.AddProduct)
+	a.Router.PUT("/products/:id", middleware.AuthMiddleware(models.ScopeProductsWrite), productHandler.UpdateProduct)
+	a.Router.PUT("/products/:id/status", middleware.AuthMiddleware(models.ScopeProductsWrite), productHandler.ChangeStatus)
+	a.Router.POST("/products/:id/renew", middleware.AuthMiddleware(models.ScopeProductsWrite), productHandler.Renew)
+	a.Router.DELETE("/deleteProduct", middleware.AuthMiddleware(models.ScopeProductsWrite), productHandler.DeleteProduct)
+	a.Router.GET("/myProducts", middleware.AuthMiddleware(models.ScopeProductsRead), productHandler.MyProducts)
+	a.Router.GET("/adminActions", middleware.AuthMiddleware(), middleware.Authorize(middleware.Admins), productHandler.AdminActions)
 	a.Router.POST("/recommendedProducts", productHandler.FindProductsByFilters)
 	a.Router.GET("/products", productHandler.Products)
 	a.Router.GET("/productsByCategory", productHandler.ProductsByCategoryBeta)
-	a.Router.GET("/searchProduct", productHandler.SearchEngine)
+	a.Router.GET("/searchProduct", middleware.OptionalAuthMiddleware(), productHandler.SearchEngine)
 	a.Router.GET("/findProduct", productHandler.FindProductsByFilters)
-	a.Router.GET("/product", productHandler.ProductInfo)
+	a.Router.GET("/product", middleware.OptionalAuthMiddleware(), productHandler.ProductInfo)
+	a.Router.GET("/products/:id/stats", middleware.AuthMiddleware(models.ScopeProductsRead), productHandler.ListingStats)
+	a.Router.GET("/productOwner", middleware.InternalMiddleware(a.cfg.Server.InternalSecret), productHandler.ProductOwner)
+}
+
+func (a *App) setRoutersForImages(imageHandler *handler.ImageHandler) {
+	a.Router.POST("/images", middleware.AuthMiddleware(models.ScopeProductsWrite), imageHandler.Upload)
+	a.Router.GET("/images/:id", imageHandler.Image)
+	a.Router.GET("/uploads/*key", imageHandler.File)
+}
+
+func (a *App) setRoutersForFacets(facetHandler *handler.FacetHandler) {
+	a.Router.GET("/facets", facetHandler.Facets)
+}
+
+func (a *App) setRoutersForFavorites(favoriteHandler *handler.FavoriteHandler) {
+	a.Router.POST("/products/:id/favorite", middleware.AuthMiddleware(), favoriteHandler.Favorite)
+	a.Router.DELETE("/products/:id/favorite", middleware.AuthMiddleware(), favoriteHandler.Unfavorite)
+	a.Router.GET("/favorites", middleware.AuthMiddleware(), favoriteHandler.Favorites)
 }
 
 func (a *App) setRoutersForSections(sectionHandler *handler.SectionsHandler) {
-	a.Router.GET("/getPageSections", sectionHandler.Section)
-	a.Router.GET("/user", sectionHandler.GetProfileInfo)
+	a.Router.GET("/getPageSections", middleware.OptionalAuthMiddleware(), sectionHandler.Section)
+	a.Router.GET("/user", middleware.OptionalAuthMiddleware(), sectionHandler.GetProfileInfo)
 }
@@ -0,0 +1,36 @@
+// Package blobstore keeps uploaded files such as listing photos and avatars.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"marketplace_project/config"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore stores files under slash-separated keys.
+type BlobStore interface {
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	// Get opens the file stored under key and returns its content type.
+	// It returns ErrNotFound when there is none.
+	Get(ctx context.Context, key string) (io.ReadCloser, string, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// New returns the BlobStore selected by cfg.Store.
+func New(cfg config.ImagesConfig) (BlobStore, error) {
+	switch cfg.Store {
+	case "local":
+		return NewLocalStore(cfg.Dir), nil
+	case "s3":
+		if cfg.S3.Endpoint == "" || cfg.S3.Bucket == "" {
+			return nil, fmt.Errorf("S3_ENDPOINT and S3_BUCKET are required for the s3 image store")
+		}
+		return NewS3Store(cfg.S3), nil
+	default:
+		return nil, fmt.Errorf("unknown image store %q", cfg.Store)
+	}
+}
@@ -0,0 +1,127 @@
+// Package chat queries the chat server about conversations between users and
+// tells it when block lists change.
+package chat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/models"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client answers questions about past conversations.
+type Client interface {
+	// HasListingChat reports whether buyerID has written to sellerID about
+	// the listing productID.
+	HasListingChat(ctx context.Context, productID, buyerID, sellerID gocql.UUID) (bool, error)
+	// ListingChats returns how many conversations the listing started on
+	// each UTC day from from to to, keyed by day in models.DayLayout.
+	ListingChats(ctx context.Context, productID gocql.UUID, from, to time.Time) (map[string]int64, error)
+	// BlocksChanged makes the chat server drop the block lists it cached for
+	// the users.
+	BlocksChanged(ctx context.Context, userIDs ...gocql.UUID) error
+}
+
+type httpClient struct {
+	baseURL string
+	secret  string
+	client  *http.Client
+}
+
+// NewClient returns a Client for the chat server at baseURL. secret is the
+// shared secret the chat server's internal routes require.
+func NewClient(baseURL, secret string) Client {
+	return &httpClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		secret:  secret,
+		client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (c *httpClient) HasListingChat(ctx context.Context, productID, buyerID, sellerID gocql.UUID) (bool, error) {
+	params := url.Values{}
+	params.Set("productID", productID.String())
+	params.Set("buyerID", buyerID.String())
+	params.Set("sellerID", sellerID.String())
+
+	var result struct {
+		Chatted bool `json:"chatted"`
+	}
+	if err := c.get(ctx, "/listingChat", params, &result); err != nil {
+		return false, err
+	}
+	return result.Chatted, nil
+}
+
+func (c *httpClient) ListingChats(ctx context.Context, productID gocql.UUID, from, to time.Time) (map[string]int64, error) {
+	params := url.Values{}
+	params.Set("productID", productID.String())
+	params.Set("from", from.Format(models.DayLayout))
+	params.Set("to", to.Format(models.DayLayout))
+
+	var result struct {
+		Days []struct {
+			Day   string `json:"day"`
+			Chats int64  `json:"chats"`
+		} `json:"days"`
+	}
+	if err := c.get(ctx, "/listingChats", params, &result); err != nil {
+		return nil, err
+	}
+	chats := make(map[string]int64, len(result.Days))
+	for _, day := range result.Days {
+		chats[day.Day] = day.Chats
+	}
+	return chats, nil
+}
+
+func (c *httpClient) BlocksChanged(ctx context.Context, userIDs ...gocql.UUID) error {
+	body, err := json.Marshal(map[string][]gocql.UUID{"userIDs": userIDs})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/blocksChanged", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (c *httpClient) get(ctx context.Context, path string, params url.Values, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+// do sends req with the shared secret and fails unless the chat server
+// answers 2xx. The caller closes the body of the returned response.
+func (c *httpClient) do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("X-Internal-Secret", c.secret)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("chat server: %s", resp.Status)
+	}
+	return resp, nil
+}
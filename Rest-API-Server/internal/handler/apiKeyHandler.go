@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/service"
+	"marketplace_project/internal/utils"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type APIKeyHandler struct {
+	service *service.APIKeyService
+}
+
+func NewAPIKeyHandler(service *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{service: service}
+}
+
+func (h *APIKeyHandler) CreateKey(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var request struct {
+		Name      string   `json:"name" binding:"required"`
+		Scopes    []string `json:"scopes" binding:"required"`
+		ExpiresIn string   `json:"expiresIn"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	request.Name = strings.TrimSpace(request.Name)
+	if request.Name == "" || len(request.Name) > 100 {
+		utils.RespondWithError(c, http.StatusBadRequest, "Name must be between 1 and 100 characters")
+		return
+	}
+	var ttl time.Duration
+	if request.ExpiresIn != "" {
+		var err error
+		ttl, err = time.ParseDuration(request.ExpiresIn)
+		if err != nil || ttl <= 0 {
+			utils.RespondWithError(c, http.StatusBadRequest, "expiresIn must be a positive duration such as 720h")
+			return
+		}
+	}
+
+	key, secret, err := h.service.Create(c.Request.Context(), userID, request.Name, request.Scopes, ttl)
+	if err != nil {
+		switch {
+		case errors.Is(err, utils.ErrInvalidAccountType):
+			utils.RespondWithError(c, http.StatusForbidden, "API keys are available to business accounts only")
+		case errors.Is(err, utils.ErrInvalidScope):
+			utils.RespondWithError(c, http.StatusBadRequest, "Scopes must be some of: "+strings.Join(models.APIKeyScopes, ", "))
+		case errors.Is(err, utils.ErrLimitReached):
+			utils.RespondWithError(c, http.StatusConflict, "Your plan's API key limit is reached, revoke a key or upgrade the plan")
+		default:
+			utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusCreated, gin.H{"apiKey": key, "key": secret})
+}
+
+func (h *APIKeyHandler) ListKeys(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	keys, err := h.service.List(c.Request.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"apiKeys": keys})
+}
+
+func (h *APIKeyHandler) RevokeKey(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	keyID, err := gocql.ParseUUID(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid key ID")
+		return
+	}
+	if err := h.service.Revoke(c.Request.Context(), userID, keyID); err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			utils.RespondWithError(c, http.StatusNotFound, "API key not found")
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, "API key revoked")
+}
@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/models"
+)
+
+// currentUserID returns the user authenticated by AuthMiddleware.
+func currentUserID(c *gin.Context) (gocql.UUID, bool) {
+	value, exists := c.Get("userID")
+	if !exists {
+		return gocql.UUID{}, false
+	}
+	userID, ok := value.(gocql.UUID)
+	return userID, ok
+}
+
+// currentSessionID returns the session the access token was issued for.
+func currentSessionID(c *gin.Context) gocql.UUID {
+	value, _ := c.Get("sessionID")
+	sessionID, _ := value.(gocql.UUID)
+	return sessionID
+}
+
+// currentActor returns the authenticated user together with their role.
+func currentActor(c *gin.Context) (models.Actor, bool) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return models.Actor{}, false
+	}
+	return models.Actor{UserID: userID, Role: c.GetString("role")}, true
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/service"
+	"marketplace_project/internal/utils"
+	"net/http"
+)
+
+type BlockHandler struct {
+	service *service.BlockService
+}
+
+func NewBlockHandler(service *service.BlockService) *BlockHandler {
+	return &BlockHandler{service: service}
+}
+
+func (h *BlockHandler) Block(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	blockedID, err := gocql.ParseUUID(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	if err := h.service.Block(c.Request.Context(), userID, blockedID); err != nil {
+		switch {
+		case errors.Is(err, utils.ErrSelfAction):
+			utils.RespondWithError(c, http.StatusBadRequest, "You cannot block yourself")
+		case errors.Is(err, utils.ErrNotFound):
+			utils.RespondWithError(c, http.StatusNotFound, "User not found")
+		default:
+			utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, "Blocked")
+}
+
+func (h *BlockHandler) Unblock(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	blockedID, err := gocql.ParseUUID(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	if err := h.service.Unblock(c.Request.Context(), userID, blockedID); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, "Unblocked")
+}
+
+// Blocked lists the accounts the caller has blocked.
+func (h *BlockHandler) Blocked(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	users, next, err := h.service.Blocked(c.Request.Context(), userID, page)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithPage(c, "users", users, next)
+}
+
+// RelatedUsers serves the chat server, which must keep userID apart from the
+// accounts it blocked or was blocked by.
+func (h *BlockHandler) RelatedUsers(c *gin.Context) {
+	userID, err := gocql.ParseUUID(c.Query("userID"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	related, err := h.service.RelatedUsers(c.Request.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"userIDs": related})
+}
@@ -64,6 +64,19 @@ func (h *CategoryHandler) AddSubcategory(c *gin.Context) {
 	}
 }
 
+func (h *CategoryHandler) DeleteCategory(c *gin.Context) {
+	categoryID, err := gocql.ParseUUID(c.Query("categoryID"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid category ID")
+		return
+	}
+	if err := h.service.DeleteCategory(c.Request.Context(), categoryID); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, "Category deleted")
+}
+
 func (h *CategoryHandler) ListMainCategories(c *gin.Context) {
 	categories, err := h.service.ListMainCategories(c.Request.Context())
 	if err != nil {
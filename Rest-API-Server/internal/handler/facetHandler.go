@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/service"
+	"marketplace_project/internal/utils"
+	"net/http"
+)
+
+type FacetHandler struct {
+	service *service.FacetService
+}
+
+func NewFacetHandler(service *service.FacetService) *FacetHandler {
+	return &FacetHandler{service: service}
+}
+
+// Facets returns the number of listings per filter value of a subcategory,
+// taking the filters of the query into account the same way /findProduct
+// does.
+func (h *FacetHandler) Facets(c *gin.Context) {
+	query, ok := filterQuery(c)
+	if !ok {
+		return
+	}
+	if query.CategoryID == (gocql.UUID{}) || query.SubcategoryID == (gocql.UUID{}) {
+		utils.RespondWithError(c, http.StatusBadRequest, "category and subcategory are required")
+		return
+	}
+
+	counts, err := h.service.Facets(c.Request.Context(), query)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, counts)
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/service"
+	"marketplace_project/internal/utils"
+	"net/http"
+)
+
+type FavoriteHandler struct {
+	service *service.FavoriteService
+}
+
+func NewFavoriteHandler(service *service.FavoriteService) *FavoriteHandler {
+	return &FavoriteHandler{service: service}
+}
+
+func (h *FavoriteHandler) Favorite(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	productID, err := gocql.ParseUUID(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+	if err := h.service.Favorite(c.Request.Context(), userID, productID); err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			utils.RespondWithError(c, http.StatusNotFound, "Product not found")
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, "Added to favorites")
+}
+
+func (h *FavoriteHandler) Unfavorite(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	productID, err := gocql.ParseUUID(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+	if err := h.service.Unfavorite(c.Request.Context(), userID, productID); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, "Removed from favorites")
+}
+
+func (h *FavoriteHandler) Favorites(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	products, next, err := h.service.Favorites(c.Request.Context(), userID, page)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithPage(c, "products", products, next)
+}
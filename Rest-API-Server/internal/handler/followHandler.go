@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/service"
+	"marketplace_project/internal/utils"
+	"net/http"
+)
+
+type FollowHandler struct {
+	service *service.FeedService
+}
+
+func NewFollowHandler(service *service.FeedService) *FollowHandler {
+	return &FollowHandler{service: service}
+}
+
+func (h *FollowHandler) Follow(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	sellerID, err := gocql.ParseUUID(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	if err := h.service.Follow(c.Request.Context(), userID, sellerID); err != nil {
+		switch {
+		case errors.Is(err, utils.ErrSelfAction):
+			utils.RespondWithError(c, http.StatusBadRequest, "You cannot follow yourself")
+		case errors.Is(err, utils.ErrForbidden):
+			utils.RespondWithError(c, http.StatusForbidden, "You cannot follow this user")
+		case errors.Is(err, utils.ErrNotFound):
+			utils.RespondWithError(c, http.StatusNotFound, "User not found")
+		default:
+			utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, "Following")
+}
+
+func (h *FollowHandler) Unfollow(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	sellerID, err := gocql.ParseUUID(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	if err := h.service.Unfollow(c.Request.Context(), userID, sellerID); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, "Unfollowed")
+}
+
+func (h *FollowHandler) Followers(c *gin.Context) {
+	h.listUsers(c, h.service.Followers)
+}
+
+func (h *FollowHandler) Following(c *gin.Context) {
+	h.listUsers(c, h.service.Following)
+}
+
+func (h *FollowHandler) listUsers(c *gin.Context, list func(ctx context.Context, userID gocql.UUID, page models.Page) ([]models.UserWrapContent, []byte, error)) {
+	userID, err := gocql.ParseUUID(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	users, next, err := list(c.Request.Context(), userID, page)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithPage(c, "users", users, next)
+}
+
+// Feed lists the newest listings of the sellers the caller follows.
+func (h *FollowHandler) Feed(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	items, next, err := h.service.Feed(c.Request.Context(), userID, page)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithPage(c, "products", items, next)
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gocql/gocql"
+	"io"
+	"marketplace_project/internal/service"
+	"marketplace_project/internal/utils"
+	"net/http"
+	"strings"
+)
+
+// multipartOverhead is allowed on top of the image size for the rest of the
+// multipart body.
+const multipartOverhead = 64 << 10
+
+type ImageHandler struct {
+	service *service.ImageService
+}
+
+func NewImageHandler(service *service.ImageService) *ImageHandler {
+	return &ImageHandler{service: service}
+}
+
+// Upload stores the picture sent in the "image" field of a multipart form and
+// returns its ID and the URLs of its variants.
+func (h *ImageHandler) Upload(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	maxBytes := h.service.MaxBytes()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+multipartOverhead)
+	header, err := c.FormFile("image")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			utils.RespondWithError(c, http.StatusRequestEntityTooLarge, "Image is too large")
+			return
+		}
+		utils.RespondWithError(c, http.StatusBadRequest, "An image file is required")
+		return
+	}
+	if header.Size > maxBytes {
+		utils.RespondWithError(c, http.StatusRequestEntityTooLarge, "Image is too large")
+		return
+	}
+	// The declared type is only checked for a clear early error; the content
+	// is what decides whether the file is accepted.
+	if declared := header.Header.Get("Content-Type"); declared != "" && !strings.HasPrefix(declared, "image/") {
+		utils.RespondWithError(c, http.StatusUnsupportedMediaType, "Only JPEG, PNG and GIF images are accepted")
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "An image file is required")
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Failed to read image")
+		return
+	}
+
+	image, err := h.service.Upload(c.Request.Context(), userID, data)
+	if err != nil {
+		if errors.Is(err, utils.ErrUnsupportedImage) {
+			utils.RespondWithError(c, http.StatusUnsupportedMediaType, "Only JPEG, PNG and GIF images are accepted")
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusCreated, image)
+}
+
+func (h *ImageHandler) Image(c *gin.Context) {
+	imageID, err := gocql.ParseUUID(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid image ID")
+		return
+	}
+	image, err := h.service.Image(c.Request.Context(), imageID)
+	if err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			utils.RespondWithError(c, http.StatusNotFound, "Image not found")
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, image)
+}
+
+// File serves a stored image file. Files never change once written, so they
+// may be cached indefinitely.
+func (h *ImageHandler) File(c *gin.Context) {
+	file, contentType, err := h.service.Open(c.Request.Context(), strings.TrimPrefix(c.Param("key"), "/"))
+	if err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			utils.RespondWithError(c, http.StatusNotFound, "File not found")
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer file.Close()
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.DataFromReader(http.StatusOK, -1, contentType, file, nil)
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"encoding/base64"
+	"github.com/gin-gonic/gin"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/utils"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// pageParams reads the page size from limit and the position to continue
+// from cursor, the nextCursor of the previous response. It writes a 400
+// response and returns false when either is malformed.
+func pageParams(c *gin.Context) (models.Page, bool) {
+	page := models.Page{Limit: defaultPageSize}
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			utils.RespondWithError(c, http.StatusBadRequest, "Invalid limit value")
+			return models.Page{}, false
+		}
+		page.Limit = limit
+	}
+	if value := c.Query("cursor"); value != "" {
+		state, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil {
+			utils.RespondWithError(c, http.StatusBadRequest, "Invalid cursor")
+			return models.Page{}, false
+		}
+		page.State = state
+	}
+	return page, true
+}
+
+// respondWithPage writes one page of a list under key, together with the
+// cursor of the next page. nextCursor is null on the last page; a page may
+// hold fewer than limit items without being the last.
+func respondWithPage(c *gin.Context, key string, items interface{}, next []byte) {
+	c.JSON(http.StatusOK, gin.H{
+		key:          items,
+		"nextCursor": nextCursor(next),
+	})
+}
+
+// nextCursor encodes the page state returned by a repository for clients,
+// or returns nil when there is no next page.
+func nextCursor(next []byte) *string {
+	if len(next) == 0 {
+		return nil
+	}
+	cursor := base64.RawURLEncoding.EncodeToString(next)
+	return &cursor
+}
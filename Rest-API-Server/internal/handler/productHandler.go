@@ -1,18 +1,22 @@
 package handler
 
 import (
-	"context"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/gocql/gocql"
 	"marketplace_project/internal/models"
 	"marketplace_project/internal/service"
 	"marketplace_project/internal/utils"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// maxStatsDays is the longest period listing statistics are reported for.
+const maxStatsDays = 90
+
 type ProductHandler struct {
 	service *service.ProductService
 }
@@ -21,69 +25,256 @@ func NewProductHandler(service *service.ProductService) *ProductHandler {
 	return &ProductHandler{service: service}
 }
 
-func extractKeywords(description string /*, tags []string8*/) []string {
-	description = strings.ToLower(description)
-
-	splitFunc := func(r rune) bool {
-		return r == ' ' || r == '_' || r == '-' || r == '.' || r == ','
-	}
-
-	words := strings.FieldsFunc(description, splitFunc)
-
-	keywordSet := make(map[string]struct{})
-	for _, word := range words {
-		keywordSet[word] = struct{}{}
-	}
-
-	//for _, tag := range tags {
-	//	tag = strings.ToLower(tag)
-	//	keywordSet[tag] = struct{}{}
-	//}
-
-	var keywords []string
-	for word := range keywordSet {
-		keywords = append(keywords, word)
-	}
-
-	return keywords
-}
-
+// ProductRequest is the body of POST /addProduct. Product.Images lists the IDs
+// of uploaded images.
 type ProductRequest struct {
 	Product models.Product      `json:"product"`
 	Filters []map[string]string `json:"filters"`
 }
 
 func (h *ProductHandler) AddProduct(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
 	var req ProductRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
 	req.Product.ProductID = gocql.TimeUUID()
-	req.Product.Keywords = extractKeywords(req.Product.Title /*, product.Tags*/)
+	req.Product.OwnerID = userID
+	req.Product.Keywords = utils.ExtractKeywords(req.Product.Title)
 	req.Product.CreatedAt = time.Now()
+	req.Product.Version = 1
+	if req.Product.Status == "" {
+		req.Product.Status = models.StatusActive
+	}
 
-	if err := h.service.AddProduct(&req.Product, &req.Filters); err != nil {
+	if err := h.service.AddProduct(c.Request.Context(), &req.Product, &req.Filters); err != nil {
+		if errors.Is(err, utils.ErrLimitReached) {
+			utils.RespondWithError(c, http.StatusForbidden, "Your plan's listing limit is reached, upgrade it or remove a listing")
+			return
+		}
+		if errors.Is(err, utils.ErrUnknownImage) {
+			utils.RespondWithError(c, http.StatusBadRequest, "Images must be IDs of images you uploaded")
+			return
+		}
+		if errors.Is(err, utils.ErrInvalidTransition) {
+			utils.RespondWithError(c, http.StatusBadRequest, "New listings must be draft or active")
+			return
+		}
 		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
 		return
 	}
+	c.Header("ETag", productETag(req.Product.Version))
+	utils.RespondWithJSON(c, http.StatusCreated, map[string]interface{}{
+		"productInfo": req.Product,
+		"filters":     req.Filters,
+	})
 }
 
 func (h *ProductHandler) DeleteProduct(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
 	productID, err := gocql.ParseUUID(c.Query("productID"))
 	if err != nil {
-		utils.RespondWithError(c, http.StatusBadRequest, "Invalid category ID")
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid product ID")
 		return
 	}
 
-	err = h.service.DeleteProduct(productID)
+	err = h.service.DeleteProduct(c.Request.Context(), actor, productID)
 	if err != nil {
-		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		respondWithProductError(c, err)
 		return
 	}
 	utils.RespondWithJSON(c, http.StatusOK, "Product deleted")
 }
 
+// UpdateProductRequest is the body of PUT /products/:id. The version the
+// client edited can be sent here or in an If-Match header.
+type UpdateProductRequest struct {
+	models.ProductUpdate
+	Version *int `json:"version"`
+}
+
+func (h *ProductHandler) UpdateProduct(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	productID, err := gocql.ParseUUID(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+	var req UpdateProductRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.Title != nil && strings.TrimSpace(*req.Title) == "" {
+		utils.RespondWithError(c, http.StatusBadRequest, "Title must not be empty")
+		return
+	}
+	if req.Price != nil && *req.Price < 0 {
+		utils.RespondWithError(c, http.StatusBadRequest, "Price must not be negative")
+		return
+	}
+
+	version, ok := expectedVersion(c, req.Version)
+	if !ok {
+		utils.RespondWithError(c, http.StatusPreconditionRequired, "If-Match header or version is required")
+		return
+	}
+	if req.Title != nil {
+		req.Keywords = utils.ExtractKeywords(*req.Title)
+	}
+
+	product, filters, err := h.service.UpdateProduct(c.Request.Context(), actor, productID, req.ProductUpdate, version)
+	if err != nil {
+		respondWithProductError(c, err)
+		return
+	}
+	c.Header("ETag", productETag(product.Version))
+	utils.RespondWithJSON(c, http.StatusOK, map[string]interface{}{
+		"productInfo": product,
+		"filters":     filters,
+	})
+}
+
+// ChangeStatus moves a listing to another status, e.g. to mark it sold.
+func (h *ProductHandler) ChangeStatus(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	productID, err := gocql.ParseUUID(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+	var req struct {
+		Status string `json:"status" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	status, ok := models.ParseListingStatus(req.Status)
+	if !ok {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid status value")
+		return
+	}
+
+	product, err := h.service.ChangeStatus(c.Request.Context(), actor, productID, status)
+	if err != nil {
+		respondWithProductError(c, err)
+		return
+	}
+	c.Header("ETag", productETag(product.Version))
+	utils.RespondWithJSON(c, http.StatusOK, map[string]interface{}{
+		"productInfo": product,
+	})
+}
+
+// Renew keeps a listing up for another period of the owner's plan, or puts
+// an expired listing up again.
+func (h *ProductHandler) Renew(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	productID, err := gocql.ParseUUID(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	product, err := h.service.Renew(c.Request.Context(), actor, productID)
+	if err != nil {
+		respondWithProductError(c, err)
+		return
+	}
+	c.Header("ETag", productETag(product.Version))
+	utils.RespondWithJSON(c, http.StatusOK, map[string]interface{}{
+		"productInfo": product,
+	})
+}
+
+// expectedVersion reads the version a client based its edit on from the
+// If-Match header, falling back to the version field of the body.
+func expectedVersion(c *gin.Context, bodyVersion *int) (int, bool) {
+	if header := c.GetHeader("If-Match"); header != "" {
+		version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(header, "W/"), `"`))
+		if err != nil || version < 0 {
+			return 0, false
+		}
+		return version, true
+	}
+	if bodyVersion != nil && *bodyVersion >= 0 {
+		return *bodyVersion, true
+	}
+	return 0, false
+}
+
+func productETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// AdminActions lists the changes staff made to other users' listings on the
+// given UTC day (YYYY-MM-DD), today by default.
+func (h *ProductHandler) AdminActions(c *gin.Context) {
+	day := time.Now().UTC()
+	if value := c.Query("day"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			utils.RespondWithError(c, http.StatusBadRequest, "Invalid day, expected YYYY-MM-DD")
+			return
+		}
+		day = parsed
+	}
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	actions, next, err := h.service.AdminActions(c.Request.Context(), day, page)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithPage(c, "actions", actions, next)
+}
+
+// respondWithProductError maps service errors of product mutations to
+// HTTP statuses.
+func respondWithProductError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, utils.ErrNotFound):
+		utils.RespondWithError(c, http.StatusNotFound, "Product not found")
+	case errors.Is(err, utils.ErrForbidden):
+		utils.RespondWithError(c, http.StatusForbidden, "forbidden")
+	case errors.Is(err, utils.ErrVersionConflict):
+		utils.RespondWithError(c, http.StatusPreconditionFailed, "Product was changed by someone else, reload it and try again")
+	case errors.Is(err, utils.ErrUnknownImage):
+		utils.RespondWithError(c, http.StatusBadRequest, "Images must be IDs of images you uploaded")
+	case errors.Is(err, utils.ErrInvalidTransition):
+		utils.RespondWithError(c, http.StatusConflict, "The listing cannot change to that status from its current one")
+	case errors.Is(err, utils.ErrLimitReached):
+		utils.RespondWithError(c, http.StatusForbidden, "Your plan's listing limit is reached, upgrade it or remove a listing")
+	default:
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+	}
+}
+
 func (h *ProductHandler) ProductInfo(c *gin.Context) {
 	productID, err := gocql.ParseUUID(c.Query("productID"))
 	if err != nil {
@@ -92,9 +283,26 @@ func (h *ProductHandler) ProductInfo(c *gin.Context) {
 	}
 	productInfo, filters, err := h.service.ProductInfoByID(productID)
 	if err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			utils.RespondWithError(c, http.StatusNotFound, "Product not found")
+			return
+		}
 		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
 		return
 	}
+	var actor *models.Actor
+	if current, ok := currentActor(c); ok {
+		actor = &current
+	}
+	if !h.service.CanView(productInfo, actor) {
+		utils.RespondWithError(c, http.StatusNotFound, "Product not found")
+		return
+	}
+	if viewer, ok := productViewer(c, productInfo.OwnerID); ok {
+		h.service.RecordView(c.Request.Context(), productID, viewer)
+	}
+
+	c.Header("ETag", productETag(productInfo.Version))
 	response := map[string]interface{}{
 		"productInfo": productInfo,
 		"filters":     filters,
@@ -103,6 +311,88 @@ func (h *ProductHandler) ProductInfo(c *gin.Context) {
 	utils.RespondWithJSON(c, http.StatusOK, response)
 }
 
+// productViewer identifies who is viewing a listing for view counting: the
+// signed-in user, or the client address for anonymous visitors. Owners
+// looking at their own listing are not counted.
+func productViewer(c *gin.Context, ownerID gocql.UUID) (string, bool) {
+	if userID, ok := currentUserID(c); ok {
+		if userID == ownerID {
+			return "", false
+		}
+		return "user:" + userID.String(), true
+	}
+	return "ip:" + c.ClientIP(), true
+}
+
+// listingSort reads the order of a listing list from the sort query
+// parameter. Keyword search is sorted by relevance unless asked otherwise,
+// other lists by newest; relevance is refused outside search. It writes a
+// 400 response and returns false for an unknown value.
+func listingSort(c *gin.Context, search bool) (models.ListingSort, bool) {
+	value := c.Query("sort")
+	if value == "" {
+		if search {
+			return models.SortRelevance, true
+		}
+		return models.SortNewest, true
+	}
+	order, ok := models.ParseListingSort(value)
+	if !ok || (order == models.SortRelevance && !search) {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid sort value")
+		return "", false
+	}
+	return order, true
+}
+
+// ProductOwner answers who published a listing. The chat server asks it
+// before remembering that a buyer contacted a seller about the listing.
+func (h *ProductHandler) ProductOwner(c *gin.Context) {
+	productID, err := gocql.ParseUUID(c.Query("productID"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+	ownerID, err := h.service.ProductOwner(c.Request.Context(), productID)
+	if err != nil {
+		respondWithProductError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ownerID": ownerID})
+}
+
+// ListingStats returns the daily statistics of one of the caller's listings
+// for the last days days, 7 unless given.
+func (h *ProductHandler) ListingStats(c *gin.Context) {
+	actor, ok := currentActor(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	productID, err := gocql.ParseUUID(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+	days, err := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if err != nil || days < 1 || days > maxStatsDays {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid days value")
+		return
+	}
+	stats, err := h.service.ListingStats(c.Request.Context(), actor, productID, days)
+	if err != nil {
+		switch {
+		case errors.Is(err, utils.ErrNotFound):
+			utils.RespondWithError(c, http.StatusNotFound, "Product not found")
+		case errors.Is(err, utils.ErrForbidden):
+			utils.RespondWithError(c, http.StatusForbidden, "You can only see statistics of your own listings")
+		default:
+			utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, stats)
+}
+
 func (h *ProductHandler) ProductsByCategoryBeta(c *gin.Context) {
 	categoryID, err := gocql.ParseUUID(c.Query("category_id"))
 	if err != nil {
@@ -110,26 +400,21 @@ func (h *ProductHandler) ProductsByCategoryBeta(c *gin.Context) {
 		return
 	}
 
-	lastProductIDStr := c.Query("lastProductID")
-	var lastProductID gocql.UUID
-	if lastProductIDStr != "" {
-		lastProductID, err = gocql.ParseUUID(lastProductIDStr)
-		if err != nil {
-			utils.RespondWithError(c, http.StatusBadRequest, "Invalid last product Time")
-			return
-		}
+	order, ok := listingSort(c, false)
+	if !ok {
+		return
+	}
+	page, ok := pageParams(c)
+	if !ok {
+		return
 	}
 
-	products, newPagingStateStr, err := h.service.ProductsWrapsByCategory(categoryID, lastProductID)
+	products, next, err := h.service.ProductsWrapsByCategory(c.Request.Context(), categoryID, order, page)
 	if err != nil {
 		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
 		return
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"pagingState": newPagingStateStr,
-		"products":    products,
-	})
+	respondWithPage(c, "products", products, next)
 }
 
 func (h *ProductHandler) ProductsByOwnerID(c *gin.Context) {
@@ -138,33 +423,74 @@ func (h *ProductHandler) ProductsByOwnerID(c *gin.Context) {
 		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
-	products, err := h.service.GetProductsByOwnerID(userID)
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	products, next, err := h.service.GetProductsByOwnerID(c.Request.Context(), userID, false, page)
 	if err != nil {
 		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
 		return
 	}
-	utils.RespondWithJSON(c, http.StatusOK, products)
+	respondWithPage(c, "products", products, next)
+}
+
+// MyProducts lists the listings of the authenticated user in every status.
+func (h *ProductHandler) MyProducts(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	products, next, err := h.service.GetProductsByOwnerID(c.Request.Context(), userID, true, page)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithPage(c, "products", products, next)
 }
 
 func (h *ProductHandler) Products(c *gin.Context) {
-	products, err := h.service.GetProducts()
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	products, next, err := h.service.GetProducts(c.Request.Context(), page)
 	if err != nil {
 		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
 		return
 	}
-	utils.RespondWithJSON(c, http.StatusOK, products)
+	respondWithPage(c, "products", products, next)
 }
 
+// SearchEngine searches listings by keyword. Signed-in users do not see the
+// listings of sellers they blocked unless they pass hideBlocked=false.
 func (h *ProductHandler) SearchEngine(c *gin.Context) {
 	searchQuery := c.Query("search")
 	searchQuery = strings.ToLower(searchQuery)
 	searchQuery = strings.ReplaceAll(searchQuery, "+", " ")
-	searchProduct, err := h.service.SearchProducts(searchQuery)
+	var viewerID *gocql.UUID
+	if userID, ok := currentUserID(c); ok && c.DefaultQuery("hideBlocked", "true") != "false" {
+		viewerID = &userID
+	}
+	order, ok := listingSort(c, true)
+	if !ok {
+		return
+	}
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	searchProduct, next, err := h.service.SearchProducts(c.Request.Context(), searchQuery, viewerID, order, page)
 	if err != nil {
 		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
 		return
 	}
-	utils.RespondWithJSON(c, http.StatusOK, searchProduct)
+	respondWithPage(c, "products", searchProduct, next)
 }
 
 //func (h *ProductHandler) FindProductsByFilters(c *gin.Context) {
@@ -219,102 +545,123 @@ func (h *ProductHandler) SearchEngine(c *gin.Context) {
 //	}
 //}
 
+// FindProductsByFilters lists the listings of a subcategory that meet every
+// filter given in the query, see filterQuery.
 func (h *ProductHandler) FindProductsByFilters(c *gin.Context) {
-	queryParams := c.Request.URL.Query()
-	var categoryID, subcategoryID gocql.UUID
-	filters := make(map[string]string)
-	limit := 0
-
-	for key, values := range queryParams {
-		if len(values) > 0 {
-			switch key {
-			case "category":
-				var err error
-				categoryID, err = gocql.ParseUUID(values[0])
-				if err != nil {
-					utils.RespondWithError(c, http.StatusBadRequest, "Invalid category UUID")
-					return
-				}
-			case "subcategory":
-				var err error
-				subcategoryID, err = gocql.ParseUUID(values[0])
-				if err != nil {
-					utils.RespondWithError(c, http.StatusBadRequest, "Invalid subcategory UUID")
-					return
-				}
-
-			case "limit":
-				var err error
-				limit, err = strconv.Atoi(values[0])
-				if err != nil || limit < 0 {
-					utils.RespondWithError(c, http.StatusBadRequest, "Invalid limit value")
-					return
-				}
-			default:
-				filters[key] = values[0]
-			}
-		}
+	query, ok := filterQuery(c)
+	if !ok {
+		return
 	}
-
-	if len(filters) == 0 {
+	if len(query.Conditions) == 0 && query.PriceMin == nil && query.PriceMax == nil {
 		utils.RespondWithError(c, http.StatusBadRequest, "No filters provided")
 		return
 	}
+	order, ok := listingSort(c, false)
+	if !ok {
+		return
+	}
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
 
-	// Map to store intersecting product IDs
-	productIDs := make(map[gocql.UUID]bool)
+	products, next, err := h.service.FindProductsByFilters(c.Request.Context(), query, order, page)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithPage(c, "products", products, next)
+}
 
-	// Perform the filtering and intersection logic
-	for filterName, filterValue := range filters {
-		filter := models.Filter{
-			Name:  filterName,
-			Value: filterValue,
+// filterQuery reads a filtered listing search from the query string. Besides
+// category, subcategory and the paging and sort parameters, price_min and
+// price_max bound the price, <name>_min and <name>_max bound a numeric filter
+// such as year or mileage, and any other parameter is a filter that must have
+// one of the given values, e.g. color=red,blue. It writes a 400 response and
+// returns false when a parameter is malformed.
+func filterQuery(c *gin.Context) (models.FilterQuery, bool) {
+	var query models.FilterQuery
+	conditions := make(map[string]*models.FilterCondition)
+	condition := func(name string) *models.FilterCondition {
+		if conditions[name] == nil {
+			conditions[name] = &models.FilterCondition{Name: name}
 		}
+		return conditions[name]
+	}
 
-		ids, err := h.service.FindProductsByFilters(categoryID, subcategoryID, filter, limit)
-		if err != nil {
-			utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
-			return
+	for key, values := range c.Request.URL.Query() {
+		if len(values) == 0 {
+			continue
 		}
-
-		if len(productIDs) == 0 {
-			// Initialize the map with the first filter's result
-			for _, id := range ids {
-				productIDs[id] = true
+		var err error
+		switch {
+		case key == "category":
+			query.CategoryID, err = gocql.ParseUUID(values[0])
+			if err != nil {
+				utils.RespondWithError(c, http.StatusBadRequest, "Invalid category UUID")
+				return models.FilterQuery{}, false
+			}
+		case key == "subcategory":
+			query.SubcategoryID, err = gocql.ParseUUID(values[0])
+			if err != nil {
+				utils.RespondWithError(c, http.StatusBadRequest, "Invalid subcategory UUID")
+				return models.FilterQuery{}, false
+			}
+		case key == "limit" || key == "cursor" || key == "sort":
+		case key == "price_min" || key == "price_max":
+			price, err := strconv.Atoi(values[0])
+			if err != nil || price < 0 {
+				utils.RespondWithError(c, http.StatusBadRequest, "Invalid "+key+" value")
+				return models.FilterQuery{}, false
 			}
-		} else {
-			// Perform intersection with existing productIDs
-			tempIDs := make(map[gocql.UUID]bool)
-			for _, id := range ids {
-				if productIDs[id] {
-					tempIDs[id] = true
+			if key == "price_min" {
+				query.PriceMin = &price
+			} else {
+				query.PriceMax = &price
+			}
+		case strings.HasSuffix(key, "_min") || strings.HasSuffix(key, "_max"):
+			bound, err := strconv.ParseFloat(values[0], 64)
+			if err != nil {
+				utils.RespondWithError(c, http.StatusBadRequest, "Invalid "+key+" value")
+				return models.FilterQuery{}, false
+			}
+			name := key[:len(key)-len("_min")]
+			if strings.HasSuffix(key, "_min") {
+				condition(name).Min = &bound
+			} else {
+				condition(name).Max = &bound
+			}
+		default:
+			for _, value := range values {
+				for _, option := range strings.Split(value, ",") {
+					if option = strings.TrimSpace(option); option != "" {
+						condition(key).Values = append(condition(key).Values, option)
+					}
 				}
 			}
-			productIDs = tempIDs
-		}
-
-		// If at any point the intersection is empty, break early
-		if len(productIDs) == 0 {
-			break
 		}
 	}
 
-	// Convert the map keys to a slice
-	var intersection []gocql.UUID
-	for id := range productIDs {
-		intersection = append(intersection, id)
+	if query.PriceMin != nil && query.PriceMax != nil && *query.PriceMin > *query.PriceMax {
+		utils.RespondWithError(c, http.StatusBadRequest, "price_min must not be greater than price_max")
+		return models.FilterQuery{}, false
 	}
-
-	var products []models.ProductWrapContent
-
-	for _, id := range intersection {
-		product, err := h.service.FindProductsByIDs(context.Background(), id)
-		if err != nil {
-			utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
-			return
+	for name, condition := range conditions {
+		if condition.IsRange() && len(condition.Values) > 0 {
+			utils.RespondWithError(c, http.StatusBadRequest, "Filter "+name+" cannot be both a value and a range")
+			return models.FilterQuery{}, false
 		}
-		products = append(products, *product)
+		if condition.Min != nil && condition.Max != nil && *condition.Min > *condition.Max {
+			utils.RespondWithError(c, http.StatusBadRequest, name+"_min must not be greater than "+name+"_max")
+			return models.FilterQuery{}, false
+		}
+		if !condition.IsRange() && len(condition.Values) == 0 {
+			continue
+		}
+		query.Conditions = append(query.Conditions, *condition)
 	}
-
-	utils.RespondWithJSON(c, http.StatusOK, products)
+	// The cursor continues the scan chosen from the first condition, so the
+	// order must not depend on map iteration.
+	sort.Slice(query.Conditions, func(i, j int) bool { return query.Conditions[i].Name < query.Conditions[j].Name })
+	return query, true
 }
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/service"
+	"marketplace_project/internal/utils"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+const maxReviewLength = 2000
+
+type ReviewHandler struct {
+	service *service.ReviewService
+}
+
+func NewReviewHandler(service *service.ReviewService) *ReviewHandler {
+	return &ReviewHandler{service: service}
+}
+
+func (h *ReviewHandler) CreateReview(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var request struct {
+		ProductID gocql.UUID `json:"productID" binding:"required"`
+		Rating    int        `json:"rating" binding:"required"`
+		Text      string     `json:"text"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if request.Rating < 1 || request.Rating > 5 {
+		utils.RespondWithError(c, http.StatusBadRequest, "Rating must be between 1 and 5")
+		return
+	}
+	request.Text = strings.TrimSpace(request.Text)
+	if utf8.RuneCountInString(request.Text) > maxReviewLength {
+		utils.RespondWithError(c, http.StatusBadRequest, "Review must be at most 2000 characters")
+		return
+	}
+
+	review, err := h.service.Create(c.Request.Context(), userID, request.ProductID, request.Rating, request.Text)
+	if err != nil {
+		switch {
+		case errors.Is(err, utils.ErrNotFound):
+			utils.RespondWithError(c, http.StatusNotFound, "Product not found")
+		case errors.Is(err, utils.ErrForbidden):
+			utils.RespondWithError(c, http.StatusForbidden, "Only buyers who contacted the seller about this listing can review it")
+		case errors.Is(err, utils.ErrAlreadyReviewed):
+			utils.RespondWithError(c, http.StatusConflict, err.Error())
+		default:
+			utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusCreated, review)
+}
+
+// ReplyToReview publishes the seller's reply to a review of one of their
+// listings.
+func (h *ReviewHandler) ReplyToReview(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	reviewID, err := gocql.ParseUUID(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid review ID")
+		return
+	}
+	var request struct {
+		Text string `json:"text" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	request.Text = strings.TrimSpace(request.Text)
+	if request.Text == "" || utf8.RuneCountInString(request.Text) > maxReviewLength {
+		utils.RespondWithError(c, http.StatusBadRequest, "Reply must be between 1 and 2000 characters")
+		return
+	}
+
+	review, err := h.service.Reply(c.Request.Context(), userID, reviewID, request.Text)
+	if err != nil {
+		switch {
+		case errors.Is(err, utils.ErrNotFound):
+			utils.RespondWithError(c, http.StatusNotFound, "Review not found")
+		case errors.Is(err, utils.ErrAlreadyReplied):
+			utils.RespondWithError(c, http.StatusConflict, err.Error())
+		default:
+			utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, review)
+}
+
+func (h *ReviewHandler) ListReviews(c *gin.Context) {
+	sellerID, err := gocql.ParseUUID(c.Query("sellerID"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid seller ID")
+		return
+	}
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	reviews, next, err := h.service.List(c.Request.Context(), sellerID, page)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithPage(c, "reviews", reviews, next)
+}
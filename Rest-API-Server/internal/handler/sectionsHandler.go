@@ -53,6 +53,16 @@ func (h *SectionsHandler) Section(c *gin.Context) {
 			"categoriesSection": categoriesSection,
 			"productSections":   productsSection,
 		}
+		if userID, ok := currentUserID(c); ok {
+			feedSection, err := h.service.FeedSection(c.Request.Context(), userID)
+			if err != nil {
+				utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+				return
+			}
+			if feedSection != nil {
+				response["feedSection"] = feedSection
+			}
+		}
 		utils.RespondWithJSON(c, http.StatusOK, response)
 	}
 
@@ -69,22 +79,34 @@ func (h *SectionsHandler) GetProfileInfo(c *gin.Context) {
 		return
 	}
 
-	user, products, err := h.service.GetProfileInfo(context.Background(), userID)
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	viewerID, signedIn := currentUserID(c)
+	user, products, next, err := h.service.GetProfileInfo(c.Request.Context(), userID, signedIn && viewerID == userID, page)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
 
 	type UserData struct {
-		UserID    gocql.UUID                  `json:"userID"`
-		Avatar    string                      `json:"avatar"`
-		FirstName string                      `json:"firstName"`
-		LastName  string                      `json:"lastName"`
-		Products  []models.ProductWrapContent `json:"products"`
+		UserID     gocql.UUID                  `json:"userID"`
+		Avatar     string                      `json:"avatar"`
+		FirstName  string                      `json:"firstName"`
+		LastName   string                      `json:"lastName"`
+		Products   []models.ProductWrapContent `json:"products"`
+		NextCursor *string                     `json:"nextCursor"`
 	}
 
 	response := UserData{
-		UserID:    user.UserID,
-		Avatar:    user.Avatar,
-		FirstName: user.FirstName,
-		LastName:  user.LastName,
-		Products:  products,
+		UserID:     user.UserID,
+		Avatar:     user.Avatar,
+		FirstName:  user.FirstName,
+		LastName:   user.LastName,
+		Products:   products,
+		NextCursor: nextCursor(next),
 	}
 
 	utils.RespondWithJSON(c, http.StatusOK, response)
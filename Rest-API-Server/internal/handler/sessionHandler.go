@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/service"
+	"marketplace_project/internal/utils"
+	"net/http"
+)
+
+type SessionHandler struct {
+	service *service.SessionService
+}
+
+func NewSessionHandler(service *service.SessionService) *SessionHandler {
+	return &SessionHandler{service: service}
+}
+
+func (h *SessionHandler) Logout(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if err := h.service.Logout(c.Request.Context(), userID, currentSessionID(c)); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, "Logged out")
+}
+
+func (h *SessionHandler) LogoutAll(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if err := h.service.LogoutAll(c.Request.Context(), userID); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, "Logged out from all devices")
+}
+
+func (h *SessionHandler) Sessions(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	sessions, next, err := h.service.ListSessions(c.Request.Context(), userID, currentSessionID(c), page)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithPage(c, "sessions", sessions, next)
+}
+
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	sessionID, err := gocql.ParseUUID(c.Query("sessionID"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+	if err := h.service.Logout(c.Request.Context(), userID, sessionID); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, "Session revoked")
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"marketplace_project/internal/payment"
+	"marketplace_project/internal/service"
+	"marketplace_project/internal/utils"
+	"net/http"
+)
+
+type SubscriptionHandler struct {
+	service *service.SubscriptionService
+}
+
+func NewSubscriptionHandler(service *service.SubscriptionService) *SubscriptionHandler {
+	return &SubscriptionHandler{service: service}
+}
+
+func (h *SubscriptionHandler) Plans(c *gin.Context) {
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"plans": h.service.Plans()})
+}
+
+func (h *SubscriptionHandler) Subscription(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	status, err := h.service.Status(c.Request.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, status)
+}
+
+// ChangePlan upgrades at once, charging the difference, or schedules a
+// downgrade for the end of the paid period.
+func (h *SubscriptionHandler) ChangePlan(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var request struct {
+		PlanID string `json:"planID" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	status, err := h.service.ChangePlan(c.Request.Context(), userID, request.PlanID)
+	if err != nil {
+		respondWithSubscriptionError(c, err)
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, status)
+}
+
+func (h *SubscriptionHandler) Cancel(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	status, err := h.service.Cancel(c.Request.Context(), userID)
+	if err != nil {
+		respondWithSubscriptionError(c, err)
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, status)
+}
+
+func respondWithSubscriptionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, utils.ErrUnknownPlan):
+		utils.RespondWithError(c, http.StatusBadRequest, "Unknown plan")
+	case errors.Is(err, utils.ErrNotFound):
+		utils.RespondWithError(c, http.StatusNotFound, "No paid subscription")
+	case errors.Is(err, payment.ErrPaymentDeclined):
+		utils.RespondWithError(c, http.StatusPaymentRequired, "Payment was declined")
+	case errors.Is(err, utils.ErrVersionConflict):
+		utils.RespondWithError(c, http.StatusConflict, "Subscription was changed at the same time, please try again")
+	default:
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+	}
+}
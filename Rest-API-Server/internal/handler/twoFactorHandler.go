@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"marketplace_project/internal/service"
+	"marketplace_project/internal/utils"
+	"net/http"
+)
+
+type TwoFactorHandler struct {
+	service *service.TwoFactorService
+	users   *service.UserService
+}
+
+func NewTwoFactorHandler(twoFactorService *service.TwoFactorService, userService *service.UserService) *TwoFactorHandler {
+	return &TwoFactorHandler{service: twoFactorService, users: userService}
+}
+
+type codeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+func (h *TwoFactorHandler) Setup(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	setup, err := h.service.Setup(c.Request.Context(), userID)
+	if err != nil {
+		respondWithTwoFactorError(c, err)
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, setup)
+}
+
+func (h *TwoFactorHandler) Enable(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var request codeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	codes, err := h.service.Enable(c.Request.Context(), userID, request.Code)
+	if err != nil {
+		respondWithTwoFactorError(c, err)
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var request struct {
+		Password string `json:"password" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := h.users.ConfirmPassword(c.Request.Context(), userID, request.Password); err != nil {
+		respondWithCredentialError(c, err)
+		return
+	}
+	if err := h.service.Disable(c.Request.Context(), userID, request.Code); err != nil {
+		respondWithTwoFactorError(c, err)
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, "Two-factor authentication disabled")
+}
+
+func (h *TwoFactorHandler) RecoveryCodes(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var request codeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	codes, err := h.service.RegenerateRecoveryCodes(c.Request.Context(), userID, request.Code)
+	if err != nil {
+		respondWithTwoFactorError(c, err)
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+func respondWithTwoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, utils.ErrInvalidCode):
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, utils.ErrTwoFactorEnabled), errors.Is(err, utils.ErrTwoFactorDisabled):
+		utils.RespondWithError(c, http.StatusConflict, err.Error())
+	case errors.Is(err, utils.ErrNotFound):
+		utils.RespondWithError(c, http.StatusNotFound, "User not found")
+	default:
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+	}
+}
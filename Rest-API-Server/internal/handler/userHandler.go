@@ -1,21 +1,32 @@
 package handler
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/gocql/gocql"
+	"log"
 	"marketplace_project/internal/models"
+	"marketplace_project/internal/oidc"
 	"marketplace_project/internal/service"
 	"marketplace_project/internal/utils"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type UserHandler struct {
-	service *service.UserService
+	service      *service.UserService
+	sessions     *service.SessionService
+	verification *service.VerificationService
+	twoFactor    *service.TwoFactorService
+	oidc         *service.OIDCService
+	loginGuard   *service.LoginGuard
 }
 
-func NewUserHandler(serviceUser *service.UserService) *UserHandler {
-	return &UserHandler{service: serviceUser}
+func NewUserHandler(serviceUser *service.UserService, sessionService *service.SessionService, verificationService *service.VerificationService, twoFactorService *service.TwoFactorService, oidcService *service.OIDCService, loginGuard *service.LoginGuard) *UserHandler {
+	return &UserHandler{service: serviceUser, sessions: sessionService, verification: verificationService, twoFactor: twoFactorService, oidc: oidcService, loginGuard: loginGuard}
 }
 
 func (h *UserHandler) Register(c *gin.Context) {
@@ -24,13 +35,37 @@ func (h *UserHandler) Register(c *gin.Context) {
 		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
+	if role := models.RoleFromAccountType(user.AccountType); role == models.RoleModerator || role == models.RoleAdmin {
+		utils.RespondWithError(c, http.StatusForbidden, "forbidden")
+		return
+	}
+	if err := h.loginGuard.CheckRegistration(c.Request.Context(), c.ClientIP()); err != nil {
+		respondWithThrottleError(c, err)
+		return
+	}
+	h.loginGuard.RegistrationAttempted(c.Request.Context(), c.ClientIP())
+
 	user.UserID = gocql.TimeUUID()
 	user.CreatedAt = time.Now()
-	user.Avatar = "https://firebasestorage.googleapis.com/v0/b/marketplace-dee62.appspot.com/o/avatars%2Favatar-default.svg?alt=media&token=ee6f1132-fa12-4be1-ad5c-338487892508"
+	user.EmailVerified = false
+	user.TwoFactorEnabled = false
+	user.Subscription = false
+	user.Avatar = models.DefaultAvatar
 	if err := h.service.Register(&user); err != nil {
-		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		switch {
+		case errors.Is(err, utils.ErrInvalidPhoneNumber):
+			utils.RespondWithError(c, http.StatusBadRequest, "Invalid phone number")
+		case errors.Is(err, utils.ErrEmailExists):
+			utils.RespondWithError(c, http.StatusConflict, err.Error())
+		default:
+			utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		}
 		return
 	}
+	if err := h.verification.SendVerificationEmail(c.Request.Context(), &user); err != nil {
+		log.Printf("Failed to send verification email to user %s: %v", user.UserID, err)
+	}
+	user.Password = ""
 	utils.RespondWithJSON(c, http.StatusCreated, user)
 }
 
@@ -59,13 +94,185 @@ func (h *UserHandler) SignIn(c *gin.Context) {
 		return
 	}
 
+	ctx := c.Request.Context()
+	if err := h.loginGuard.CheckSignIn(ctx, credentials.Email, c.ClientIP()); err != nil {
+		respondWithThrottleError(c, err)
+		return
+	}
+
 	user, err := h.service.SignIn(credentials.Email, credentials.Password)
 	if err != nil {
+		if errors.Is(err, utils.ErrInvalidPassword) || errors.Is(err, utils.ErrNotFound) {
+			h.loginGuard.SignInFailed(ctx, credentials.Email, c.ClientIP())
+		}
 		utils.RespondWithError(c, http.StatusBadRequest, "Invalid email or password")
 		return
 	}
+	// With two-factor sign-in the counter is cleared only once the code has
+	// been accepted, so guessing codes is throttled like guessing passwords.
+	if !user.TwoFactorEnabled {
+		h.loginGuard.SignInSucceeded(ctx, credentials.Email)
+	}
+
+	h.completeSignIn(c, user)
+}
+
+// respondWithThrottleError answers a request refused by the login guard with
+// 429 and a Retry-After header.
+func respondWithThrottleError(c *gin.Context, err error) {
+	var throttled *service.TooManyAttemptsError
+	if !errors.As(err, &throttled) {
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	seconds := int(math.Ceil(throttled.RetryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	utils.RespondWithError(c, http.StatusTooManyRequests, "Too many attempts, please try again later")
+}
 
-	accessToken, refreshToken, err := utils.GenerateToken(user.UserID, user.Email)
+// SecurityEvents lists lockouts and other security events on the caller's
+// account.
+func (h *UserHandler) SecurityEvents(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	events, next, err := h.loginGuard.SecurityEvents(c.Request.Context(), userID, page)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithPage(c, "events", events, next)
+}
+
+// completeSignIn finishes a successful first sign-in step: accounts with
+// two-factor authentication get a challenge, all others a session.
+func (h *UserHandler) completeSignIn(c *gin.Context, user *models.User) {
+	if user.TwoFactorEnabled {
+		challenge, err := h.twoFactor.StartChallenge(c.Request.Context(), user)
+		if err != nil {
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to start two-factor sign-in")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"twoFactorRequired": true,
+			"challengeToken":    challenge,
+		})
+		return
+	}
+
+	h.respondWithSession(c, user)
+}
+
+// OIDCProviders lists the external identity providers that can be used to
+// sign in.
+func (h *UserHandler) OIDCProviders(c *gin.Context) {
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"providers": h.oidc.Providers()})
+}
+
+// OIDCAuthorize returns the provider URL the web client should redirect to.
+func (h *UserHandler) OIDCAuthorize(c *gin.Context) {
+	authURL, err := h.oidc.AuthorizationURL(c.Request.Context(), c.Param("provider"))
+	if err != nil {
+		if errors.Is(err, utils.ErrUnknownProvider) {
+			utils.RespondWithError(c, http.StatusNotFound, err.Error())
+			return
+		}
+		utils.RespondWithError(c, http.StatusBadGateway, "Identity provider is unavailable")
+		log.Printf("Failed to start sign-in with %s: %v", c.Param("provider"), err)
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"url": authURL})
+}
+
+// OIDCCallback receives the state and code the provider redirected the web
+// client with and signs the user in.
+func (h *UserHandler) OIDCCallback(c *gin.Context) {
+	var request struct {
+		State string `json:"state" binding:"required"`
+		Code  string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	user, err := h.oidc.Callback(c.Request.Context(), c.Param("provider"), request.State, request.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, utils.ErrUnknownProvider):
+			utils.RespondWithError(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, utils.ErrInvalidToken):
+			utils.RespondWithError(c, http.StatusBadRequest, "Sign-in expired, please try again")
+		case errors.Is(err, utils.ErrEmailNotVerified):
+			utils.RespondWithError(c, http.StatusConflict, "The email address could not be matched to an account safely; verify it first")
+		case errors.Is(err, oidc.ErrExchangeFailed), errors.Is(err, oidc.ErrInvalidIDToken):
+			log.Printf("Sign-in with %s failed: %v", c.Param("provider"), err)
+			utils.RespondWithError(c, http.StatusUnauthorized, "Sign-in with the identity provider failed")
+		default:
+			log.Printf("Sign-in with %s failed: %v", c.Param("provider"), err)
+			utils.RespondWithError(c, http.StatusBadGateway, "Identity provider is unavailable")
+		}
+		return
+	}
+
+	h.completeSignIn(c, user)
+}
+
+// SignInTwoFactor is the second sign-in step for accounts with two-factor
+// authentication: it exchanges the challenge token and an authenticator or
+// recovery code for the usual token pair.
+func (h *UserHandler) SignInTwoFactor(c *gin.Context) {
+	var request struct {
+		ChallengeToken string `json:"challengeToken" binding:"required"`
+		Code           string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	email, err := h.twoFactor.ChallengeEmail(request.ChallengeToken)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Sign-in expired, please sign in again")
+		return
+	}
+	ctx := c.Request.Context()
+	if err := h.loginGuard.CheckSignIn(ctx, email, c.ClientIP()); err != nil {
+		respondWithThrottleError(c, err)
+		return
+	}
+
+	user, err := h.twoFactor.CompleteChallenge(ctx, request.ChallengeToken, request.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, utils.ErrInvalidToken), errors.Is(err, utils.ErrTwoFactorDisabled), errors.Is(err, utils.ErrNotFound):
+			utils.RespondWithError(c, http.StatusUnauthorized, "Sign-in expired, please sign in again")
+		case errors.Is(err, utils.ErrInvalidCode):
+			h.loginGuard.SignInFailed(ctx, email, c.ClientIP())
+			utils.RespondWithError(c, http.StatusUnauthorized, "Invalid verification code")
+		default:
+			utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.loginGuard.SignInSucceeded(ctx, email)
+
+	h.respondWithSession(c, user)
+}
+
+// respondWithSession starts a session for the signed-in user and writes the
+// token pair together with the public profile.
+func (h *UserHandler) respondWithSession(c *gin.Context, user *models.User) {
+	accessToken, refreshToken, err := h.sessions.StartSession(c.Request.Context(), user, c.Request.UserAgent(), c.ClientIP())
 	if err != nil {
 		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to generate tokens")
 		return
@@ -81,12 +288,12 @@ func (h *UserHandler) SignIn(c *gin.Context) {
 
 	accessTokenOption := Token{
 		Token:   accessToken,
-		Options: option{Exp: 10000},
+		Options: option{Exp: int(utils.AccessTokenTTL().Milliseconds())},
 	}
 
 	refreshTokenOption := Token{
 		Token:   refreshToken,
-		Options: option{Exp: 604800000},
+		Options: option{Exp: int(utils.RefreshTokenTTL().Milliseconds())},
 	}
 
 	tokens := tokens{
@@ -111,14 +318,12 @@ func (h *UserHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	userID, email, err := utils.ExtractUserIdAndEmailFromContext(tokenRequest.Token)
-	if err != nil {
-		utils.RespondWithError(c, http.StatusUnauthorized, "Invalid token")
-		return
-	}
-
-	accessToken, refreshToken, err := utils.GenerateToken(userID, email)
+	accessToken, refreshToken, err := h.sessions.Refresh(c.Request.Context(), tokenRequest.Token, c.Request.UserAgent(), c.ClientIP())
 	if err != nil {
+		if errors.Is(err, utils.ErrInvalidToken) || errors.Is(err, utils.ErrTokenReused) {
+			utils.RespondWithError(c, http.StatusUnauthorized, "Invalid token")
+			return
+		}
 		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to generate new token")
 		return
 	}
@@ -142,3 +347,180 @@ func (h *UserHandler) UserDataByID(c *gin.Context) {
 
 	utils.RespondWithJSON(c, http.StatusOK, userData)
 }
+
+func (h *UserHandler) PublicKeys(c *gin.Context) {
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"keys": utils.PublicKeys()})
+}
+
+func (h *UserHandler) SetAccountType(c *gin.Context) {
+	var request struct {
+		UserID      gocql.UUID `json:"userID" binding:"required"`
+		AccountType string     `json:"accountType" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := h.service.SetAccountType(c.Request.Context(), request.UserID, request.AccountType); err != nil {
+		switch {
+		case errors.Is(err, utils.ErrInvalidAccountType):
+			utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, utils.ErrNotFound):
+			utils.RespondWithError(c, http.StatusNotFound, err.Error())
+		default:
+			utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, "Account type updated")
+}
+
+func (h *UserHandler) UpdateProfile(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var update models.ProfileUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if message := validateProfileUpdate(&update); message != "" {
+		utils.RespondWithError(c, http.StatusBadRequest, message)
+		return
+	}
+
+	user, err := h.service.UpdateProfile(c.Request.Context(), userID, update)
+	if err != nil {
+		switch {
+		case errors.Is(err, utils.ErrInvalidPhoneNumber):
+			utils.RespondWithError(c, http.StatusBadRequest, "Invalid phone number")
+		case errors.Is(err, utils.ErrUnknownImage):
+			utils.RespondWithError(c, http.StatusBadRequest, "Avatar must be the ID of an image you uploaded")
+		case errors.Is(err, utils.ErrNotFound):
+			utils.RespondWithError(c, http.StatusNotFound, "User not found")
+		default:
+			utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, user)
+}
+
+// validateProfileUpdate trims the submitted names and returns a message for
+// the first invalid field, or an empty string.
+func validateProfileUpdate(update *models.ProfileUpdate) string {
+	for _, name := range []*string{update.FirstName, update.LastName} {
+		if name == nil {
+			continue
+		}
+		*name = strings.TrimSpace(*name)
+		if *name == "" || len(*name) > 100 {
+			return "Names must be between 1 and 100 characters"
+		}
+	}
+	return ""
+}
+
+func (h *UserHandler) ChangeEmail(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var request struct {
+		Email    string `json:"email" binding:"required,email"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := h.service.ConfirmPassword(c.Request.Context(), userID, request.Password); err != nil {
+		respondWithCredentialError(c, err)
+		return
+	}
+	if err := h.verification.RequestEmailChange(c.Request.Context(), userID, request.Email); err != nil {
+		if errors.Is(err, utils.ErrEmailExists) {
+			utils.RespondWithError(c, http.StatusConflict, err.Error())
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, "Confirmation link sent to the new address")
+}
+
+func (h *UserHandler) ConfirmEmailChange(c *gin.Context) {
+	var request TokenRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := h.verification.ConfirmEmailChange(c.Request.Context(), request.Token); err != nil {
+		switch {
+		case errors.Is(err, utils.ErrInvalidToken), errors.Is(err, utils.ErrNotFound):
+			utils.RespondWithError(c, http.StatusBadRequest, "Invalid or expired link")
+		case errors.Is(err, utils.ErrEmailExists):
+			utils.RespondWithError(c, http.StatusConflict, err.Error())
+		default:
+			utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, "Email changed")
+}
+
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var request struct {
+		CurrentPassword string `json:"currentPassword" binding:"required"`
+		NewPassword     string `json:"newPassword" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	err := h.service.ChangePassword(c.Request.Context(), userID, currentSessionID(c), request.CurrentPassword, request.NewPassword)
+	if err != nil {
+		respondWithCredentialError(c, err)
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, "Password changed")
+}
+
+func (h *UserHandler) DeleteAccount(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var request struct {
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := h.service.DeleteAccount(c.Request.Context(), userID, request.Password); err != nil {
+		respondWithCredentialError(c, err)
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, "Account deleted")
+}
+
+func respondWithCredentialError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, utils.ErrInvalidPassword):
+		utils.RespondWithError(c, http.StatusForbidden, "Current password is incorrect")
+	case errors.Is(err, utils.ErrNotFound):
+		utils.RespondWithError(c, http.StatusNotFound, "User not found")
+	default:
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+	}
+}
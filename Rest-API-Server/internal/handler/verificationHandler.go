@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"marketplace_project/internal/service"
+	"marketplace_project/internal/utils"
+	"net/http"
+)
+
+type VerificationHandler struct {
+	service *service.VerificationService
+}
+
+func NewVerificationHandler(service *service.VerificationService) *VerificationHandler {
+	return &VerificationHandler{service: service}
+}
+
+func (h *VerificationHandler) VerifyEmail(c *gin.Context) {
+	var request TokenRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := h.service.VerifyEmail(c.Request.Context(), request.Token); err != nil {
+		if errors.Is(err, utils.ErrInvalidToken) || errors.Is(err, utils.ErrNotFound) {
+			utils.RespondWithError(c, http.StatusBadRequest, "Invalid or expired link")
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, "Email verified")
+}
+
+func (h *VerificationHandler) ResendVerification(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if err := h.service.ResendVerificationEmail(c.Request.Context(), userID); err != nil {
+		if errors.Is(err, utils.ErrEmailAlreadyVerified) {
+			utils.RespondWithError(c, http.StatusConflict, err.Error())
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, "Verification email sent")
+}
+
+func (h *VerificationHandler) ForgotPassword(c *gin.Context) {
+	var request struct {
+		Email string `json:"email" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := h.service.RequestPasswordReset(c.Request.Context(), request.Email); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, "If the address is registered, a reset link has been sent")
+}
+
+func (h *VerificationHandler) ResetPassword(c *gin.Context) {
+	var request struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := h.service.ResetPassword(c.Request.Context(), request.Token, request.Password); err != nil {
+		if errors.Is(err, utils.ErrInvalidToken) {
+			utils.RespondWithError(c, http.StatusBadRequest, "Invalid or expired link")
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, "Password updated")
+}
@@ -0,0 +1,114 @@
+// Package imaging turns uploaded pictures into the variants the marketplace
+// serves. Every variant is decoded and encoded again, which leaves behind all
+// metadata of the upload, EXIF and GPS included.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrTooManyPixels     = errors.New("image dimensions too large")
+)
+
+// maxPixels bounds the decoded size of an upload, so that a small file
+// cannot make the server allocate gigabytes.
+const maxPixels = 50_000_000
+
+const jpegQuality = 85
+
+// Variant names.
+const (
+	VariantOriginal  = "original"
+	VariantMedium    = "medium"
+	VariantThumbnail = "thumbnail"
+)
+
+// variants lists the sizes generated for every upload, largest first, with
+// the longest side each is scaled down to.
+var variants = []struct {
+	name    string
+	maxSide int
+}{
+	{VariantOriginal, 2048},
+	{VariantMedium, 800},
+	{VariantThumbnail, 200},
+}
+
+// Variant is one encoded size of an upload.
+type Variant struct {
+	Name        string
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+	Data        []byte
+}
+
+// Process checks that data is a JPEG, PNG or GIF picture and returns its
+// variants. JPEG rotation recorded in EXIF is applied to the pixels before
+// the metadata is dropped. Pictures with transparency (PNG and GIF) are
+// encoded as PNG, all others as JPEG; only the first frame of an animated GIF
+// is kept.
+func Process(data []byte) ([]Variant, error) {
+	contentType := http.DetectContentType(data)
+	if contentType != "image/jpeg" && contentType != "image/png" && contentType != "image/gif" {
+		return nil, ErrUnsupportedFormat
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, ErrUnsupportedFormat
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, ErrTooManyPixels
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+
+	img := toNRGBA(decoded)
+	if contentType == "image/jpeg" {
+		img = orient(img, jpegOrientation(data))
+	}
+
+	result := make([]Variant, 0, len(variants))
+	for _, v := range variants {
+		// Each variant is scaled from the previous one, which is already
+		// smaller than the upload.
+		img = fit(img, v.maxSide)
+		variant := Variant{Name: v.name, Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+		var buf bytes.Buffer
+		if contentType == "image/jpeg" {
+			variant.ContentType, variant.Extension = "image/jpeg", ".jpg"
+			err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+		} else {
+			variant.ContentType, variant.Extension = "image/png", ".png"
+			err = png.Encode(&buf, img)
+		}
+		if err != nil {
+			return nil, err
+		}
+		variant.Data = buf.Bytes()
+		result = append(result, variant)
+	}
+	return result, nil
+}
+
+func toNRGBA(img image.Image) *image.NRGBA {
+	bounds := img.Bounds()
+	out := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(out, out.Bounds(), img, bounds.Min, draw.Src)
+	return out
+}
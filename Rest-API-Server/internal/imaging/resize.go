@@ -0,0 +1,64 @@
+package imaging
+
+import "image"
+
+// fit scales img down so that its longer side is at most maxSide, keeping the
+// aspect ratio. Smaller images are returned as they are.
+func fit(img *image.NRGBA, maxSide int) *image.NRGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w <= maxSide && h <= maxSide {
+		return img
+	}
+	dw, dh := maxSide, h*maxSide/w
+	if h > w {
+		dw, dh = w*maxSide/h, maxSide
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+	return downscale(img, dw, dh)
+}
+
+// downscale averages the source pixels that fall into each target pixel.
+// Colors are weighted by alpha so transparent pixels do not darken the
+// edges of what is visible.
+func downscale(img *image.NRGBA, dw, dh int) *image.NRGBA {
+	sw, sh := img.Bounds().Dx(), img.Bounds().Dy()
+	out := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*sh/dh, (y+1)*sh/dh
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*sw/dw, (x+1)*sw/dw
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				i := img.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					alpha := uint64(img.Pix[i+3])
+					r += uint64(img.Pix[i]) * alpha
+					g += uint64(img.Pix[i+1]) * alpha
+					b += uint64(img.Pix[i+2]) * alpha
+					a += alpha
+					n++
+					i += 4
+				}
+			}
+			o := out.PixOffset(x, y)
+			if a > 0 {
+				out.Pix[o] = uint8(r / a)
+				out.Pix[o+1] = uint8(g / a)
+				out.Pix[o+2] = uint8(b / a)
+			}
+			out.Pix[o+3] = uint8(a / n)
+		}
+	}
+	return out
+}
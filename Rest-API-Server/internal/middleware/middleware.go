@@ -1,9 +1,13 @@
 package middleware
 
 import (
+	"context"
+	"crypto/subtle"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"marketplace_project/internal/models"
 	"marketplace_project/internal/utils"
 )
 
@@ -11,7 +15,7 @@ func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "http://localhost:5173")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Authorization, If-Match, X-API-Key")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 
 		if c.Request.Method == "OPTIONS" {
@@ -23,24 +27,130 @@ func CORSMiddleware() gin.HandlerFunc {
 	}
 }
 
-func AuthMiddleware() gin.HandlerFunc {
+// APIKeyAuthenticator resolves API keys presented instead of a JWT.
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, key string) (*models.APIKeyIdentity, error)
+}
+
+var apiKeys APIKeyAuthenticator
+
+// ConfigureAPIKeys enables API key authentication in AuthMiddleware.
+func ConfigureAPIKeys(authenticator APIKeyAuthenticator) {
+	apiKeys = authenticator
+}
+
+// AuthMiddleware authenticates the request with a Bearer access token. When
+// scopes are given the route also accepts an API key, sent as a Bearer token
+// or in X-API-Key, that was granted all of them; routes without scopes are
+// only available to interactive sessions.
+func AuthMiddleware(scopes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		tokenString, err := utils.ExtractBearerToken(c.Request)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header"})
-			c.Abort()
+		tokenString := c.GetHeader("X-API-Key")
+		if tokenString == "" {
+			var err error
+			tokenString, err = utils.ExtractBearerToken(c.Request)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header"})
+				c.Abort()
+				return
+			}
+		}
+
+		if utils.IsAPIKey(tokenString) {
+			authenticateAPIKey(c, tokenString, scopes)
 			return
 		}
 
-		userID, email, err := utils.ExtractUserIdAndEmailFromContext(tokenString)
+		claims, err := utils.ParseAccessToken(tokenString)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 			c.Abort()
 			return
 		}
 
-		c.Set("userID", userID)
-		c.Set("email", email)
+		c.Set("userID", claims.UserID)
+		c.Set("email", claims.Email)
+		c.Set("sessionID", claims.SessionID)
+		c.Set("emailVerified", claims.EmailVerified)
+		c.Set("role", claims.Role)
+
+		c.Next()
+	}
+}
+
+// OptionalAuthMiddleware authenticates the request like AuthMiddleware when
+// it carries credentials and lets anonymous requests through, for pages that
+// are public but personalized for signed-in users.
+func OptionalAuthMiddleware() gin.HandlerFunc {
+	auth := AuthMiddleware()
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" && c.GetHeader("X-API-Key") == "" {
+			c.Next()
+			return
+		}
+		auth(c)
+	}
+}
+
+func authenticateAPIKey(c *gin.Context, key string, scopes []string) {
+	if len(scopes) == 0 || apiKeys == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API keys cannot be used for this endpoint"})
+		c.Abort()
+		return
+	}
+
+	identity, err := apiKeys.Authenticate(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid API key"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check API key"})
+		}
+		c.Abort()
+		return
+	}
+	for _, scope := range scopes {
+		if !identity.Key.HasScope(scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key is missing scope " + scope})
+			c.Abort()
+			return
+		}
+	}
+
+	c.Set("userID", identity.Key.UserID)
+	c.Set("email", identity.Email)
+	c.Set("emailVerified", identity.EmailVerified)
+	c.Set("role", identity.Role)
+	c.Set("apiKeyID", identity.Key.KeyID)
+
+	c.Next()
+}
+
+// InternalMiddleware admits only the chat server, which sends the shared
+// secret in X-Internal-Secret. Without a configured secret nothing is let
+// through.
+func InternalMiddleware(secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		presented := c.GetHeader("X-Internal-Secret")
+		if secret == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(secret)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "internal endpoint"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireVerifiedEmail rejects accounts that have not confirmed their email
+// address yet. It must run after AuthMiddleware.
+func RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool("emailVerified") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "email address is not verified"})
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
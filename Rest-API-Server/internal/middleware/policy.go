@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/utils"
+	"net/http"
+)
+
+// Route policies: the roles allowed to call a group of endpoints.
+var (
+	CatalogManagers = []string{models.RoleModerator, models.RoleAdmin}
+	Moderators      = []string{models.RoleModerator, models.RoleAdmin}
+	Admins          = []string{models.RoleAdmin}
+	// BusinessAccounts covers features for sellers such as API keys.
+	BusinessAccounts = []string{models.RoleBusiness}
+)
+
+// Authorize lets the request through only when the authenticated user has
+// one of roles. It must run after AuthMiddleware.
+func Authorize(roles []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		utils.RespondWithError(c, http.StatusForbidden, "forbidden")
+		c.Abort()
+	}
+}
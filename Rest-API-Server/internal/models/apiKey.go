@@ -0,0 +1,51 @@
+package models
+
+import (
+	"github.com/gocql/gocql"
+	"time"
+)
+
+// Scopes an API key can be granted. A route accepts API keys only when it
+// names the scope it needs.
+const (
+	ScopeProductsRead  = "products:read"
+	ScopeProductsWrite = "products:write"
+)
+
+var APIKeyScopes = []string{ScopeProductsRead, ScopeProductsWrite}
+
+// APIKey is a long-lived credential of a business account. Only a hash of the
+// key is stored; Prefix is kept so users can tell their keys apart.
+type APIKey struct {
+	KeyID     gocql.UUID `json:"keyID"`
+	UserID    gocql.UUID `json:"-"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	KeyHash   string     `json:"-"`
+}
+
+// HasScope reports whether the key was granted scope.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, granted := range k.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired reports whether the key has stopped working by now.
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && now.After(*k.ExpiresAt)
+}
+
+// APIKeyIdentity is the caller behind a valid API key.
+type APIKeyIdentity struct {
+	Key           *APIKey
+	Email         string
+	EmailVerified bool
+	Role          string
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"github.com/gocql/gocql"
+	"time"
+)
+
+// Actor is the authenticated user performing a request.
+type Actor struct {
+	UserID gocql.UUID
+	Role   string
+}
+
+// IsStaff reports whether the actor may act on content owned by others.
+func (a Actor) IsStaff() bool {
+	return a.Role == RoleAdmin || a.Role == RoleModerator
+}
+
+// AdminAction records a staff member acting on content they do not own.
+type AdminAction struct {
+	ActionID   gocql.UUID `json:"actionID"`
+	ActorID    gocql.UUID `json:"actorID"`
+	ActorRole  string     `json:"actorRole"`
+	Action     string     `json:"action"`
+	TargetType string     `json:"targetType"`
+	TargetID   gocql.UUID `json:"targetID"`
+	OwnerID    gocql.UUID `json:"ownerID"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
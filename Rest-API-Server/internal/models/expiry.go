@@ -0,0 +1,29 @@
+package models
+
+import (
+	"github.com/gocql/gocql"
+	"time"
+)
+
+// Kinds of listing deadlines.
+const (
+	// DeadlineNotice is when the seller is told the listing is about to expire.
+	DeadlineNotice = "notice"
+	// DeadlineExpire is when the listing expires.
+	DeadlineExpire = "expire"
+)
+
+// ListingDeadline is a point in time at which the expiry scheduler acts on a
+// listing. ExpiresAt is the expiry it was scheduled for; a deadline whose
+// listing has since been renewed or taken down no longer applies.
+type ListingDeadline struct {
+	ProductID gocql.UUID
+	Kind      string
+	DueAt     time.Time
+	ExpiresAt time.Time
+}
+
+// DeadlineDay is the partition deadlines due at t are stored in.
+func DeadlineDay(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
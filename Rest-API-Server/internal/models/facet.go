@@ -0,0 +1,19 @@
+package models
+
+// Facets counts listings per filter name and value.
+type Facets map[string]map[string]int64
+
+func (f Facets) Add(name, value string, listings int64) {
+	if f[name] == nil {
+		f[name] = make(map[string]int64)
+	}
+	f[name][value] += listings
+}
+
+// FacetCounts is the answer to a facet query. Partial is set when the
+// subcategory had too many filter or price rows to read them all, so the
+// counts only cover part of its listings and are lower bounds.
+type FacetCounts struct {
+	Facets  Facets `json:"facets"`
+	Partial bool   `json:"partial"`
+}
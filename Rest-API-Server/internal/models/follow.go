@@ -0,0 +1,10 @@
+package models
+
+import "github.com/gocql/gocql"
+
+// FeedItem is a listing in a user's feed, copied from the product when it was
+// published so the feed can be read from one partition.
+type FeedItem struct {
+	ProductWrapContent
+	SellerID gocql.UUID `json:"sellerID"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"github.com/gocql/gocql"
+	"time"
+)
+
+// Image is an uploaded picture. Listings and avatars refer to images by ID;
+// Variants maps each generated size (original, medium, thumbnail) to the URL
+// it is served at.
+type Image struct {
+	ImageID   gocql.UUID        `json:"imageID"`
+	OwnerID   gocql.UUID        `json:"ownerID"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Variants  map[string]string `json:"variants"`
+	CreatedAt time.Time         `json:"createdAt"`
+	// Keys maps each variant to the key it is stored under.
+	Keys map[string]string `json:"-"`
+}
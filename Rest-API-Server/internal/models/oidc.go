@@ -0,0 +1,10 @@
+package models
+
+// OIDCLoginState is what the API remembers about a sign-in with an external
+// provider between sending the browser away and receiving the code back.
+type OIDCLoginState struct {
+	State        string
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+}
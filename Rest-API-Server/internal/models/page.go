@@ -0,0 +1,8 @@
+package models
+
+// Page selects one page of a list: at most Limit items, continuing where the
+// page that returned State ended. A nil State starts from the beginning.
+type Page struct {
+	Limit int
+	State []byte
+}
@@ -2,6 +2,8 @@ package models
 
 import (
 	"github.com/gocql/gocql"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -18,6 +20,89 @@ type Product struct {
 	CreatedAt     time.Time  `json:"createdAt"`
 	Views         int        `json:"views"`
 	Keywords      []string   `json:"keywords,omitempty"`
+	// Version is increased by every update and is used to detect concurrent
+	// edits. Listings created before versioning was introduced have version 0.
+	Version int           `json:"version"`
+	Status  ListingStatus `json:"status"`
+	// ExpiresAt is when an active listing expires unless it is renewed.
+	// Listings published before expiry was introduced have none until
+	// go run ./cmd/backfill-listing-expiry gives them one.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// ListingStatus is the stage of a listing's life. Only active listings are
+// shown to buyers in categories, search and filters; the owner sees all of
+// their listings.
+type ListingStatus string
+
+const (
+	StatusDraft    ListingStatus = "draft"
+	StatusActive   ListingStatus = "active"
+	StatusReserved ListingStatus = "reserved"
+	StatusSold     ListingStatus = "sold"
+	StatusArchived ListingStatus = "archived"
+	// StatusExpired is set by the expiry scheduler when an active listing's
+	// period runs out; owners cannot choose it.
+	StatusExpired ListingStatus = "expired"
+)
+
+// listingTransitions lists the statuses each status may change to. Archived
+// and expired listings can be put up again; sold ones can only be archived.
+var listingTransitions = map[ListingStatus][]ListingStatus{
+	StatusDraft:    {StatusActive, StatusArchived},
+	StatusActive:   {StatusReserved, StatusSold, StatusArchived},
+	StatusReserved: {StatusActive, StatusSold, StatusArchived},
+	StatusSold:     {StatusArchived},
+	StatusArchived: {StatusActive},
+	StatusExpired:  {StatusActive, StatusArchived},
+}
+
+func ParseListingStatus(value string) (ListingStatus, bool) {
+	status := ListingStatus(value)
+	_, ok := listingTransitions[status]
+	return status, ok
+}
+
+// ListingStatusOf reads a stored status. Listings saved before statuses were
+// introduced have none and are active.
+func ListingStatusOf(stored string) ListingStatus {
+	if stored == "" {
+		return StatusActive
+	}
+	return ListingStatus(stored)
+}
+
+// CanBecome reports whether a listing may change from s to next.
+func (s ListingStatus) CanBecome(next ListingStatus) bool {
+	for _, allowed := range listingTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Listed reports whether listings in status s are shown to buyers.
+func (s ListingStatus) Listed() bool {
+	return s == StatusActive
+}
+
+// Counted reports whether listings in status s count against the owner's
+// plan limit of active listings. A reservation still occupies the place.
+func (s ListingStatus) Counted() bool {
+	return s == StatusActive || s == StatusReserved
+}
+
+// ProductUpdate holds the fields of a partial product update; nil fields are
+// left unchanged. Images lists IDs of uploaded images, or URLs of images the
+// listing already has.
+type ProductUpdate struct {
+	Title       *string              `json:"title"`
+	Description *string              `json:"description"`
+	Price       *int                 `json:"price"`
+	Images      *[]string            `json:"images"`
+	Filters     *[]map[string]string `json:"filters"`
+	Keywords    []string             `json:"-"`
 }
 
 type ProductFilters struct {
@@ -33,9 +118,81 @@ type Filter struct {
 	Value string     `json:"value"`
 }
 
+// FilterCondition is one condition of a filtered listing search. A listing
+// matches when its value of the filter Name is one of Values or, for a range
+// condition, a number between Min and Max. Nil bounds are open.
+type FilterCondition struct {
+	Name   string
+	Values []string
+	Min    *float64
+	Max    *float64
+}
+
+// IsRange reports whether the condition compares numbers rather than values.
+func (f FilterCondition) IsRange() bool {
+	return f.Min != nil || f.Max != nil
+}
+
+// Matches reports whether a listing with the given values of the filter
+// meets the condition.
+func (f FilterCondition) Matches(values []string) bool {
+	for _, value := range values {
+		if !f.IsRange() {
+			for _, wanted := range f.Values {
+				if value == wanted {
+					return true
+				}
+			}
+			continue
+		}
+		number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			continue
+		}
+		if (f.Min == nil || number >= *f.Min) && (f.Max == nil || number <= *f.Max) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterQuery selects the listings of a subcategory that meet every
+// condition and whose price is within PriceMin and PriceMax.
+type FilterQuery struct {
+	CategoryID    gocql.UUID
+	SubcategoryID gocql.UUID
+	Conditions    []FilterCondition
+	PriceMin      *int
+	PriceMax      *int
+}
+
 type ProductWrapContent struct {
 	ProductID gocql.UUID `json:"productID"`
 	Title     string     `json:"productName"`
 	Image     string     `json:"productImage"`
 	Price     int        `json:"productPrice"`
+	// Status is only filled in where listings of every status are shown.
+	Status ListingStatus `json:"status,omitempty"`
+}
+
+// ListingSort is the order in which a list of listings is returned.
+type ListingSort string
+
+const (
+	SortNewest     ListingSort = "newest"
+	SortOldest     ListingSort = "oldest"
+	SortPriceAsc   ListingSort = "price_asc"
+	SortPriceDesc  ListingSort = "price_desc"
+	SortMostViewed ListingSort = "most_viewed"
+	// SortRelevance puts first the listings whose titles consist most of the
+	// searched words. It only applies to keyword search.
+	SortRelevance ListingSort = "relevance"
+)
+
+func ParseListingSort(value string) (ListingSort, bool) {
+	switch sort := ListingSort(value); sort {
+	case SortNewest, SortOldest, SortPriceAsc, SortPriceDesc, SortMostViewed, SortRelevance:
+		return sort, true
+	}
+	return "", false
 }
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"github.com/gocql/gocql"
+	"time"
+)
+
+// Review is a buyer's rating of a seller, given for one listing they talked
+// to the seller about.
+type Review struct {
+	ReviewID  gocql.UUID   `json:"reviewID"`
+	SellerID  gocql.UUID   `json:"sellerID"`
+	ProductID gocql.UUID   `json:"productID"`
+	BuyerID   gocql.UUID   `json:"buyerID"`
+	Rating    int          `json:"rating"`
+	Text      string       `json:"text"`
+	Reply     *ReviewReply `json:"reply,omitempty"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
+// ReviewReply is the seller's single public answer to a review.
+type ReviewReply struct {
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"github.com/gocql/gocql"
+	"time"
+)
+
+// Security event types recorded for the account owner.
+const (
+	SecurityEventAccountLocked   = "account_locked"
+	SecurityEventAccountUnlocked = "account_unlocked"
+)
+
+// LoginAttempts is the failed-attempt counter of one account or client IP.
+type LoginAttempts struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+type SecurityEvent struct {
+	EventID   gocql.UUID `json:"eventID"`
+	UserID    gocql.UUID `json:"-"`
+	Type      string     `json:"type"`
+	IP        string     `json:"ip"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"github.com/gocql/gocql"
+	"time"
+)
+
+// Session is one signed-in device. Every refresh token issued for the device
+// belongs to the same session, so revoking the session revokes the family.
+type Session struct {
+	UserID     gocql.UUID `json:"-"`
+	SessionID  gocql.UUID `json:"sessionID"`
+	Device     string     `json:"device"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt time.Time  `json:"lastUsedAt"`
+	Current    bool       `json:"current"`
+}
+
+type RefreshToken struct {
+	TokenID   gocql.UUID
+	SessionID gocql.UUID
+	UserID    gocql.UUID
+	ExpiresAt time.Time
+	Used      bool
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"github.com/gocql/gocql"
+	"time"
+)
+
+// DayLayout is how days are written in listing statistics.
+const DayLayout = "2006-01-02"
+
+// StatCounters are the events counted for a listing.
+type StatCounters struct {
+	Views       int64 `json:"views"`
+	Favorites   int64 `json:"favorites"`
+	Chats       int64 `json:"chats"`
+	Impressions int64 `json:"impressions"`
+}
+
+func (c *StatCounters) Add(other StatCounters) {
+	c.Views += other.Views
+	c.Favorites += other.Favorites
+	c.Chats += other.Chats
+	c.Impressions += other.Impressions
+}
+
+// ListingStatsDay holds the counters of one UTC day.
+type ListingStatsDay struct {
+	Day string `json:"day"`
+	StatCounters
+}
+
+// ListingStats is what the owner of a listing sees about its reach, one entry
+// per day, oldest first.
+type ListingStats struct {
+	ProductID gocql.UUID        `json:"productID"`
+	Days      []ListingStatsDay `json:"days"`
+	Totals    StatCounters      `json:"totals"`
+}
+
+// StatsDay returns the UTC day t falls on, the bucket its events are counted
+// in.
+func StatsDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
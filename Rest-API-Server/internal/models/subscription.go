@@ -0,0 +1,78 @@
+package models
+
+import (
+	"github.com/gocql/gocql"
+	"time"
+)
+
+const (
+	PlanFree = "free"
+	PlanPlus = "plus"
+	PlanPro  = "pro"
+)
+
+// Subscription statuses. A past_due subscription keeps its plan until the
+// grace period after PeriodEnd runs out; a canceled one until PeriodEnd.
+const (
+	SubscriptionActive   = "active"
+	SubscriptionPastDue  = "past_due"
+	SubscriptionCanceled = "canceled"
+)
+
+// Plan is a subscription tier and the limits that come with it.
+type Plan struct {
+	ID                string `json:"id"`
+	Name              string `json:"name"`
+	PriceCents        int    `json:"priceCents"`
+	MaxActiveListings int    `json:"maxActiveListings"`
+	// BoostedSlots is how many listings may be promoted at once.
+	BoostedSlots int `json:"boostedSlots"`
+	MaxAPIKeys   int `json:"maxAPIKeys"`
+	// ListingDays is how long a listing stays up before it has to be renewed.
+	ListingDays int `json:"listingDays"`
+}
+
+// ListingPeriod is how long a listing published under the plan stays up.
+func (p Plan) ListingPeriod() time.Duration {
+	return time.Duration(p.ListingDays) * 24 * time.Hour
+}
+
+// Plans lists the available tiers, cheapest first.
+var Plans = []Plan{
+	{ID: PlanFree, Name: "Free", PriceCents: 0, MaxActiveListings: 10, BoostedSlots: 0, MaxAPIKeys: 2, ListingDays: 30},
+	{ID: PlanPlus, Name: "Plus", PriceCents: 999, MaxActiveListings: 100, BoostedSlots: 3, MaxAPIKeys: 5, ListingDays: 60},
+	{ID: PlanPro, Name: "Pro", PriceCents: 2999, MaxActiveListings: 1000, BoostedSlots: 20, MaxAPIKeys: 20, ListingDays: 90},
+}
+
+// PlanByID returns the plan with the given id.
+func PlanByID(id string) (Plan, bool) {
+	for _, plan := range Plans {
+		if plan.ID == id {
+			return plan, true
+		}
+	}
+	return Plan{}, false
+}
+
+// Subscription is a user's paid plan. Users without one are on the free plan.
+type Subscription struct {
+	UserID      gocql.UUID `json:"-"`
+	PlanID      string     `json:"planID"`
+	Status      string     `json:"status"`
+	PeriodStart time.Time  `json:"periodStart"`
+	PeriodEnd   time.Time  `json:"periodEnd"`
+	// GraceUntil is when the plan is withdrawn if renewal keeps failing.
+	GraceUntil time.Time `json:"graceUntil"`
+	// RetryAt is when a failed renewal is attempted again.
+	RetryAt time.Time `json:"-"`
+	// PendingPlanID is a downgrade that takes effect at PeriodEnd.
+	PendingPlanID string `json:"pendingPlanID,omitempty"`
+	ProviderRef   string `json:"-"`
+}
+
+// SubscriptionStatus is what a user is entitled to right now.
+type SubscriptionStatus struct {
+	Plan         Plan          `json:"plan"`
+	Subscription *Subscription `json:"subscription,omitempty"`
+	InGrace      bool          `json:"inGrace"`
+}
@@ -0,0 +1,19 @@
+package models
+
+// TwoFactor is the TOTP state of an account. A secret is stored as soon as
+// setup starts, but it is only enforced at sign-in once Enabled is set after
+// the user proved their authenticator works.
+type TwoFactor struct {
+	Secret  string
+	Enabled bool
+	// LastStep is the time step of the last accepted code, so the same code
+	// cannot be used twice. Nil when no code was accepted yet.
+	LastStep *int64
+}
+
+// TwoFactorSetup is returned when enrollment starts. URI is meant to be shown
+// as a QR code.
+type TwoFactorSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
@@ -6,23 +6,59 @@ import (
 	"time"
 )
 
-type phoneNumber struct {
+// Roles carried in access tokens. They are derived from the account type, so
+// moderator and admin accounts can only be created by an admin.
+const (
+	RoleUser      = "user"
+	RoleBusiness  = "business"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+func RoleFromAccountType(accountType string) string {
+	switch accountType {
+	case RoleBusiness, RoleModerator, RoleAdmin:
+		return accountType
+	default:
+		return RoleUser
+	}
+}
+
+// DefaultAvatar is assigned to new accounts until the user uploads a picture.
+const DefaultAvatar = "https://firebasestorage.googleapis.com/v0/b/marketplace-dee62.appspot.com/o/avatars%2Favatar-default.svg?alt=media&token=ee6f1132-fa12-4be1-ad5c-338487892508"
+
+// PhoneNumber is a phone number as entered by the user. It is stored on the
+// account in E.164 format.
+type PhoneNumber struct {
 	CountryCode string `json:"countryCode"`
 	Number      string `json:"number"`
 }
 
 type User struct {
-	UserID       gocql.UUID       `json:"userID"`
-	FirstName    string           `json:"firstName"`
-	LastName     string           `json:"lastName"`
-	Avatar       string           `json:"avatar"`
-	PhoneNumber  string           `json:"phoneNumber"`
-	Email        string           `json:"email"`
-	Password     string           `json:"password"`
-	AccountType  string           `json:"accountType"`
-	Subscription bool             `json:"subscription"`
-	Rating       *decimal.Decimal `json:"rating"`
-	CreatedAt    time.Time        `json:"createdAt"`
+	UserID        gocql.UUID `json:"userID"`
+	FirstName     string     `json:"firstName"`
+	LastName      string     `json:"lastName"`
+	Avatar        string     `json:"avatar"`
+	PhoneNumber   string     `json:"phoneNumber"`
+	Email         string     `json:"email"`
+	EmailVerified bool       `json:"emailVerified"`
+	// TwoFactorEnabled is read from the account and ignored on registration.
+	TwoFactorEnabled bool             `json:"twoFactorEnabled"`
+	Password         string           `json:"password"`
+	AccountType      string           `json:"accountType"`
+	Subscription     bool             `json:"subscription"`
+	Rating           *decimal.Decimal `json:"rating"`
+	CreatedAt        time.Time        `json:"createdAt"`
+}
+
+// ProfileUpdate holds the fields of a partial profile update; nil fields are
+// left unchanged. An empty PhoneNumber removes the number from the account.
+// Avatar is the ID of an uploaded image.
+type ProfileUpdate struct {
+	FirstName   *string      `json:"firstName"`
+	LastName    *string      `json:"lastName"`
+	Avatar      *string      `json:"avatar"`
+	PhoneNumber *PhoneNumber `json:"phoneNumber"`
 }
 
 type UserWrapContent struct {
@@ -32,5 +68,6 @@ type UserWrapContent struct {
 	Avatar      string               `json:"avatar"`
 	AccountType string               `json:"accountType"`
 	Rating      *decimal.Decimal     `json:"rating"`
+	ReviewCount int                  `json:"reviewCount"`
 	Products    []ProductWrapContent `json:"products,omitempty"`
 }
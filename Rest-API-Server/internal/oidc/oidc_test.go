@@ -0,0 +1,104 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"marketplace_project/config"
+	"marketplace_project/internal/oidc"
+	"marketplace_project/internal/oidc/oidctest"
+	"testing"
+)
+
+const clientID = "marketplace"
+
+func newProvider(issuer *oidctest.Issuer) *oidc.Provider {
+	return oidc.NewProvider("mock", config.OIDCProviderConfig{
+		Issuer:      issuer.URL,
+		ClientID:    clientID,
+		RedirectURL: "http://localhost:5173/oauth/callback",
+		Scopes:      []string{"openid", "email", "profile"},
+	})
+}
+
+// signIn starts a sign-in with a fresh verifier and nonce and returns the
+// code the issuer hands out for it.
+func signIn(t *testing.T, issuer *oidctest.Issuer, provider *oidc.Provider, claims map[string]interface{}) (code, verifier, nonce string) {
+	t.Helper()
+	verifier, err := oidc.RandomToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce, err = oidc.RandomToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := provider.AuthCodeURL(context.Background(), "state", nonce, oidc.CodeChallenge(verifier))
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	return issuer.Authorize(t, authURL, claims), verifier, nonce
+}
+
+func TestExchangeReturnsVerifiedIdentity(t *testing.T) {
+	issuer := oidctest.NewIssuer(t)
+	provider := newProvider(issuer)
+	code, verifier, nonce := signIn(t, issuer, provider, map[string]interface{}{
+		"sub":            "subject-1",
+		"email":          "ann@example.com",
+		"email_verified": true,
+		"given_name":     "Ann",
+	})
+
+	identity, err := provider.Exchange(context.Background(), code, verifier, nonce)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if identity.Subject != "subject-1" || identity.Email != "ann@example.com" || !identity.EmailVerified || identity.GivenName != "Ann" {
+		t.Errorf("unexpected identity %+v", identity)
+	}
+}
+
+func TestExchangeRequiresPKCEVerifier(t *testing.T) {
+	issuer := oidctest.NewIssuer(t)
+	provider := newProvider(issuer)
+	code, _, nonce := signIn(t, issuer, provider, map[string]interface{}{"sub": "subject-1"})
+
+	otherVerifier, err := oidc.RandomToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Exchange(context.Background(), code, otherVerifier, nonce); !errors.Is(err, oidc.ErrExchangeFailed) {
+		t.Fatalf("Exchange with the wrong verifier: got %v, want ErrExchangeFailed", err)
+	}
+}
+
+func TestExchangeRejectsMismatchedIDToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		nonce  string
+	}{
+		{name: "nonce", nonce: "another-nonce"},
+		{name: "issuer", claims: map[string]interface{}{"iss": "https://attacker.example.com"}},
+		{name: "audience", claims: map[string]interface{}{"aud": "another-client"}},
+		{name: "subject", claims: map[string]interface{}{"sub": ""}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			issuer := oidctest.NewIssuer(t)
+			provider := newProvider(issuer)
+			claims := map[string]interface{}{"sub": "subject-1"}
+			for name, value := range test.claims {
+				claims[name] = value
+			}
+			code, verifier, nonce := signIn(t, issuer, provider, claims)
+			if test.nonce != "" {
+				nonce = test.nonce
+			}
+
+			if _, err := provider.Exchange(context.Background(), code, verifier, nonce); !errors.Is(err, oidc.ErrInvalidIDToken) {
+				t.Fatalf("got %v, want ErrInvalidIDToken", err)
+			}
+		})
+	}
+}
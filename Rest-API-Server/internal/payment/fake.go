@@ -0,0 +1,60 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// FakeProvider accepts every charge up to a limit without moving money. It is
+// meant for local development.
+type FakeProvider struct {
+	mu           sync.Mutex
+	declineAbove int
+	charges      map[string]Charge
+	byKey        map[string]string
+	next         int
+}
+
+// NewFakeProvider returns a provider that declines charges above
+// declineAbove cents; zero accepts everything.
+func NewFakeProvider(declineAbove int) *FakeProvider {
+	return &FakeProvider{
+		declineAbove: declineAbove,
+		charges:      make(map[string]Charge),
+		byKey:        make(map[string]string),
+	}
+}
+
+func (p *FakeProvider) Charge(ctx context.Context, charge Charge) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if reference, ok := p.byKey[charge.IdempotencyKey]; ok && charge.IdempotencyKey != "" {
+		return reference, nil
+	}
+	if p.declineAbove > 0 && charge.AmountCents > p.declineAbove {
+		return "", ErrPaymentDeclined
+	}
+	p.next++
+	reference := fmt.Sprintf("fake_%d", p.next)
+	p.charges[reference] = charge
+	if charge.IdempotencyKey != "" {
+		p.byKey[charge.IdempotencyKey] = reference
+	}
+	log.Printf("Fake payment %s: charged user %s %d cents for %s", reference, charge.UserID, charge.AmountCents, charge.Description)
+	return reference, nil
+}
+
+func (p *FakeProvider) Refund(ctx context.Context, reference string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	charge, ok := p.charges[reference]
+	if !ok {
+		return fmt.Errorf("unknown charge %q", reference)
+	}
+	delete(p.charges, reference)
+	delete(p.byKey, charge.IdempotencyKey)
+	log.Printf("Fake payment %s: refunded user %s %d cents", reference, charge.UserID, charge.AmountCents)
+	return nil
+}
@@ -0,0 +1,40 @@
+// Package payment charges users for subscription plans.
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gocql/gocql"
+	"marketplace_project/config"
+)
+
+var ErrPaymentDeclined = errors.New("payment declined")
+
+// Charge describes one payment for a subscription period.
+type Charge struct {
+	UserID      gocql.UUID
+	AmountCents int
+	Description string
+	// IdempotencyKey lets the provider recognize a retried charge.
+	IdempotencyKey string
+}
+
+// Provider collects payments. Implementations return ErrPaymentDeclined when
+// the customer's payment method was refused.
+type Provider interface {
+	// Charge collects the amount and returns the provider's reference.
+	Charge(ctx context.Context, charge Charge) (string, error)
+	// Refund returns a previous charge in full.
+	Refund(ctx context.Context, reference string) error
+}
+
+// New returns the Provider selected by cfg.Provider.
+func New(cfg config.PaymentConfig) (Provider, error) {
+	switch cfg.Provider {
+	case "fake":
+		return NewFakeProvider(cfg.FakeDeclineAbove), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", cfg.Provider)
+	}
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/utils"
+	"time"
+)
+
+// ActionTokenRepository tracks single-use tokens, such as email verification
+// and password reset links, until they are consumed or expire.
+type ActionTokenRepository interface {
+	SaveActionToken(ctx context.Context, tokenID gocql.UUID, userID gocql.UUID, purpose string, ttl time.Duration) error
+	ConsumeActionToken(ctx context.Context, tokenID gocql.UUID, userID gocql.UUID, purpose string) (bool, error)
+	// RecordActionTokenFailure counts a failed attempt to use the token and
+	// returns the number so far. It fails with ErrNotFound when the token
+	// has been consumed or has expired.
+	RecordActionTokenFailure(ctx context.Context, tokenID gocql.UUID, purpose string) (int, error)
+}
+
+type actionTokenRepository struct {
+	session *gocql.Session
+}
+
+func NewActionTokenRepository(session *gocql.Session) ActionTokenRepository {
+	return &actionTokenRepository{session: session}
+}
+
+func (r *actionTokenRepository) SaveActionToken(ctx context.Context, tokenID gocql.UUID, userID gocql.UUID, purpose string, ttl time.Duration) error {
+	query := "INSERT INTO marketplace_keyspace.user_action_tokens(token_id, user_id, purpose) VALUES (?, ?, ?) USING TTL ?"
+	return r.session.Query(query, tokenID, userID, purpose, int(ttl.Seconds())).WithContext(ctx).Exec()
+}
+
+// ConsumeActionToken deletes the token with a lightweight transaction and
+// reports whether it existed for this user and purpose, so a link can be
+// used only once even when it is opened twice at the same time.
+func (r *actionTokenRepository) ConsumeActionToken(ctx context.Context, tokenID gocql.UUID, userID gocql.UUID, purpose string) (bool, error) {
+	query := "DELETE FROM marketplace_keyspace.user_action_tokens WHERE token_id = ? IF user_id = ? AND purpose = ?"
+	return r.session.Query(query, tokenID, userID, purpose).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+}
+
+// RecordActionTokenFailure increments the failure count with a compare-and-set
+// on the read value, keeping the token's remaining lifetime. The purpose is
+// part of the condition so a token consumed in the meantime is not recreated.
+func (r *actionTokenRepository) RecordActionTokenFailure(ctx context.Context, tokenID gocql.UUID, purpose string) (int, error) {
+	for i := 0; i < maxCASRetries; i++ {
+		var failures *int
+		var ttl int
+		query := "SELECT failures, TTL(purpose) FROM marketplace_keyspace.user_action_tokens WHERE token_id = ?"
+		if err := r.session.Query(query, tokenID).WithContext(ctx).Scan(&failures, &ttl); err != nil {
+			if errors.Is(err, gocql.ErrNotFound) {
+				return 0, utils.ErrNotFound
+			}
+			return 0, err
+		}
+		if ttl <= 0 {
+			return 0, utils.ErrNotFound
+		}
+
+		next := 1
+		if failures != nil {
+			next = *failures + 1
+		}
+		query = "UPDATE marketplace_keyspace.user_action_tokens USING TTL ? SET failures = ? WHERE token_id = ? IF failures = ? AND purpose = ?"
+		applied, err := r.session.Query(query, ttl, next, tokenID, failures, purpose).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+		if err != nil {
+			return 0, err
+		}
+		if applied {
+			return next, nil
+		}
+	}
+	return 0, fmt.Errorf("record failed use of token %s: too much contention", tokenID)
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/utils"
+)
+
+// APIKeyRepository stores every key twice: by hash for authentication and by
+// owner for listing and revocation.
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key *models.APIKey) error
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+	ListAPIKeys(ctx context.Context, userID gocql.UUID) ([]models.APIKey, error)
+	DeleteAPIKey(ctx context.Context, userID gocql.UUID, keyID gocql.UUID) error
+}
+
+type apiKeyRepository struct {
+	session *gocql.Session
+}
+
+func NewAPIKeyRepository(session *gocql.Session) APIKeyRepository {
+	return &apiKeyRepository{session: session}
+}
+
+func (r *apiKeyRepository) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	query := "INSERT INTO marketplace_keyspace.api_keys_by_hash(key_hash, key_id, user_id, name, prefix, scopes, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	if err := r.session.Query(query, key.KeyHash, key.KeyID, key.UserID, key.Name, key.Prefix, key.Scopes, key.CreatedAt, key.ExpiresAt).WithContext(ctx).Exec(); err != nil {
+		return err
+	}
+	query = "INSERT INTO marketplace_keyspace.api_keys_by_user(user_id, key_id, key_hash, name, prefix, scopes, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	return r.session.Query(query, key.UserID, key.KeyID, key.KeyHash, key.Name, key.Prefix, key.Scopes, key.CreatedAt, key.ExpiresAt).WithContext(ctx).Exec()
+}
+
+func (r *apiKeyRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	query := "SELECT key_id, user_id, name, prefix, scopes, created_at, expires_at FROM marketplace_keyspace.api_keys_by_hash WHERE key_hash = ?"
+	key := models.APIKey{KeyHash: keyHash}
+	if err := r.session.Query(query, keyHash).WithContext(ctx).Scan(&key.KeyID, &key.UserID, &key.Name, &key.Prefix, &key.Scopes, &key.CreatedAt, &key.ExpiresAt); err != nil {
+		if errors.Is(err, gocql.ErrNotFound) {
+			return nil, utils.ErrNotFound
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) ListAPIKeys(ctx context.Context, userID gocql.UUID) ([]models.APIKey, error) {
+	query := "SELECT key_id, key_hash, name, prefix, scopes, created_at, expires_at FROM marketplace_keyspace.api_keys_by_user WHERE user_id = ?"
+	iter := r.session.Query(query, userID).WithContext(ctx).Iter()
+	defer iter.Close()
+
+	keys := []models.APIKey{}
+	for {
+		key := models.APIKey{UserID: userID}
+		if !iter.Scan(&key.KeyID, &key.KeyHash, &key.Name, &key.Prefix, &key.Scopes, &key.CreatedAt, &key.ExpiresAt) {
+			break
+		}
+		keys = append(keys, key)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// DeleteAPIKey revokes a key of userID. The hash row is removed first, so the
+// key stops working even if removing it from the listing fails.
+func (r *apiKeyRepository) DeleteAPIKey(ctx context.Context, userID gocql.UUID, keyID gocql.UUID) error {
+	query := "SELECT key_hash FROM marketplace_keyspace.api_keys_by_user WHERE user_id = ? AND key_id = ?"
+	var keyHash string
+	if err := r.session.Query(query, userID, keyID).WithContext(ctx).Scan(&keyHash); err != nil {
+		if errors.Is(err, gocql.ErrNotFound) {
+			return utils.ErrNotFound
+		}
+		return err
+	}
+	if err := r.session.Query("DELETE FROM marketplace_keyspace.api_keys_by_hash WHERE key_hash = ?", keyHash).WithContext(ctx).Exec(); err != nil {
+		return err
+	}
+	return r.session.Query("DELETE FROM marketplace_keyspace.api_keys_by_user WHERE user_id = ? AND key_id = ?", userID, keyID).WithContext(ctx).Exec()
+}
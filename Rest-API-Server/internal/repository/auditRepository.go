@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/models"
+	"time"
+)
+
+type AuditRepository interface {
+	RecordAdminAction(ctx context.Context, action *models.AdminAction) error
+	AdminActionsByDay(ctx context.Context, day time.Time, page models.Page) ([]models.AdminAction, []byte, error)
+}
+
+type auditRepository struct {
+	session *gocql.Session
+}
+
+func NewAuditRepository(session *gocql.Session) AuditRepository {
+	return &auditRepository{session: session}
+}
+
+// Actions are partitioned by UTC day so one partition never grows unbounded.
+func auditDay(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+func (r *auditRepository) RecordAdminAction(ctx context.Context, action *models.AdminAction) error {
+	query := "INSERT INTO marketplace_keyspace.admin_actions(day, action_id, actor_id, actor_role, action, target_type, target_id, owner_id, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	return r.session.Query(query,
+		auditDay(action.CreatedAt),
+		action.ActionID,
+		action.ActorID,
+		action.ActorRole,
+		action.Action,
+		action.TargetType,
+		action.TargetID,
+		action.OwnerID,
+		action.CreatedAt,
+	).WithContext(ctx).Exec()
+}
+
+func (r *auditRepository) AdminActionsByDay(ctx context.Context, day time.Time, page models.Page) ([]models.AdminAction, []byte, error) {
+	query := "SELECT action_id, actor_id, actor_role, action, target_type, target_id, owner_id, created_at FROM marketplace_keyspace.admin_actions WHERE day = ?"
+	var action models.AdminAction
+	actions := []models.AdminAction{}
+	iter := pageIter(r.session.Query(query, auditDay(day)).WithContext(ctx), page)
+	defer iter.Close()
+	next := nextPageState(iter)
+	for iter.Scan(&action.ActionID, &action.ActorID, &action.ActorRole, &action.Action, &action.TargetType, &action.TargetID, &action.OwnerID, &action.CreatedAt) {
+		actions = append(actions, action)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, nil, err
+	}
+	return actions, next, nil
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/models"
+	"time"
+)
+
+// BlockRepository stores block lists in both directions: who a user blocked,
+// and who blocked them.
+type BlockRepository interface {
+	Block(ctx context.Context, blockerID, blockedID gocql.UUID, at time.Time) error
+	Unblock(ctx context.Context, blockerID, blockedID gocql.UUID) error
+	IsBlocked(ctx context.Context, blockerID, blockedID gocql.UUID) (bool, error)
+	ListBlocked(ctx context.Context, blockerID gocql.UUID, page models.Page) ([]gocql.UUID, []byte, error)
+	// AllBlocked returns every account blockerID has blocked.
+	AllBlocked(ctx context.Context, blockerID gocql.UUID) ([]gocql.UUID, error)
+	// AllBlockers returns every account that has blocked blockedID.
+	AllBlockers(ctx context.Context, blockedID gocql.UUID) ([]gocql.UUID, error)
+}
+
+type blockRepository struct {
+	session *gocql.Session
+}
+
+func NewBlockRepository(session *gocql.Session) BlockRepository {
+	return &blockRepository{session: session}
+}
+
+func (r *blockRepository) Block(ctx context.Context, blockerID, blockedID gocql.UUID, at time.Time) error {
+	query := "INSERT INTO marketplace_keyspace.user_blocks(blocker_id, blocked_id, blocked_at) VALUES (?, ?, ?)"
+	if err := r.session.Query(query, blockerID, blockedID, at).WithContext(ctx).Exec(); err != nil {
+		return err
+	}
+	query = "INSERT INTO marketplace_keyspace.user_blocked_by(blocked_id, blocker_id, blocked_at) VALUES (?, ?, ?)"
+	return r.session.Query(query, blockedID, blockerID, at).WithContext(ctx).Exec()
+}
+
+func (r *blockRepository) Unblock(ctx context.Context, blockerID, blockedID gocql.UUID) error {
+	query := "DELETE FROM marketplace_keyspace.user_blocks WHERE blocker_id = ? AND blocked_id = ?"
+	if err := r.session.Query(query, blockerID, blockedID).WithContext(ctx).Exec(); err != nil {
+		return err
+	}
+	query = "DELETE FROM marketplace_keyspace.user_blocked_by WHERE blocked_id = ? AND blocker_id = ?"
+	return r.session.Query(query, blockedID, blockerID).WithContext(ctx).Exec()
+}
+
+func (r *blockRepository) IsBlocked(ctx context.Context, blockerID, blockedID gocql.UUID) (bool, error) {
+	query := "SELECT blocked_id FROM marketplace_keyspace.user_blocks WHERE blocker_id = ? AND blocked_id = ?"
+	var id gocql.UUID
+	if err := r.session.Query(query, blockerID, blockedID).WithContext(ctx).Scan(&id); err != nil {
+		if errors.Is(err, gocql.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *blockRepository) ListBlocked(ctx context.Context, blockerID gocql.UUID, page models.Page) ([]gocql.UUID, []byte, error) {
+	query := "SELECT blocked_id FROM marketplace_keyspace.user_blocks WHERE blocker_id = ?"
+	return scanIDPage(pageIter(r.session.Query(query, blockerID).WithContext(ctx), page))
+}
+
+func (r *blockRepository) AllBlocked(ctx context.Context, blockerID gocql.UUID) ([]gocql.UUID, error) {
+	query := "SELECT blocked_id FROM marketplace_keyspace.user_blocks WHERE blocker_id = ?"
+	return scanIDs(r.session.Query(query, blockerID).WithContext(ctx).Iter())
+}
+
+func (r *blockRepository) AllBlockers(ctx context.Context, blockedID gocql.UUID) ([]gocql.UUID, error) {
+	query := "SELECT blocker_id FROM marketplace_keyspace.user_blocked_by WHERE blocked_id = ?"
+	return scanIDs(r.session.Query(query, blockedID).WithContext(ctx).Iter())
+}
+
+func scanIDs(iter *gocql.Iter) ([]gocql.UUID, error) {
+	var ids []gocql.UUID
+	var id gocql.UUID
+	for iter.Scan(&id) {
+		ids = append(ids, id)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// scanIDPage reads one page of ids and the state of the page after it.
+func scanIDPage(iter *gocql.Iter) ([]gocql.UUID, []byte, error) {
+	next := nextPageState(iter)
+	ids, err := scanIDs(iter)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ids, next, nil
+}
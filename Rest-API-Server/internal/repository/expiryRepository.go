@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/models"
+	"time"
+)
+
+// ExpiryRepository keeps the deadlines of active listings, partitioned by the
+// day they fall due so the scheduler can find the ones that have passed.
+type ExpiryRepository interface {
+	AddDeadline(ctx context.Context, deadline models.ListingDeadline) error
+	// DueDeadlines returns up to limit deadlines of day that are due at now,
+	// earliest first.
+	DueDeadlines(ctx context.Context, day string, now time.Time, limit int) ([]models.ListingDeadline, error)
+	RemoveDeadline(ctx context.Context, deadline models.ListingDeadline) error
+}
+
+type expiryRepository struct {
+	session *gocql.Session
+}
+
+func NewExpiryRepository(session *gocql.Session) ExpiryRepository {
+	return &expiryRepository{session: session}
+}
+
+func (r *expiryRepository) AddDeadline(ctx context.Context, deadline models.ListingDeadline) error {
+	query := "INSERT INTO marketplace_keyspace.listing_deadlines(day, due_at, product_id, kind, expires_at) VALUES (?, ?, ?, ?, ?)"
+	return r.session.Query(query,
+		models.DeadlineDay(deadline.DueAt),
+		deadline.DueAt,
+		deadline.ProductID,
+		deadline.Kind,
+		deadline.ExpiresAt,
+	).WithContext(ctx).Exec()
+}
+
+func (r *expiryRepository) DueDeadlines(ctx context.Context, day string, now time.Time, limit int) ([]models.ListingDeadline, error) {
+	query := "SELECT due_at, product_id, kind, expires_at FROM marketplace_keyspace.listing_deadlines WHERE day = ? AND due_at <= ? LIMIT ?"
+	iter := r.session.Query(query, day, now, limit).WithContext(ctx).Iter()
+
+	var deadlines []models.ListingDeadline
+	var deadline models.ListingDeadline
+	for iter.Scan(&deadline.DueAt, &deadline.ProductID, &deadline.Kind, &deadline.ExpiresAt) {
+		deadlines = append(deadlines, deadline)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return deadlines, nil
+}
+
+func (r *expiryRepository) RemoveDeadline(ctx context.Context, deadline models.ListingDeadline) error {
+	query := "DELETE FROM marketplace_keyspace.listing_deadlines WHERE day = ? AND due_at = ? AND product_id = ? AND kind = ?"
+	return r.session.Query(query, models.DeadlineDay(deadline.DueAt), deadline.DueAt, deadline.ProductID, deadline.Kind).WithContext(ctx).Exec()
+}
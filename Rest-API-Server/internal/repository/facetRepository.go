@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/models"
+)
+
+// FacetRepository reads what facet counts are computed from. The number of
+// active listings per filter value is kept up to date in
+// product_filter_counts by the product repository as filters are written and
+// deleted and as listings change status.
+type FacetRepository interface {
+	// FilterCounts returns the number of listings of the subcategory per
+	// filter name and value.
+	FilterCounts(ctx context.Context, categoryID, subcategoryID gocql.UUID) (models.Facets, error)
+	// ListingFilters returns the filter values of the listings of the
+	// subcategory that have any, whatever their status, reading at most
+	// maxRows filter rows. The bool is false when the limit cut the listings
+	// short.
+	ListingFilters(ctx context.Context, categoryID, subcategoryID gocql.UUID, maxRows int) (map[gocql.UUID]map[string][]string, bool, error)
+	// ListingPrices returns the price of each active listing of the
+	// subcategory, reading at most maxRows ranking rows. The bool is false
+	// when the limit cut the listings short.
+	ListingPrices(ctx context.Context, categoryID, subcategoryID gocql.UUID, maxRows int) (map[gocql.UUID]int, bool, error)
+}
+
+type facetRepository struct {
+	session *gocql.Session
+}
+
+func NewFacetRepository(session *gocql.Session) FacetRepository {
+	return &facetRepository{session: session}
+}
+
+func (r *facetRepository) FilterCounts(ctx context.Context, categoryID, subcategoryID gocql.UUID) (models.Facets, error) {
+	query := "SELECT filter_name, filter_value, listings FROM marketplace_keyspace.product_filter_counts WHERE category_id = ? AND sub_category_id = ?"
+	iter := r.session.Query(query, categoryID, subcategoryID).WithContext(ctx).Iter()
+
+	facets := models.Facets{}
+	var name, value string
+	var listings int64
+	for iter.Scan(&name, &value, &listings) {
+		if listings > 0 {
+			facets.Add(name, value, listings)
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return facets, nil
+}
+
+// ListingFilters reads the product_filters partition of every filter value
+// the subcategory has listings for.
+func (r *facetRepository) ListingFilters(ctx context.Context, categoryID, subcategoryID gocql.UUID, maxRows int) (map[gocql.UUID]map[string][]string, bool, error) {
+	counts, err := r.FilterCounts(ctx, categoryID, subcategoryID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	listings := make(map[gocql.UUID]map[string][]string)
+	rows := 0
+	query := "SELECT product_id FROM marketplace_keyspace.product_filters WHERE category_id = ? AND sub_category_id = ? AND filter_name = ? AND filter_value = ?"
+	for name, values := range counts {
+		for value := range values {
+			iter := r.session.Query(query, categoryID, subcategoryID, name, value).WithContext(ctx).PageSize(1000).Iter()
+			var productID gocql.UUID
+			for rows < maxRows && iter.Scan(&productID) {
+				rows++
+				if listings[productID] == nil {
+					listings[productID] = make(map[string][]string)
+				}
+				listings[productID][name] = append(listings[productID][name], value)
+			}
+			if err := iter.Close(); err != nil {
+				return nil, false, err
+			}
+			if rows >= maxRows {
+				return listings, false, nil
+			}
+		}
+	}
+	return listings, true, nil
+}
+
+// ListingPrices reads the price ranking of the subcategory, whose scores are
+// the prices.
+func (r *facetRepository) ListingPrices(ctx context.Context, categoryID, subcategoryID gocql.UUID, maxRows int) (map[gocql.UUID]int, bool, error) {
+	query := "SELECT product_id, score FROM marketplace_keyspace.product_rankings WHERE scope = ? AND ranking = ?"
+	iter := r.session.Query(query, subcategoryScope(categoryID, subcategoryID), rankingPrice).WithContext(ctx).PageSize(1000).Iter()
+
+	prices := make(map[gocql.UUID]int)
+	rows := 0
+	var productID gocql.UUID
+	var score int64
+	for rows < maxRows && iter.Scan(&productID, &score) {
+		rows++
+		prices[productID] = int(score)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, false, err
+	}
+	return prices, rows < maxRows, nil
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/models"
+	"time"
+)
+
+// FavoriteRepository stores the listings each user has saved.
+type FavoriteRepository interface {
+	// AddFavorite reports whether the listing was not already a favorite.
+	AddFavorite(ctx context.Context, userID, productID gocql.UUID, at time.Time) (bool, error)
+	RemoveFavorite(ctx context.Context, userID, productID gocql.UUID) error
+	ListFavorites(ctx context.Context, userID gocql.UUID, page models.Page) ([]gocql.UUID, []byte, error)
+}
+
+type favoriteRepository struct {
+	session *gocql.Session
+}
+
+func NewFavoriteRepository(session *gocql.Session) FavoriteRepository {
+	return &favoriteRepository{session: session}
+}
+
+func (r *favoriteRepository) AddFavorite(ctx context.Context, userID, productID gocql.UUID, at time.Time) (bool, error) {
+	query := "INSERT INTO marketplace_keyspace.favorites(user_id, product_id, created_at) VALUES (?, ?, ?) IF NOT EXISTS"
+	return r.session.Query(query, userID, productID, at).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+}
+
+func (r *favoriteRepository) RemoveFavorite(ctx context.Context, userID, productID gocql.UUID) error {
+	query := "DELETE FROM marketplace_keyspace.favorites WHERE user_id = ? AND product_id = ?"
+	return r.session.Query(query, userID, productID).WithContext(ctx).Exec()
+}
+
+func (r *favoriteRepository) ListFavorites(ctx context.Context, userID gocql.UUID, page models.Page) ([]gocql.UUID, []byte, error) {
+	query := "SELECT product_id FROM marketplace_keyspace.favorites WHERE user_id = ?"
+	return scanIDPage(pageIter(r.session.Query(query, userID).WithContext(ctx), page))
+}
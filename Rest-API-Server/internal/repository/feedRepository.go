@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/models"
+	"time"
+)
+
+// FeedRepository keeps a timeline per user with the listings of the sellers
+// they follow, written when a listing is published. Reading a feed is then a
+// single-partition query however many sellers the user follows.
+type FeedRepository interface {
+	AddToTimeline(ctx context.Context, userID gocql.UUID, item models.FeedItem, ttl time.Duration) error
+	RemoveFromTimeline(ctx context.Context, userID gocql.UUID, productID gocql.UUID) error
+	// RemoveSellerFromTimeline deletes every listing of sellerID from the
+	// timeline of userID.
+	RemoveSellerFromTimeline(ctx context.Context, userID, sellerID gocql.UUID) error
+	Timeline(ctx context.Context, userID gocql.UUID, page models.Page) ([]models.FeedItem, []byte, error)
+}
+
+type feedRepository struct {
+	session *gocql.Session
+}
+
+func NewFeedRepository(session *gocql.Session) FeedRepository {
+	return &feedRepository{session: session}
+}
+
+func (r *feedRepository) AddToTimeline(ctx context.Context, userID gocql.UUID, item models.FeedItem, ttl time.Duration) error {
+	query := "INSERT INTO marketplace_keyspace.feed_timeline(user_id, product_id, seller_id, title, image, price) VALUES (?, ?, ?, ?, ?, ?) USING TTL ?"
+	return r.session.Query(query, userID, item.ProductID, item.SellerID, item.Title, item.Image, item.Price, int(ttl.Seconds())).WithContext(ctx).Exec()
+}
+
+func (r *feedRepository) RemoveFromTimeline(ctx context.Context, userID gocql.UUID, productID gocql.UUID) error {
+	query := "DELETE FROM marketplace_keyspace.feed_timeline WHERE user_id = ? AND product_id = ?"
+	return r.session.Query(query, userID, productID).WithContext(ctx).Exec()
+}
+
+// RemoveSellerFromTimeline reads the seller's rows from the single timeline
+// partition, which the item TTL keeps small, and deletes them in one batch.
+func (r *feedRepository) RemoveSellerFromTimeline(ctx context.Context, userID, sellerID gocql.UUID) error {
+	query := "SELECT product_id FROM marketplace_keyspace.feed_timeline WHERE user_id = ? AND seller_id = ? ALLOW FILTERING"
+	iter := r.session.Query(query, userID, sellerID).WithContext(ctx).Iter()
+	batch := r.session.NewBatch(gocql.UnloggedBatch).WithContext(ctx)
+	var productID gocql.UUID
+	for iter.Scan(&productID) {
+		batch.Query("DELETE FROM marketplace_keyspace.feed_timeline WHERE user_id = ? AND product_id = ?", userID, productID)
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+	if batch.Size() == 0 {
+		return nil
+	}
+	return r.session.ExecuteBatch(batch)
+}
+
+// Timeline returns a page of the feed, newest first.
+func (r *feedRepository) Timeline(ctx context.Context, userID gocql.UUID, page models.Page) ([]models.FeedItem, []byte, error) {
+	query := "SELECT product_id, seller_id, title, image, price FROM marketplace_keyspace.feed_timeline WHERE user_id = ?"
+	iter := pageIter(r.session.Query(query, userID).WithContext(ctx), page)
+	next := nextPageState(iter)
+	items := []models.FeedItem{}
+	var item models.FeedItem
+	for iter.Scan(&item.ProductID, &item.SellerID, &item.Title, &item.Image, &item.Price) {
+		items = append(items, item)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, nil, err
+	}
+	return items, next, nil
+}
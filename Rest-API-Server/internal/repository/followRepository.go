@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/models"
+	"time"
+)
+
+// FollowRepository stores who follows whom in both directions, so followers
+// and followed accounts can each be listed from one partition.
+type FollowRepository interface {
+	Follow(ctx context.Context, followerID, sellerID gocql.UUID, at time.Time) error
+	Unfollow(ctx context.Context, followerID, sellerID gocql.UUID) error
+	IsFollowing(ctx context.Context, followerID, sellerID gocql.UUID) (bool, error)
+	ListFollowers(ctx context.Context, sellerID gocql.UUID, page models.Page) ([]gocql.UUID, []byte, error)
+	ListFollowing(ctx context.Context, followerID gocql.UUID, page models.Page) ([]gocql.UUID, []byte, error)
+	// AllFollowers walks every follower of sellerID, for fanning out new
+	// listings.
+	AllFollowers(ctx context.Context, sellerID gocql.UUID, fn func(followerID gocql.UUID) error) error
+}
+
+type followRepository struct {
+	session *gocql.Session
+}
+
+func NewFollowRepository(session *gocql.Session) FollowRepository {
+	return &followRepository{session: session}
+}
+
+func (r *followRepository) Follow(ctx context.Context, followerID, sellerID gocql.UUID, at time.Time) error {
+	query := "INSERT INTO marketplace_keyspace.following(follower_id, seller_id, followed_at) VALUES (?, ?, ?)"
+	if err := r.session.Query(query, followerID, sellerID, at).WithContext(ctx).Exec(); err != nil {
+		return err
+	}
+	query = "INSERT INTO marketplace_keyspace.followers(seller_id, follower_id, followed_at) VALUES (?, ?, ?)"
+	return r.session.Query(query, sellerID, followerID, at).WithContext(ctx).Exec()
+}
+
+func (r *followRepository) Unfollow(ctx context.Context, followerID, sellerID gocql.UUID) error {
+	query := "DELETE FROM marketplace_keyspace.following WHERE follower_id = ? AND seller_id = ?"
+	if err := r.session.Query(query, followerID, sellerID).WithContext(ctx).Exec(); err != nil {
+		return err
+	}
+	query = "DELETE FROM marketplace_keyspace.followers WHERE seller_id = ? AND follower_id = ?"
+	return r.session.Query(query, sellerID, followerID).WithContext(ctx).Exec()
+}
+
+func (r *followRepository) IsFollowing(ctx context.Context, followerID, sellerID gocql.UUID) (bool, error) {
+	query := "SELECT seller_id FROM marketplace_keyspace.following WHERE follower_id = ? AND seller_id = ?"
+	var id gocql.UUID
+	if err := r.session.Query(query, followerID, sellerID).WithContext(ctx).Scan(&id); err != nil {
+		if errors.Is(err, gocql.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *followRepository) ListFollowers(ctx context.Context, sellerID gocql.UUID, page models.Page) ([]gocql.UUID, []byte, error) {
+	query := "SELECT follower_id FROM marketplace_keyspace.followers WHERE seller_id = ?"
+	return scanIDPage(pageIter(r.session.Query(query, sellerID).WithContext(ctx), page))
+}
+
+func (r *followRepository) ListFollowing(ctx context.Context, followerID gocql.UUID, page models.Page) ([]gocql.UUID, []byte, error) {
+	query := "SELECT seller_id FROM marketplace_keyspace.following WHERE follower_id = ?"
+	return scanIDPage(pageIter(r.session.Query(query, followerID).WithContext(ctx), page))
+}
+
+func (r *followRepository) AllFollowers(ctx context.Context, sellerID gocql.UUID, fn func(followerID gocql.UUID) error) error {
+	query := "SELECT follower_id FROM marketplace_keyspace.followers WHERE seller_id = ?"
+	iter := r.session.Query(query, sellerID).WithContext(ctx).PageSize(500).Iter()
+	var followerID gocql.UUID
+	for iter.Scan(&followerID) {
+		if err := fn(followerID); err != nil {
+			iter.Close()
+			return err
+		}
+	}
+	return iter.Close()
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/utils"
+)
+
+// ImageRepository records uploaded images. The files themselves are kept in
+// a blob store under the keys recorded here.
+type ImageRepository interface {
+	AddImage(ctx context.Context, image *models.Image) error
+	GetImage(ctx context.Context, imageID gocql.UUID) (*models.Image, error)
+}
+
+type imageRepository struct {
+	session *gocql.Session
+}
+
+func NewImageRepository(session *gocql.Session) ImageRepository {
+	return &imageRepository{session: session}
+}
+
+func (r *imageRepository) AddImage(ctx context.Context, image *models.Image) error {
+	query := "INSERT INTO marketplace_keyspace.images(image_id, owner_id, width, height, variant_keys, created_at) VALUES (?, ?, ?, ?, ?, ?)"
+	return r.session.Query(query, image.ImageID, image.OwnerID, image.Width, image.Height, image.Keys, image.CreatedAt).WithContext(ctx).Exec()
+}
+
+func (r *imageRepository) GetImage(ctx context.Context, imageID gocql.UUID) (*models.Image, error) {
+	image := &models.Image{}
+	query := "SELECT image_id, owner_id, width, height, variant_keys, created_at FROM marketplace_keyspace.images WHERE image_id = ?"
+	if err := r.session.Query(query, imageID).WithContext(ctx).Scan(&image.ImageID, &image.OwnerID, &image.Width, &image.Height, &image.Keys, &image.CreatedAt); err != nil {
+		if errors.Is(err, gocql.ErrNotFound) {
+			return nil, utils.ErrNotFound
+		}
+		return nil, err
+	}
+	return image, nil
+}
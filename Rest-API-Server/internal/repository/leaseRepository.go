@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"github.com/gocql/gocql"
+	"time"
+)
+
+// LeaseRepository hands out named leases so that work done by every API
+// replica, such as scheduled jobs, runs on one of them at a time. A lease
+// lapses when its holder stops renewing it.
+type LeaseRepository interface {
+	// Acquire takes the lease for holder, or extends it if holder already
+	// has it, for ttl. It reports whether holder has the lease.
+	Acquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+	// Release gives up the lease if holder has it.
+	Release(ctx context.Context, name, holder string) error
+}
+
+type leaseRepository struct {
+	session *gocql.Session
+}
+
+func NewLeaseRepository(session *gocql.Session) LeaseRepository {
+	return &leaseRepository{session: session}
+}
+
+func (r *leaseRepository) Acquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	seconds := int(ttl.Seconds())
+	query := "UPDATE marketplace_keyspace.scheduler_leases USING TTL ? SET holder = ? WHERE name = ? IF holder = ?"
+	applied, err := r.session.Query(query, seconds, holder, name, holder).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+	if err != nil || applied {
+		return applied, err
+	}
+	query = "INSERT INTO marketplace_keyspace.scheduler_leases(name, holder) VALUES (?, ?) IF NOT EXISTS USING TTL ?"
+	return r.session.Query(query, name, holder, seconds).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+}
+
+func (r *leaseRepository) Release(ctx context.Context, name, holder string) error {
+	query := "DELETE FROM marketplace_keyspace.scheduler_leases WHERE name = ? IF holder = ?"
+	_, err := r.session.Query(query, name, holder).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+	return err
+}
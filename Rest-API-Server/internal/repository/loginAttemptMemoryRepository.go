@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"marketplace_project/internal/models"
+	"sync"
+	"time"
+)
+
+type memoryAttempts struct {
+	attempts  models.LoginAttempts
+	expiresAt time.Time
+}
+
+type loginAttemptMemoryRepository struct {
+	mu      sync.Mutex
+	entries map[string]*memoryAttempts
+}
+
+// NewLoginAttemptMemoryRepository returns a store that lives in the process.
+// It suits a single API instance and local development.
+func NewLoginAttemptMemoryRepository() LoginAttemptRepository {
+	return &loginAttemptMemoryRepository{entries: make(map[string]*memoryAttempts)}
+}
+
+func (r *loginAttemptMemoryRepository) GetAttempts(ctx context.Context, key string) (*models.LoginAttempts, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry := r.entry(key, time.Now())
+	if entry == nil {
+		return &models.LoginAttempts{}, nil
+	}
+	attempts := entry.attempts
+	return &attempts, nil
+}
+
+func (r *loginAttemptMemoryRepository) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*models.LoginAttempts, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry := r.entry(key, now)
+	if entry == nil {
+		entry = &memoryAttempts{}
+		r.entries[key] = entry
+	}
+	entry.attempts.Failures++
+	entry.attempts.LastFailure = now
+	if expiresAt := now.Add(window); expiresAt.After(entry.expiresAt) {
+		entry.expiresAt = expiresAt
+	}
+	attempts := entry.attempts
+	return &attempts, nil
+}
+
+func (r *loginAttemptMemoryRepository) Lock(ctx context.Context, key string, until time.Time, retain time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry := r.entry(key, time.Now())
+	if entry == nil {
+		entry = &memoryAttempts{}
+		r.entries[key] = entry
+	}
+	entry.attempts.LockedUntil = until
+	if expiresAt := until.Add(retain); expiresAt.After(entry.expiresAt) {
+		entry.expiresAt = expiresAt
+	}
+	return nil
+}
+
+func (r *loginAttemptMemoryRepository) Reset(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.entries, key)
+	return nil
+}
+
+// entry returns the live entry for key, dropping it when it has expired.
+// The caller must hold r.mu.
+func (r *loginAttemptMemoryRepository) entry(key string, now time.Time) *memoryAttempts {
+	entry, ok := r.entries[key]
+	if !ok {
+		return nil
+	}
+	if now.After(entry.expiresAt) {
+		delete(r.entries, key)
+		return nil
+	}
+	return entry
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/models"
+	"time"
+)
+
+// LoginAttemptRepository keeps failed sign-in counters. Keys identify either
+// an account or a client IP. Entries expire on their own once window has
+// passed without new failures; a lock is kept for retain after it ends, so an
+// expired lock can still be noticed and reported.
+type LoginAttemptRepository interface {
+	GetAttempts(ctx context.Context, key string) (*models.LoginAttempts, error)
+	RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*models.LoginAttempts, error)
+	Lock(ctx context.Context, key string, until time.Time, retain time.Duration) error
+	Reset(ctx context.Context, key string) error
+}
+
+// maxCASRetries bounds the compare-and-set loop when many instances record
+// failures for the same key at once.
+const maxCASRetries = 5
+
+type loginAttemptRepository struct {
+	session *gocql.Session
+}
+
+// NewLoginAttemptRepository returns a Cassandra-backed store shared by every
+// API instance.
+func NewLoginAttemptRepository(session *gocql.Session) LoginAttemptRepository {
+	return &loginAttemptRepository{session: session}
+}
+
+func (r *loginAttemptRepository) GetAttempts(ctx context.Context, key string) (*models.LoginAttempts, error) {
+	query := "SELECT failures, last_failure, locked_until FROM marketplace_keyspace.login_attempts WHERE key = ?"
+	var attempts models.LoginAttempts
+	if err := r.session.Query(query, key).WithContext(ctx).Scan(&attempts.Failures, &attempts.LastFailure, &attempts.LockedUntil); err != nil {
+		if errors.Is(err, gocql.ErrNotFound) {
+			return &models.LoginAttempts{}, nil
+		}
+		return nil, err
+	}
+	return &attempts, nil
+}
+
+// RecordFailure increments the counter with lightweight transactions, since
+// counter columns cannot expire. The read value is used as the condition, so
+// concurrent failures from other instances are never lost.
+func (r *loginAttemptRepository) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*models.LoginAttempts, error) {
+	ttl := int(window.Seconds())
+	for i := 0; i < maxCASRetries; i++ {
+		var failures *int
+		var lockedUntil time.Time
+		err := r.session.Query("SELECT failures, locked_until FROM marketplace_keyspace.login_attempts WHERE key = ?", key).WithContext(ctx).Scan(&failures, &lockedUntil)
+
+		var applied bool
+		switch {
+		case errors.Is(err, gocql.ErrNotFound):
+			query := "INSERT INTO marketplace_keyspace.login_attempts(key, failures, last_failure) VALUES (?, 1, ?) IF NOT EXISTS USING TTL ?"
+			applied, err = r.session.Query(query, key, now, ttl).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+		case err == nil:
+			next := 1
+			if failures != nil {
+				next = *failures + 1
+			}
+			query := "UPDATE marketplace_keyspace.login_attempts USING TTL ? SET failures = ?, last_failure = ? WHERE key = ? IF failures = ?"
+			applied, err = r.session.Query(query, ttl, next, now, key, failures).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+		}
+		if err != nil {
+			return nil, err
+		}
+		if applied {
+			count := 1
+			if failures != nil {
+				count = *failures + 1
+			}
+			return &models.LoginAttempts{Failures: count, LastFailure: now, LockedUntil: lockedUntil}, nil
+		}
+	}
+	return nil, fmt.Errorf("record failed attempt for %s: too much contention", key)
+}
+
+func (r *loginAttemptRepository) Lock(ctx context.Context, key string, until time.Time, retain time.Duration) error {
+	ttl := int(time.Until(until.Add(retain)).Seconds()) + 1
+	query := "UPDATE marketplace_keyspace.login_attempts USING TTL ? SET locked_until = ? WHERE key = ?"
+	return r.session.Query(query, ttl, until, key).WithContext(ctx).Exec()
+}
+
+func (r *loginAttemptRepository) Reset(ctx context.Context, key string) error {
+	query := "DELETE FROM marketplace_keyspace.login_attempts WHERE key = ?"
+	return r.session.Query(query, key).WithContext(ctx).Exec()
+}
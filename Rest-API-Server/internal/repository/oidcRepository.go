@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/utils"
+	"time"
+)
+
+type OIDCRepository interface {
+	SaveLoginState(ctx context.Context, state *models.OIDCLoginState, ttl time.Duration) error
+	ConsumeLoginState(ctx context.Context, state string) (*models.OIDCLoginState, error)
+	GetIdentityUser(ctx context.Context, provider, subject string) (gocql.UUID, error)
+	LinkIdentity(ctx context.Context, provider, subject string, userID gocql.UUID) (bool, error)
+	UnlinkIdentity(ctx context.Context, provider, subject string) error
+}
+
+type oidcRepository struct {
+	session *gocql.Session
+}
+
+func NewOIDCRepository(session *gocql.Session) OIDCRepository {
+	return &oidcRepository{session: session}
+}
+
+func (r *oidcRepository) SaveLoginState(ctx context.Context, state *models.OIDCLoginState, ttl time.Duration) error {
+	query := "INSERT INTO marketplace_keyspace.oidc_login_states(state, provider, code_verifier, nonce) VALUES (?, ?, ?, ?) USING TTL ?"
+	return r.session.Query(query, state.State, state.Provider, state.CodeVerifier, state.Nonce, int(ttl.Seconds())).WithContext(ctx).Exec()
+}
+
+// ConsumeLoginState returns the stored state and deletes it with a
+// lightweight transaction, so a callback can be completed only once.
+func (r *oidcRepository) ConsumeLoginState(ctx context.Context, state string) (*models.OIDCLoginState, error) {
+	query := "SELECT state, provider, code_verifier, nonce FROM marketplace_keyspace.oidc_login_states WHERE state = ?"
+	var loginState models.OIDCLoginState
+	if err := r.session.Query(query, state).WithContext(ctx).Scan(&loginState.State, &loginState.Provider, &loginState.CodeVerifier, &loginState.Nonce); err != nil {
+		if errors.Is(err, gocql.ErrNotFound) {
+			return nil, utils.ErrNotFound
+		}
+		return nil, err
+	}
+
+	query = "DELETE FROM marketplace_keyspace.oidc_login_states WHERE state = ? IF EXISTS"
+	applied, err := r.session.Query(query, state).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	if !applied {
+		return nil, utils.ErrNotFound
+	}
+	return &loginState, nil
+}
+
+func (r *oidcRepository) GetIdentityUser(ctx context.Context, provider, subject string) (gocql.UUID, error) {
+	query := "SELECT user_id FROM marketplace_keyspace.user_identities WHERE provider = ? AND subject = ?"
+	var userID gocql.UUID
+	if err := r.session.Query(query, provider, subject).WithContext(ctx).Scan(&userID); err != nil {
+		if errors.Is(err, gocql.ErrNotFound) {
+			return gocql.UUID{}, utils.ErrNotFound
+		}
+		return gocql.UUID{}, err
+	}
+	return userID, nil
+}
+
+// LinkIdentity records that the provider account belongs to userID. It
+// reports false when the identity is already linked.
+func (r *oidcRepository) LinkIdentity(ctx context.Context, provider, subject string, userID gocql.UUID) (bool, error) {
+	query := "INSERT INTO marketplace_keyspace.user_identities(provider, subject, user_id, linked_at) VALUES (?, ?, ?, ?) IF NOT EXISTS"
+	return r.session.Query(query, provider, subject, userID, time.Now()).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+}
+
+func (r *oidcRepository) UnlinkIdentity(ctx context.Context, provider, subject string) error {
+	query := "DELETE FROM marketplace_keyspace.user_identities WHERE provider = ? AND subject = ?"
+	return r.session.Query(query, provider, subject).WithContext(ctx).Exec()
+}
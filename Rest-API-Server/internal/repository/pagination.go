@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/models"
+)
+
+// pageIter runs query for a single page. Setting the page state, even a nil
+// one, turns off gocql's automatic fetching of further pages, so the iterator
+// stops after page.Limit rows and PageState tells where to continue.
+func pageIter(query *gocql.Query, page models.Page) *gocql.Iter {
+	return query.PageSize(page.Limit).PageState(page.State).Iter()
+}
+
+// nextPageState returns the state of the page after the one iter read, or
+// nil when there is none.
+func nextPageState(iter *gocql.Iter) []byte {
+	state := iter.PageState()
+	if len(state) == 0 {
+		return nil
+	}
+	return state
+}
+
+// maxFillRounds bounds how many pages fillPage reads for one response.
+const maxFillRounds = 10
+
+// fillPage reads the results of newQuery page by page and hands each page to
+// scan, which returns how many rows it kept. It stops once page.Limit rows
+// were kept, the results run out or maxFillRounds pages were read, and at
+// the first error of scan or of a read. Each read asks only for as many rows
+// as are still missing, so the returned state never skips a row; a short
+// page with a state just means the caller should continue.
+func fillPage(newQuery func() *gocql.Query, page models.Page, scan func(iter *gocql.Iter) (int, error)) ([]byte, error) {
+	state := page.State
+	kept := 0
+	for round := 0; ; round++ {
+		iter := pageIter(newQuery(), models.Page{Limit: page.Limit - kept, State: state})
+		state = nextPageState(iter)
+		n, scanErr := scan(iter)
+		if err := iter.Close(); err != nil {
+			return nil, err
+		}
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		kept += n
+		if kept >= page.Limit || state == nil || round+1 == maxFillRounds {
+			return state, nil
+		}
+	}
+}
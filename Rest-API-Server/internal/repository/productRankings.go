@@ -0,0 +1,373 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/models"
+	"net/url"
+	"strings"
+)
+
+// Sorted listings are read from product_rankings, which holds one row per
+// scope an active listing appears in (its category, its subcategory, each title
+// keyword and each filter value) and per ranking, clustered by the listing's score in that ranking.
+// product_ranks keeps the current score of each listing, so that the rows can
+// be moved when a score changes and rows left behind by concurrent moves can
+// be told apart from current ones when reading.
+const (
+	rankingCreated   = "created"
+	rankingPrice     = "price"
+	rankingViews     = "views"
+	rankingRelevance = "relevance"
+)
+
+// maxScoreMoves bounds how often moveViewsScore retries after losing a race
+// with another view of the same listing.
+const maxScoreMoves = 5
+
+func categoryScope(categoryID gocql.UUID) string {
+	return "category:" + categoryID.String()
+}
+
+func subcategoryScope(categoryID, subcategoryID gocql.UUID) string {
+	return "subcategory:" + categoryID.String() + ":" + subcategoryID.String()
+}
+
+func sellerScope(ownerID gocql.UUID) string {
+	return "seller:" + ownerID.String()
+}
+
+func keywordScope(keyword string) string {
+	return "keyword:" + keyword
+}
+
+func filterScope(categoryID, subcategoryID gocql.UUID, filter models.Filter) string {
+	return "filter:" + categoryID.String() + ":" + subcategoryID.String() + ":" + url.QueryEscape(filter.Name) + "=" + url.QueryEscape(filter.Value)
+}
+
+// listingOrder returns the ranking a sort option reads and whether it is read
+// from the highest score down.
+func listingOrder(sort models.ListingSort) (string, bool) {
+	switch sort {
+	case models.SortOldest:
+		return rankingCreated, false
+	case models.SortPriceAsc:
+		return rankingPrice, false
+	case models.SortPriceDesc:
+		return rankingPrice, true
+	case models.SortMostViewed:
+		return rankingViews, true
+	case models.SortRelevance:
+		return rankingRelevance, true
+	default:
+		return rankingCreated, true
+	}
+}
+
+func productScopes(product *models.Product, filters []models.Filter) []string {
+	scopes := []string{categoryScope(product.CategoryID), subcategoryScope(product.CategoryID, product.SubcategoryID), sellerScope(product.OwnerID)}
+	for _, keyword := range product.Keywords {
+		scopes = append(scopes, keywordScope(keyword))
+	}
+	for _, filter := range filters {
+		scopes = append(scopes, filterScope(product.CategoryID, product.SubcategoryID, filter))
+	}
+	return scopes
+}
+
+// productScores returns the score of the listing in every ranking. Relevance
+// is higher for titles with fewer words: every result of a search carries all
+// searched words, so a shorter title means a closer match.
+func productScores(product *models.Product, views int64) map[string]int64 {
+	return map[string]int64{
+		rankingCreated:   product.CreatedAt.UnixMilli(),
+		rankingPrice:     int64(product.Price),
+		rankingViews:     views,
+		rankingRelevance: -int64(len(product.Keywords)),
+	}
+}
+
+// scopeHasRanking reports whether listings of scope are ranked by ranking.
+// Relevance only means something for keyword scopes, and a seller's listings
+// are only read newest first, to fill the feed of a new follower.
+func scopeHasRanking(scope, ranking string) bool {
+	if strings.HasPrefix(scope, "seller:") {
+		return ranking == rankingCreated
+	}
+	return ranking != rankingRelevance || strings.HasPrefix(scope, "keyword:")
+}
+
+// indexRankings replaces the ranking rows of the listing with ones for its
+// current scopes and scores. The view score is carried over.
+func (r *productRepository) indexRankings(ctx context.Context, product *models.Product, filters []models.Filter) error {
+	var views int64
+	query := "SELECT views FROM marketplace_keyspace.product_views WHERE product_id = ?"
+	if err := r.session.Query(query, product.ProductID).WithContext(ctx).Scan(&views); err != nil && !errors.Is(err, gocql.ErrNotFound) {
+		return err
+	}
+	if err := r.removeRankings(ctx, product.ProductID); err != nil {
+		return err
+	}
+
+	scopes := productScopes(product, filters)
+	scores := productScores(product, views)
+	query = "UPDATE marketplace_keyspace.product_ranks SET scopes = ? WHERE product_id = ?"
+	if err := r.session.Query(query, scopes, product.ProductID).WithContext(ctx).Exec(); err != nil {
+		return err
+	}
+	for ranking, score := range scores {
+		query = "INSERT INTO marketplace_keyspace.product_ranks(product_id, ranking, score) VALUES (?, ?, ?)"
+		if err := r.session.Query(query, product.ProductID, ranking, score).WithContext(ctx).Exec(); err != nil {
+			return err
+		}
+		for _, scope := range scopes {
+			if !scopeHasRanking(scope, ranking) {
+				continue
+			}
+			query = "INSERT INTO marketplace_keyspace.product_rankings(scope, ranking, score, product_id) VALUES (?, ?, ?, ?)"
+			if err := r.session.Query(query, scope, ranking, score, product.ProductID).WithContext(ctx).Exec(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// removeRankings deletes every ranking row of the listing.
+func (r *productRepository) removeRankings(ctx context.Context, productID gocql.UUID) error {
+	query := "SELECT scopes, ranking, score FROM marketplace_keyspace.product_ranks WHERE product_id = ?"
+	iter := r.session.Query(query, productID).WithContext(ctx).Iter()
+	var scopes []string
+	var ranking string
+	var score int64
+	for iter.Scan(&scopes, &ranking, &score) {
+		for _, scope := range scopes {
+			if !scopeHasRanking(scope, ranking) {
+				continue
+			}
+			query = "DELETE FROM marketplace_keyspace.product_rankings WHERE scope = ? AND ranking = ? AND score = ? AND product_id = ?"
+			if err := r.session.Query(query, scope, ranking, score, productID).WithContext(ctx).Exec(); err != nil {
+				iter.Close()
+				return err
+			}
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+	return r.session.Query("DELETE FROM marketplace_keyspace.product_ranks WHERE product_id = ?", productID).WithContext(ctx).Exec()
+}
+
+// moveViewsScore moves the listing up the views ranking to views. Views are
+// counted concurrently, so the stored score is claimed with a lightweight
+// transaction and only the winner moves the rows; a lower count than the
+// stored one is ignored.
+func (r *productRepository) moveViewsScore(ctx context.Context, productID gocql.UUID, views int64) error {
+	for attempt := 0; attempt < maxScoreMoves; attempt++ {
+		var scopes []string
+		var score int64
+		query := "SELECT scopes, score FROM marketplace_keyspace.product_ranks WHERE product_id = ? AND ranking = ?"
+		if err := r.session.Query(query, productID, rankingViews).WithContext(ctx).Scan(&scopes, &score); err != nil {
+			if errors.Is(err, gocql.ErrNotFound) {
+				return nil
+			}
+			return err
+		}
+		if score >= views {
+			return nil
+		}
+
+		query = "UPDATE marketplace_keyspace.product_ranks SET score = ? WHERE product_id = ? AND ranking = ? IF score = ?"
+		applied, err := r.session.Query(query, views, productID, rankingViews, score).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+		if err != nil {
+			return err
+		}
+		if !applied {
+			continue
+		}
+		for _, scope := range scopes {
+			if !scopeHasRanking(scope, rankingViews) {
+				continue
+			}
+			query = "INSERT INTO marketplace_keyspace.product_rankings(scope, ranking, score, product_id) VALUES (?, ?, ?, ?)"
+			if err := r.session.Query(query, scope, rankingViews, views, productID).WithContext(ctx).Exec(); err != nil {
+				return err
+			}
+			query = "DELETE FROM marketplace_keyspace.product_rankings WHERE scope = ? AND ranking = ? AND score = ? AND product_id = ?"
+			if err := r.session.Query(query, scope, rankingViews, score, productID).WithContext(ctx).Exec(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// rankedListing is a listing read while paging through a ranking. Filters
+// holds the values of each of its filters by name and is only loaded when
+// asked for.
+type rankedListing struct {
+	models.ProductWrapContent
+	OwnerID       gocql.UUID
+	CategoryID    gocql.UUID
+	SubcategoryID gocql.UUID
+	Keywords      []string
+	Filters       map[string][]string
+}
+
+// rankingRow is a row of product_rankings.
+type rankingRow struct {
+	productID gocql.UUID
+	score     int64
+}
+
+// rankedProducts returns a page of the listings of scope in the given order,
+// keeping those keep accepts. Rows whose score is no longer the listing's
+// current one, or whose listing is gone, are skipped. The listings of each
+// batch of rows are loaded together, with their filters when withFilters is
+// set.
+func (r *productRepository) rankedProducts(ctx context.Context, scope string, sort models.ListingSort, page models.Page, withFilters bool, keep func(listing *rankedListing) (bool, error)) ([]models.ProductWrapContent, []byte, error) {
+	ranking, descending := listingOrder(sort)
+	query := "SELECT product_id, score FROM marketplace_keyspace.product_rankings WHERE scope = ? AND ranking = ?"
+	if !descending {
+		query += " ORDER BY score ASC, product_id ASC"
+	}
+
+	products := []models.ProductWrapContent{}
+	next, err := fillPage(func() *gocql.Query {
+		return r.session.Query(query, scope, ranking).WithContext(ctx)
+	}, page, func(iter *gocql.Iter) (int, error) {
+		var rows []rankingRow
+		var row rankingRow
+		for iter.Scan(&row.productID, &row.score) {
+			rows = append(rows, row)
+		}
+		listings, err := r.rankedListings(ctx, rows, ranking, withFilters)
+		if err != nil {
+			return 0, err
+		}
+		kept := 0
+		for _, listing := range listings {
+			ok, err := keep(listing)
+			if err != nil {
+				return kept, err
+			}
+			if ok {
+				products = append(products, listing.ProductWrapContent)
+				kept++
+			}
+		}
+		return kept, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return products, next, nil
+}
+
+// rankedListings loads the listings of ranking rows, in the order of the
+// rows, with one query per table. Rows that are out of date are left out.
+func (r *productRepository) rankedListings(ctx context.Context, rows []rankingRow, ranking string, withFilters bool) ([]*rankedListing, error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	productIDs := make([]gocql.UUID, len(rows))
+	for i, row := range rows {
+		productIDs[i] = row.productID
+	}
+
+	scores := make(map[gocql.UUID]int64, len(rows))
+	iter := r.session.Query("SELECT product_id, score FROM marketplace_keyspace.product_ranks WHERE product_id IN ? AND ranking = ?", productIDs, ranking).WithContext(ctx).Iter()
+	var productID gocql.UUID
+	var score int64
+	for iter.Scan(&productID, &score) {
+		scores[productID] = score
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+
+	listings := make(map[gocql.UUID]*rankedListing, len(rows))
+	query := "SELECT product_id, title, image, price, owner_id, category_id, subcategory_id, keywords, status FROM marketplace_keyspace.product_by_id WHERE product_id IN ?"
+	iter = r.session.Query(query, productIDs).WithContext(ctx).Iter()
+	for {
+		listing := &rankedListing{}
+		var images []string
+		var status string
+		if !iter.Scan(
+			&listing.ProductID,
+			&listing.Title,
+			&images,
+			&listing.Price,
+			&listing.OwnerID,
+			&listing.CategoryID,
+			&listing.SubcategoryID,
+			&listing.Keywords,
+			&status,
+		) {
+			break
+		}
+		// Rankings only hold active listings, but a status change may be
+		// halfway through.
+		if !models.ListingStatusOf(status).Listed() {
+			continue
+		}
+		listing.Image = firstImage(images)
+		listings[listing.ProductID] = listing
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+
+	if withFilters {
+		if err := r.loadFilters(ctx, productIDs, listings); err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]*rankedListing, 0, len(rows))
+	for _, row := range rows {
+		current, ok := scores[row.productID]
+		listing := listings[row.productID]
+		if !ok || current != row.score || listing == nil {
+			continue
+		}
+		result = append(result, listing)
+	}
+	return result, nil
+}
+
+// loadFilters sets the filter values of the listings, keeping only those of
+// each listing's own subcategory.
+func (r *productRepository) loadFilters(ctx context.Context, productIDs []gocql.UUID, listings map[gocql.UUID]*rankedListing) error {
+	for _, listing := range listings {
+		listing.Filters = make(map[string][]string)
+	}
+	query := "SELECT product_id, category_id, sub_category_id, filter_name, filter_value FROM marketplace_keyspace.product_filters_by_id WHERE product_id IN ?"
+	iter := r.session.Query(query, productIDs).WithContext(ctx).Iter()
+	var productID, categoryID, subcategoryID gocql.UUID
+	var name, value string
+	for iter.Scan(&productID, &categoryID, &subcategoryID, &name, &value) {
+		listing := listings[productID]
+		if listing == nil || listing.CategoryID != categoryID || listing.SubcategoryID != subcategoryID {
+			continue
+		}
+		listing.Filters[name] = append(listing.Filters[name], value)
+	}
+	return iter.Close()
+}
+
+// RebuildRankings indexes a listing from its stored rows, or removes it from
+// the rankings when it is not active. It is used to rank listings created
+// before sorting was introduced.
+func (r *productRepository) RebuildRankings(ctx context.Context, productID gocql.UUID) error {
+	product, filters, err := r.ProductInfoByID(ctx, productID)
+	if err != nil {
+		return err
+	}
+	if !product.Status.Listed() {
+		return r.removeRankings(ctx, productID)
+	}
+	return r.indexRankings(ctx, product, *filters)
+}
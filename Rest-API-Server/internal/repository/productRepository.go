@@ -2,25 +2,48 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"github.com/gocql/gocql"
 	"marketplace_project/internal/models"
-	"strings"
+	"marketplace_project/internal/utils"
 	"time"
 )
 
 type ProductRepository interface {
 	AddProduct(ctx context.Context, product *models.Product, filters *[]map[string]string) error
 	DeleteProduct(ctx context.Context, id gocql.UUID) error
-	UpdateProduct(ctx context.Context, product models.Product) error
-	ProductsWrapsByCategory(ctx context.Context, categoryID gocql.UUID, lastProductID gocql.UUID) ([]models.ProductWrapContent, gocql.UUID, error)
+	UpdateProduct(ctx context.Context, product *models.Product, expectedVersion int, filters *[]map[string]string) error
+	ProductsWrapsByCategory(ctx context.Context, categoryID gocql.UUID, sort models.ListingSort, page models.Page) ([]models.ProductWrapContent, []byte, error)
 	CreateProductFilters(ctx context.Context, categoryID gocql.UUID, subcategory gocql.UUID, filter models.Filter, productID gocql.UUID) error
 	ProductWrapByCategory(ctx context.Context, categoryID gocql.UUID) ([]models.ProductWrapContent, error)
-	Products(ctx context.Context) ([]models.ProductWrapContent, error)
-	SearchByKeywords(ctx context.Context, searchQuery string) ([]models.ProductWrapContent, error)
+	Products(ctx context.Context, page models.Page) ([]models.ProductWrapContent, []byte, error)
+	// SearchByKeywords returns a page of the listings matching every keyword,
+	// leaving out those of excludeOwners.
+	SearchByKeywords(ctx context.Context, searchQuery string, excludeOwners map[gocql.UUID]bool, sort models.ListingSort, page models.Page) ([]models.ProductWrapContent, []byte, error)
+	// GetProductByOwnerID returns all listings of the owner, for internal
+	// use; ProductsByOwner serves them page by page.
 	GetProductByOwnerID(ctx context.Context, ownerID gocql.UUID) ([]models.ProductWrapContent, error)
-	FindProductsByFilters(ctx context.Context, categoryID gocql.UUID, subcategoryID gocql.UUID, filters models.Filter, limit int) ([]gocql.UUID, error)
+	// ProductsByOwner returns a page of the owner's listings with their
+	// status, only the active ones when listedOnly is set.
+	ProductsByOwner(ctx context.Context, ownerID gocql.UUID, listedOnly bool, page models.Page) ([]models.ProductWrapContent, []byte, error)
+	// LatestByOwner returns up to limit of the owner's active listings,
+	// newest first.
+	LatestByOwner(ctx context.Context, ownerID gocql.UUID, limit int) ([]models.ProductWrapContent, error)
+	// FindProductsByFilters returns a page of the listings matching query.
+	FindProductsByFilters(ctx context.Context, query models.FilterQuery, sort models.ListingSort, page models.Page) ([]models.ProductWrapContent, []byte, error)
 	FindProductsByID(ctx context.Context, productID gocql.UUID) (*models.ProductWrapContent, error)
 	ProductInfoByID(ctx context.Context, productID gocql.UUID) (*models.Product, *[]models.Filter, error)
+	GetProductOwner(ctx context.Context, productID gocql.UUID) (gocql.UUID, error)
+	// CountActiveProductsByOwner counts the owner's listings that take up a
+	// place in their plan.
+	CountActiveProductsByOwner(ctx context.Context, ownerID gocql.UUID) (int, error)
+	// SetStatus moves product to status if it is still at product.Version,
+	// saving product.ExpiresAt with it, and updates product. Listings that
+	// stop being active leave the rankings and filter counts; listings that
+	// become active join them.
+	SetStatus(ctx context.Context, product *models.Product, status models.ListingStatus) error
+	IncrementViews(ctx context.Context, productID gocql.UUID) error
+	RebuildRankings(ctx context.Context, productID gocql.UUID) error
 }
 
 type productRepository struct {
@@ -31,18 +54,23 @@ func NewProductRepository(session *gocql.Session) ProductRepository {
 	return &productRepository{session: session}
 }
 
-func (r *productRepository) AddProduct(ctx context.Context, product *models.Product, filters *[]map[string]string) error {
-	var brandName string
+// brandFromFilters returns the value of the "brand" filter, which is stored
+// on the product row for display.
+func brandFromFilters(filters *[]map[string]string) string {
 	for _, filterMap := range *filters {
 		for filterName, filterValue := range filterMap {
 			if filterName == "brand" {
-				brandName = filterValue
-				break
+				return filterValue
 			}
 		}
 	}
+	return ""
+}
 
-	query := "INSERT INTO marketplace_keyspace.product(product_id, owner_id, category_id, subcategory_id, title, brandname, description, image, price, keywords, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+func (r *productRepository) AddProduct(ctx context.Context, product *models.Product, filters *[]map[string]string) error {
+	brandName := brandFromFilters(filters)
+
+	query := "INSERT INTO marketplace_keyspace.product(product_id, owner_id, category_id, subcategory_id, title, brandname, description, image, price, keywords, created_at, version, status, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
 	if err := r.session.Query(query,
 		product.ProductID,
 		product.OwnerID,
@@ -55,6 +83,9 @@ func (r *productRepository) AddProduct(ctx context.Context, product *models.Prod
 		product.Price,
 		product.Keywords,
 		product.CreatedAt,
+		product.Version,
+		product.Status,
+		product.ExpiresAt,
 	).WithContext(ctx).Exec(); err != nil {
 		return err
 	}
@@ -64,8 +95,39 @@ func (r *productRepository) AddProduct(ctx context.Context, product *models.Prod
 		return err
 	}
 
+	if err := r.insertProductFilters(ctx, product, filters); err != nil {
+		return err
+	}
+	if !product.Status.Listed() {
+		return nil
+	}
+	return r.indexRankings(ctx, product, filtersFromMaps(filters))
+}
+
+// filtersFromMaps converts filters in the form listings are submitted in,
+// one name and value per map entry.
+func filtersFromMaps(filters *[]map[string]string) []models.Filter {
+	var list []models.Filter
+	for _, filterMap := range *filters {
+		for name, value := range filterMap {
+			list = append(list, models.Filter{Name: name, Value: value})
+		}
+	}
+	return list
+}
+
+// insertProductFilters writes the filters of product. They are counted for
+// facets while the listing is active.
+func (r *productRepository) insertProductFilters(ctx context.Context, product *models.Product, filters *[]map[string]string) error {
+	written := make(map[models.Filter]bool)
 	for _, filterMap := range *filters {
 		for filterName, filterValue := range filterMap {
+			filter := models.Filter{Name: filterName, Value: filterValue}
+			if written[filter] {
+				continue
+			}
+			written[filter] = true
+
 			query := "INSERT INTO marketplace_keyspace.product_filters(category_id, sub_category_id, filter_name, filter_value, product_id) VALUES (?,?,?,?,?)"
 			if err := r.session.Query(query,
 				product.CategoryID,
@@ -76,19 +138,42 @@ func (r *productRepository) AddProduct(ctx context.Context, product *models.Prod
 			).WithContext(ctx).Exec(); err != nil {
 				return err
 			}
+			if !product.Status.Listed() {
+				continue
+			}
+			if err := r.countFilterValue(ctx, product.CategoryID, product.SubcategoryID, filterName, filterValue, 1); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
-func (r *productRepository) DeleteProduct(ctx context.Context, id gocql.UUID) error {
-	query := "SELECT category_id, subcategory_id, created_at FROM marketplace_keyspace.product_by_id WHERE product_id = ?"
-	var categoryID, subCategoryID gocql.UUID
-	var createdAt time.Time
-	if err := r.session.Query(query, id).WithContext(ctx).Scan(&categoryID, &subCategoryID, &createdAt); err != nil {
+// countFilterValue adds delta to the number of listings of the subcategory
+// that have the filter value, which facet counts are read from.
+func (r *productRepository) countFilterValue(ctx context.Context, categoryID, subcategoryID gocql.UUID, name, value string, delta int64) error {
+	query := "UPDATE marketplace_keyspace.product_filter_counts SET listings = listings + ? WHERE category_id = ? AND sub_category_id = ? AND filter_name = ? AND filter_value = ?"
+	return r.session.Query(query, delta, categoryID, subcategoryID, name, value).WithContext(ctx).Exec()
+}
+
+// countFilters adds delta to the facet counts of every filter value of the
+// listing.
+func (r *productRepository) countFilters(ctx context.Context, product *models.Product, delta int64) error {
+	filters, err := r.productFilters(ctx, product.ProductID)
+	if err != nil {
 		return err
 	}
+	for _, filter := range filters {
+		if err := r.countFilterValue(ctx, product.CategoryID, product.SubcategoryID, filter.Name, filter.Value, delta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+// deleteProductFilters removes the filters of the listing, and their facet
+// counts when counted is set.
+func (r *productRepository) deleteProductFilters(ctx context.Context, categoryID, subCategoryID, id gocql.UUID, counted bool) error {
 	filtersQuery := "SELECT filter_name, filter_value FROM marketplace_keyspace.product_filters_by_id WHERE category_id = ? AND sub_category_id = ? AND product_id = ?"
 	iter := r.session.Query(filtersQuery, categoryID, subCategoryID, id).WithContext(ctx).Iter()
 	defer iter.Close()
@@ -98,9 +183,27 @@ func (r *productRepository) DeleteProduct(ctx context.Context, id gocql.UUID) er
 		if err := r.session.Query("DELETE FROM marketplace_keyspace.product_filters WHERE category_id = ? AND sub_category_id = ? AND filter_name = ? AND filter_value = ? AND product_id = ?", categoryID, subCategoryID, filterName, filterValue, id).WithContext(ctx).Exec(); err != nil {
 			return err
 		}
+		if !counted {
+			continue
+		}
+		if err := r.countFilterValue(ctx, categoryID, subCategoryID, filterName, filterValue, -1); err != nil {
+			return err
+		}
 	}
 
-	if err := iter.Close(); err != nil {
+	return iter.Close()
+}
+
+func (r *productRepository) DeleteProduct(ctx context.Context, id gocql.UUID) error {
+	query := "SELECT category_id, subcategory_id, created_at, status FROM marketplace_keyspace.product_by_id WHERE product_id = ?"
+	var categoryID, subCategoryID gocql.UUID
+	var createdAt time.Time
+	var status string
+	if err := r.session.Query(query, id).WithContext(ctx).Scan(&categoryID, &subCategoryID, &createdAt, &status); err != nil {
+		return err
+	}
+
+	if err := r.deleteProductFilters(ctx, categoryID, subCategoryID, id, models.ListingStatusOf(status).Listed()); err != nil {
 		return err
 	}
 
@@ -112,63 +215,13 @@ func (r *productRepository) DeleteProduct(ctx context.Context, id gocql.UUID) er
 		return err
 	}
 
-	return nil
+	return r.removeRankings(ctx, id)
 }
 
-func (r *productRepository) ProductsWrapsByCategory(ctx context.Context, categoryID gocql.UUID, lastProductID gocql.UUID) ([]models.ProductWrapContent, gocql.UUID, error) {
-	var productID gocql.UUID
-	var productWrapList []models.ProductWrapContent
-	pageSize := 2
-
-	var query string
-	var iter *gocql.Iter
-
-	if lastProductID == (gocql.UUID{}) {
-		query = "SELECT product_id FROM marketplace_keyspace.product WHERE category_id = ? LIMIT ?"
-		iter = r.session.Query(query, categoryID, pageSize).WithContext(ctx).Iter()
-	} else {
-		query = "SELECT product_id FROM marketplace_keyspace.product WHERE category_id = ? AND product_id < ? LIMIT ?"
-		iter = r.session.Query(query, categoryID, lastProductID, pageSize).WithContext(ctx).Iter()
-	}
-
-	defer iter.Close()
-
-	var productIDList []gocql.UUID
-	for iter.Scan(&productID) {
-		productIDList = append(productIDList, productID)
-	}
-
-	if len(productIDList) == 0 {
-		return nil, lastProductID, nil
-	}
-
-	query = "SELECT product_id, title, image, price FROM marketplace_keyspace.product WHERE product_id = ?"
-	for _, id := range productIDList {
-		var product models.ProductWrapContent
-		var imageList []string
-		err := r.session.Query(query, id).WithContext(ctx).Scan(
-			&product.ProductID,
-			&product.Title,
-			&imageList,
-			&product.Price,
-		)
-		if err != nil {
-			return nil, lastProductID, err
-		}
-		if len(imageList) > 0 {
-			product.Image = imageList[0] // Only take the first image
-		} else {
-			product.Image = ""
-		}
-		productWrapList = append(productWrapList, product)
-	}
-
-	newPagingState := gocql.UUID{}
-	if len(productIDList) > 0 {
-		newPagingState = productIDList[len(productIDList)-1]
-	}
-
-	return productWrapList, newPagingState, nil
+func (r *productRepository) ProductsWrapsByCategory(ctx context.Context, categoryID gocql.UUID, sort models.ListingSort, page models.Page) ([]models.ProductWrapContent, []byte, error) {
+	return r.rankedProducts(ctx, categoryScope(categoryID), sort, page, false, func(*rankedListing) (bool, error) {
+		return true, nil
+	})
 }
 
 func (r *productRepository) CreateProductFilters(ctx context.Context, categoryID gocql.UUID, subcategory gocql.UUID, filter models.Filter, productID gocql.UUID) error {
@@ -182,25 +235,13 @@ func (r *productRepository) CreateProductFilters(ctx context.Context, categoryID
 	).WithContext(ctx).Exec()
 }
 
+// ProductWrapByCategory returns the newest active listings of the category
+// for the home page.
 func (r *productRepository) ProductWrapByCategory(ctx context.Context, categoryID gocql.UUID) ([]models.ProductWrapContent, error) {
-	query := "SELECT product_id, title, image, price FROM marketplace_keyspace.product WHERE category_id = ? limit 8"
-	var productWrap models.ProductWrapContent
-	var productWrapList []models.ProductWrapContent
-	var imageList []string
-	iter := r.session.Query(query, categoryID).WithContext(ctx).Iter()
-	defer iter.Close()
-	for iter.Scan(&productWrap.ProductID, &productWrap.Title, &imageList, &productWrap.Price) {
-		if len(imageList) > 0 {
-			productWrap.Image = imageList[0]
-		} else {
-			productWrap.Image = ""
-		}
-		productWrapList = append(productWrapList, productWrap)
-	}
-	if err := iter.Close(); err != nil {
-		return nil, err
-	}
-	return productWrapList, nil
+	products, _, err := r.rankedProducts(ctx, categoryScope(categoryID), models.SortNewest, models.Page{Limit: 8}, false, func(*rankedListing) (bool, error) {
+		return true, nil
+	})
+	return products, err
 }
 
 func (r *productRepository) FindProductsByID(ctx context.Context, productID gocql.UUID) (*models.ProductWrapContent, error) {
@@ -225,25 +266,45 @@ func (r *productRepository) FindProductsByID(ctx context.Context, productID gocq
 	return &productWrap, nil
 }
 
-func (r *productRepository) Products(ctx context.Context) ([]models.ProductWrapContent, error) {
-	query := "SELECT product_id, title, image, price FROM marketplace_keyspace.product"
-	var productWrap models.ProductWrapContent
-	var productWrapList []models.ProductWrapContent
-	iter := r.session.Query(query).WithContext(ctx).Iter()
-	defer iter.Close()
-	var imageList []string
-	for iter.Scan(&productWrap.ProductID, &productWrap.Title, &imageList, &productWrap.Price) {
-		if len(imageList) > 0 {
-			productWrap.Image = imageList[0]
-		} else {
-			productWrap.Image = ""
+// Products returns a page of all active listings.
+func (r *productRepository) Products(ctx context.Context, page models.Page) ([]models.ProductWrapContent, []byte, error) {
+	query := "SELECT product_id, title, image, price, status FROM marketplace_keyspace.product"
+	return r.productWrapPage(func() *gocql.Query {
+		return r.session.Query(query).WithContext(ctx)
+	}, true, page)
+}
+
+// productWrapPage reads a page of product_id, title, image, price, status
+// rows, leaving out listings that are not active when listedOnly is set.
+func (r *productRepository) productWrapPage(newQuery func() *gocql.Query, listedOnly bool, page models.Page) ([]models.ProductWrapContent, []byte, error) {
+	products := []models.ProductWrapContent{}
+	next, err := fillPage(newQuery, page, func(iter *gocql.Iter) (int, error) {
+		kept := 0
+		var product models.ProductWrapContent
+		var imageList []string
+		var status string
+		for iter.Scan(&product.ProductID, &product.Title, &imageList, &product.Price, &status) {
+			product.Status = models.ListingStatusOf(status)
+			if listedOnly && !product.Status.Listed() {
+				continue
+			}
+			product.Image = firstImage(imageList)
+			products = append(products, product)
+			kept++
 		}
-		productWrapList = append(productWrapList, productWrap)
+		return kept, nil
+	})
+	if err != nil {
+		return nil, nil, err
 	}
-	if err := iter.Close(); err != nil {
-		return nil, err
+	return products, next, nil
+}
+
+func firstImage(images []string) string {
+	if len(images) > 0 {
+		return images[0]
 	}
-	return productWrapList, nil
+	return ""
 }
 
 //func (r *productRepository) SearchByKeyword(session *gocql.Session, keyword string) ([]models.ProductWrapContent, error) {
@@ -265,64 +326,49 @@ func (r *productRepository) Products(ctx context.Context) ([]models.ProductWrapC
 //	return products, nil
 //}
 
-func (r *productRepository) SearchByKeywords(ctx context.Context, searchQuery string) ([]models.ProductWrapContent, error) {
-
-	keywords := strings.Fields(searchQuery)
+// SearchByKeywords pages through the ranking of the first keyword and keeps
+// the listings that carry all the others too.
+func (r *productRepository) SearchByKeywords(ctx context.Context, searchQuery string, excludeOwners map[gocql.UUID]bool, sort models.ListingSort, page models.Page) ([]models.ProductWrapContent, []byte, error) {
+	keywords := utils.ExtractKeywords(searchQuery)
 	if len(keywords) == 0 {
-		return nil, nil
+		return []models.ProductWrapContent{}, nil, nil
 	}
+	return r.rankedProducts(ctx, keywordScope(keywords[0]), sort, page, false, func(listing *rankedListing) (bool, error) {
+		return !excludeOwners[listing.OwnerID] && containsAll(listing.Keywords, keywords), nil
+	})
+}
 
-	productKeywordCount := make(map[gocql.UUID]int)
-	productDetails := make(map[gocql.UUID]models.ProductWrapContent)
-
-	for _, keyword := range keywords {
-		query := `SELECT product_id, title, image, price FROM marketplace_keyspace.product WHERE keywords CONTAINS ?`
-		iter := r.session.Query(query, keyword).Iter()
-
-		var product models.ProductWrapContent
-		var imageList []string
-
-		for iter.Scan(&product.ProductID, &product.Title, &imageList, &product.Price) {
-
-			if len(imageList) > 0 {
-				product.Image = imageList[0]
-			} else {
-				product.Image = ""
-			}
-			if _, exists := productKeywordCount[product.ProductID]; exists {
-				productKeywordCount[product.ProductID]++
-			} else {
-				productKeywordCount[product.ProductID] = 1
-				productDetails[product.ProductID] = product
+func containsAll(values, wanted []string) bool {
+	for _, w := range wanted {
+		found := false
+		for _, v := range values {
+			if v == w {
+				found = true
+				break
 			}
 		}
-		iter.Close()
-	}
-
-	var result []models.ProductWrapContent
-	requiredKeywordCount := len(keywords)
-	for productID, count := range productKeywordCount {
-		if count == requiredKeywordCount {
-			result = append(result, productDetails[productID])
+		if !found {
+			return false
 		}
 	}
-
-	return result, nil
+	return true
 }
 
 func (r *productRepository) GetProductByOwnerID(ctx context.Context, ownerID gocql.UUID) ([]models.ProductWrapContent, error) {
-	query := "SELECT product_id, title, image, price FROM marketplace_keyspace.product WHERE owner_id = ?"
+	query := "SELECT product_id, title, image, price, status FROM marketplace_keyspace.product WHERE owner_id = ?"
 	var productWrap models.ProductWrapContent
 	var productWrapList []models.ProductWrapContent
 	iter := r.session.Query(query, ownerID).WithContext(ctx).Iter()
 	defer iter.Close()
 	var imageList []string
-	for iter.Scan(&productWrap.ProductID, &productWrap.Title, &imageList, &productWrap.Price) {
+	var status string
+	for iter.Scan(&productWrap.ProductID, &productWrap.Title, &imageList, &productWrap.Price, &status) {
 		if len(imageList) > 0 {
 			productWrap.Image = imageList[0]
 		} else {
 			productWrap.Image = ""
 		}
+		productWrap.Status = models.ListingStatusOf(status)
 		productWrapList = append(productWrapList, productWrap)
 	}
 	if err := iter.Close(); err != nil {
@@ -331,34 +377,54 @@ func (r *productRepository) GetProductByOwnerID(ctx context.Context, ownerID goc
 	return productWrapList, nil
 }
 
-func (r *productRepository) FindProductsByFilters(ctx context.Context, categoryID gocql.UUID, subcategoryID gocql.UUID, filters models.Filter, limit int) ([]gocql.UUID, error) {
-	query := "SELECT product_id FROM marketplace_keyspace.product_filters WHERE category_id = ? AND  sub_category_id = ? AND filter_name = ? AND filter_value = ?"
-	if limit > 0 {
-		query += " LIMIT ?"
-	}
+func (r *productRepository) ProductsByOwner(ctx context.Context, ownerID gocql.UUID, listedOnly bool, page models.Page) ([]models.ProductWrapContent, []byte, error) {
+	query := "SELECT product_id, title, image, price, status FROM marketplace_keyspace.product WHERE owner_id = ?"
+	return r.productWrapPage(func() *gocql.Query {
+		return r.session.Query(query, ownerID).WithContext(ctx)
+	}, listedOnly, page)
+}
 
-	var iter *gocql.Iter
-	if limit > 0 {
-		iter = r.session.Query(query, categoryID, subcategoryID, filters.Name, filters.Value, limit).WithContext(ctx).Iter()
-	} else {
-		iter = r.session.Query(query, categoryID, subcategoryID, filters.Name, filters.Value).WithContext(ctx).Iter()
-	}
+func (r *productRepository) LatestByOwner(ctx context.Context, ownerID gocql.UUID, limit int) ([]models.ProductWrapContent, error) {
+	products, _, err := r.rankedProducts(ctx, sellerScope(ownerID), models.SortNewest, models.Page{Limit: limit}, false, func(*rankedListing) (bool, error) {
+		return true, nil
+	})
+	return products, err
+}
 
-	defer iter.Close()
-	var ids []gocql.UUID
-	var id gocql.UUID
-	for iter.Scan(&id) {
-		ids = append(ids, id)
-	}
-	if err := iter.Close(); err != nil {
-		return nil, err
+// FindProductsByFilters pages through the ranking of the first condition
+// that asks for a single value, or of the whole subcategory when there is
+// none, and keeps the listings that meet all conditions.
+func (r *productRepository) FindProductsByFilters(ctx context.Context, query models.FilterQuery, sort models.ListingSort, page models.Page) ([]models.ProductWrapContent, []byte, error) {
+	scope := subcategoryScope(query.CategoryID, query.SubcategoryID)
+	for _, condition := range query.Conditions {
+		if !condition.IsRange() && len(condition.Values) == 1 {
+			scope = filterScope(query.CategoryID, query.SubcategoryID, models.Filter{Name: condition.Name, Value: condition.Values[0]})
+			break
+		}
 	}
-	return ids, nil
+	return r.rankedProducts(ctx, scope, sort, page, len(query.Conditions) > 0, func(listing *rankedListing) (bool, error) {
+		if listing.CategoryID != query.CategoryID || listing.SubcategoryID != query.SubcategoryID {
+			return false, nil
+		}
+		if (query.PriceMin != nil && listing.Price < *query.PriceMin) || (query.PriceMax != nil && listing.Price > *query.PriceMax) {
+			return false, nil
+		}
+		if len(query.Conditions) == 0 {
+			return true, nil
+		}
+		for _, condition := range query.Conditions {
+			if !condition.Matches(listing.Filters[condition.Name]) {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
 }
 
 func (r *productRepository) ProductInfoByID(ctx context.Context, productID gocql.UUID) (*models.Product, *[]models.Filter, error) {
 	var productInfo models.Product
-	productQuery := "SELECT product_id, title, image, description, price, owner_id, created_at, category_id, subcategory_id, brandName FROM marketplace_keyspace.product_by_id WHERE product_id = ?"
+	var status string
+	productQuery := "SELECT product_id, title, image, description, price, owner_id, created_at, category_id, subcategory_id, brandName, keywords, version, status, expires_at FROM marketplace_keyspace.product_by_id WHERE product_id = ?"
 	if err := r.session.Query(productQuery, productID).WithContext(ctx).Scan(
 		&productInfo.ProductID,
 		&productInfo.Title,
@@ -370,14 +436,36 @@ func (r *productRepository) ProductInfoByID(ctx context.Context, productID gocql
 		&productInfo.CategoryID,
 		&productInfo.SubcategoryID,
 		&productInfo.BrandName,
+		&productInfo.Keywords,
+		&productInfo.Version,
+		&status,
+		&productInfo.ExpiresAt,
 	); err != nil {
+		if errors.Is(err, gocql.ErrNotFound) {
+			return nil, nil, utils.ErrNotFound
+		}
 		return nil, nil, err
 	}
+	productInfo.Status = models.ListingStatusOf(status)
 
+	viewsQuery := "SELECT views FROM marketplace_keyspace.product_views WHERE product_id = ?"
+	if err := r.session.Query(viewsQuery, productID).WithContext(ctx).Scan(&productInfo.Views); err != nil && !errors.Is(err, gocql.ErrNotFound) {
+		return nil, nil, err
+	}
+
+	filters, err := r.productFilters(ctx, productID)
+	if err != nil {
+		return &productInfo, nil, err
+	}
+
+	return &productInfo, &filters, nil
+}
+
+func (r *productRepository) productFilters(ctx context.Context, productID gocql.UUID) ([]models.Filter, error) {
 	var filters []models.Filter
 
-	productQuery = "SELECT filter_name, filter_value FROM marketplace_keyspace.product_filters WHERE product_id = ?"
-	iter := r.session.Query(productQuery, productID).WithContext(ctx).Iter()
+	query := "SELECT filter_name, filter_value FROM marketplace_keyspace.product_filters WHERE product_id = ?"
+	iter := r.session.Query(query, productID).WithContext(ctx).Iter()
 	defer iter.Close()
 
 	var filterName, filterValue string
@@ -392,23 +480,142 @@ func (r *productRepository) ProductInfoByID(ctx context.Context, productID gocql
 	}
 
 	if err := iter.Close(); err != nil {
-		return &productInfo, nil, err
+		return nil, err
 	}
 
-	return &productInfo, &filters, nil
+	return filters, nil
 }
 
-func (r *productRepository) UpdateProduct(ctx context.Context, product models.Product) error {
-	query := "UPDATE marketplace_keyspace.product SET owner_id = ?, title = ?, image = ?, description = ?, price = ?,brandName = ?, category_id = ?, subcategory_id = ?,created_at = ?, keywords = ? WHERE product_id = ?"
-	return r.session.Query(query,
-		product.OwnerID, product.Title, product.Images, product.Description,
-		product.Price, product.BrandName, product.CategoryID, product.SubcategoryID,
-		product.CreatedAt, product.Views, product.Keywords,
-		product.ProductID,
-	).WithContext(ctx).Exec()
+func (r *productRepository) GetProductOwner(ctx context.Context, productID gocql.UUID) (gocql.UUID, error) {
+	query := "SELECT owner_id FROM marketplace_keyspace.product_by_id WHERE product_id = ?"
+	var ownerID gocql.UUID
+	if err := r.session.Query(query, productID).WithContext(ctx).Scan(&ownerID); err != nil {
+		if errors.Is(err, gocql.ErrNotFound) {
+			return gocql.UUID{}, utils.ErrNotFound
+		}
+		return gocql.UUID{}, err
+	}
+	return ownerID, nil
+}
+
+func (r *productRepository) CountActiveProductsByOwner(ctx context.Context, ownerID gocql.UUID) (int, error) {
+	query := "SELECT status FROM marketplace_keyspace.product WHERE owner_id = ?"
+	iter := r.session.Query(query, ownerID).WithContext(ctx).Iter()
+	count := 0
+	var status string
+	for iter.Scan(&status) {
+		if models.ListingStatusOf(status).Counted() {
+			count++
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return 0, err
+	}
+	return count, nil
 }
 
+// UpdateProduct writes the editable fields of product if its stored version
+// still equals expectedVersion, and bumps product.Version. product_by_id is a
+// materialized view of product and follows automatically; the product_filters
+// rows are replaced when filters is not nil.
+func (r *productRepository) UpdateProduct(ctx context.Context, product *models.Product, expectedVersion int, filters *[]map[string]string) error {
+	// Rows written before versioning have no version column at all.
+	var expected interface{}
+	if expectedVersion > 0 {
+		expected = expectedVersion
+	}
+	if filters != nil {
+		product.BrandName = brandFromFilters(filters)
+	}
+
+	query := "UPDATE marketplace_keyspace.product SET title = ?, image = ?, description = ?, price = ?, brandName = ?, keywords = ?, version = ? WHERE category_id = ? AND subcategory_id = ? AND created_at = ? AND product_id = ? IF version = ?"
+	applied, err := r.session.Query(query,
+		product.Title, product.Images, product.Description, product.Price,
+		product.BrandName, product.Keywords, expectedVersion+1,
+		product.CategoryID, product.SubcategoryID, product.CreatedAt, product.ProductID,
+		expected,
+	).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return utils.ErrVersionConflict
+	}
+	product.Version = expectedVersion + 1
+
+	if filters == nil {
+		if !product.Status.Listed() {
+			return nil
+		}
+		current, err := r.productFilters(ctx, product.ProductID)
+		if err != nil {
+			return err
+		}
+		return r.indexRankings(ctx, product, current)
+	}
+	if err := r.deleteProductFilters(ctx, product.CategoryID, product.SubcategoryID, product.ProductID, product.Status.Listed()); err != nil {
+		return err
+	}
+	if err := r.insertProductFilters(ctx, product, filters); err != nil {
+		return err
+	}
+	if !product.Status.Listed() {
+		return nil
+	}
+	return r.indexRankings(ctx, product, filtersFromMaps(filters))
+}
+
+func (r *productRepository) SetStatus(ctx context.Context, product *models.Product, status models.ListingStatus) error {
+	var expected interface{}
+	if product.Version > 0 {
+		expected = product.Version
+	}
+	query := "UPDATE marketplace_keyspace.product SET status = ?, expires_at = ?, version = ? WHERE category_id = ? AND subcategory_id = ? AND created_at = ? AND product_id = ? IF version = ?"
+	applied, err := r.session.Query(query,
+		status, product.ExpiresAt, product.Version+1,
+		product.CategoryID, product.SubcategoryID, product.CreatedAt, product.ProductID,
+		expected,
+	).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return utils.ErrVersionConflict
+	}
+	wasListed := product.Status.Listed()
+	product.Status = status
+	product.Version++
+
+	switch {
+	case wasListed && !status.Listed():
+		if err := r.removeRankings(ctx, product.ProductID); err != nil {
+			return err
+		}
+		return r.countFilters(ctx, product, -1)
+	case !wasListed && status.Listed():
+		filters, err := r.productFilters(ctx, product.ProductID)
+		if err != nil {
+			return err
+		}
+		if err := r.indexRankings(ctx, product, filters); err != nil {
+			return err
+		}
+		return r.countFilters(ctx, product, 1)
+	}
+	return nil
+}
+
+// IncrementViews counts a view of the listing and moves it up the views
+// ranking.
 func (r *productRepository) IncrementViews(ctx context.Context, productID gocql.UUID) error {
 	query := "UPDATE marketplace_keyspace.product_views SET views = views + 1 WHERE product_id = ?"
-	return r.session.Query(query, productID).WithContext(ctx).Exec()
+	if err := r.session.Query(query, productID).WithContext(ctx).Exec(); err != nil {
+		return err
+	}
+	var views int64
+	query = "SELECT views FROM marketplace_keyspace.product_views WHERE product_id = ?"
+	if err := r.session.Query(query, productID).WithContext(ctx).Scan(&views); err != nil {
+		return err
+	}
+	return r.moveViewsScore(ctx, productID, views)
 }
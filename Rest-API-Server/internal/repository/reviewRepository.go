@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/utils"
+	"time"
+)
+
+type ReviewRepository interface {
+	CreateReview(ctx context.Context, review *models.Review) error
+	GetReview(ctx context.Context, sellerID, reviewID gocql.UUID) (*models.Review, error)
+	ListReviews(ctx context.Context, sellerID gocql.UUID, page models.Page) ([]models.Review, []byte, error)
+	SetReply(ctx context.Context, sellerID, reviewID gocql.UUID, reply models.ReviewReply) error
+}
+
+type reviewRepository struct {
+	session *gocql.Session
+}
+
+func NewReviewRepository(session *gocql.Session) ReviewRepository {
+	return &reviewRepository{session: session}
+}
+
+// CreateReview stores the review and updates the seller's rating. A buyer
+// can review each listing once; the claim in review_claims makes that hold
+// under concurrent requests. The claim stays pending until the rating
+// includes the review, so when a request fails after the claim a retry
+// finishes it instead of being refused, and review.ReviewID is then the
+// claimed review's.
+func (r *reviewRepository) CreateReview(ctx context.Context, review *models.Review) error {
+	claim := "INSERT INTO marketplace_keyspace.review_claims(product_id, buyer_id, review_id, pending) VALUES (?, ?, ?, true) IF NOT EXISTS"
+	existing := map[string]interface{}{}
+	applied, err := r.session.Query(claim, review.ProductID, review.BuyerID, review.ReviewID).WithContext(ctx).MapScanCAS(existing)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		if pending, _ := existing["pending"].(bool); !pending {
+			return utils.ErrAlreadyReviewed
+		}
+		return r.finishReview(ctx, review, existing["review_id"].(gocql.UUID))
+	}
+
+	query := "INSERT INTO marketplace_keyspace.reviews_by_seller(seller_id, review_id, product_id, buyer_id, rating, text, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	if err := r.session.Query(query, review.SellerID, review.ReviewID, review.ProductID, review.BuyerID, review.Rating, review.Text, review.CreatedAt).WithContext(ctx).Exec(); err != nil {
+		release := "DELETE FROM marketplace_keyspace.review_claims WHERE product_id = ? AND buyer_id = ? IF review_id = ?"
+		if _, releaseErr := r.session.Query(release, review.ProductID, review.BuyerID, review.ReviewID).WithContext(ctx).MapScanCAS(map[string]interface{}{}); releaseErr != nil {
+			return fmt.Errorf("%w (releasing review claim: %v)", err, releaseErr)
+		}
+		return err
+	}
+	return r.rate(ctx, review)
+}
+
+// finishReview completes a review whose claim was taken by an earlier
+// request that did not get as far as updating the rating. The review is
+// stored under the claimed ID if it is missing; one that was stored is kept.
+func (r *reviewRepository) finishReview(ctx context.Context, review *models.Review, reviewID gocql.UUID) error {
+	stored, err := r.GetReview(ctx, review.SellerID, reviewID)
+	switch {
+	case err == nil:
+		*review = *stored
+	case errors.Is(err, utils.ErrNotFound):
+		review.ReviewID = reviewID
+		query := "INSERT INTO marketplace_keyspace.reviews_by_seller(seller_id, review_id, product_id, buyer_id, rating, text, created_at) VALUES (?, ?, ?, ?, ?, ?, ?) IF NOT EXISTS"
+		if _, err := r.session.Query(query, review.SellerID, review.ReviewID, review.ProductID, review.BuyerID, review.Rating, review.Text, review.CreatedAt).WithContext(ctx).MapScanCAS(map[string]interface{}{}); err != nil {
+			return err
+		}
+	default:
+		return err
+	}
+	return r.rate(ctx, review)
+}
+
+// rate adds the review's rating to the seller's aggregate and then records
+// on its claim that it was applied, so a retry adds it only while the claim
+// is still pending.
+func (r *reviewRepository) rate(ctx context.Context, review *models.Review) error {
+	if err := r.addRating(ctx, review.SellerID, review.Rating); err != nil {
+		return err
+	}
+	query := "UPDATE marketplace_keyspace.review_claims SET pending = false WHERE product_id = ? AND buyer_id = ? IF review_id = ? AND pending = true"
+	_, err := r.session.Query(query, review.ProductID, review.BuyerID, review.ReviewID).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+	return err
+}
+
+// addRating adds one rating to the running sum and count on the seller's
+// account, so the average never has to be recomputed from all reviews.
+func (r *reviewRepository) addRating(ctx context.Context, sellerID gocql.UUID, rating int) error {
+	for i := 0; i < maxCASRetries; i++ {
+		var sum, count *int
+		query := "SELECT rating_sum, rating_count FROM marketplace_keyspace.userdata WHERE id = ?"
+		if err := r.session.Query(query, sellerID).WithContext(ctx).Scan(&sum, &count); err != nil {
+			if errors.Is(err, gocql.ErrNotFound) {
+				return utils.ErrNotFound
+			}
+			return err
+		}
+
+		nextSum, nextCount := rating, 1
+		if sum != nil {
+			nextSum += *sum
+		}
+		if count != nil {
+			nextCount += *count
+		}
+		update := "UPDATE marketplace_keyspace.userdata SET rating_sum = ?, rating_count = ? WHERE id = ? IF rating_count = ?"
+		applied, err := r.session.Query(update, nextSum, nextCount, sellerID, count).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+		if err != nil {
+			return err
+		}
+		if applied {
+			return nil
+		}
+	}
+	return fmt.Errorf("update rating of %s: too much contention", sellerID)
+}
+
+func (r *reviewRepository) GetReview(ctx context.Context, sellerID, reviewID gocql.UUID) (*models.Review, error) {
+	query := "SELECT review_id, product_id, buyer_id, rating, text, reply, replied_at, created_at FROM marketplace_keyspace.reviews_by_seller WHERE seller_id = ? AND review_id = ?"
+	iter := r.session.Query(query, sellerID, reviewID).WithContext(ctx).Iter()
+	reviews := scanReviews(iter, sellerID)
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	if len(reviews) == 0 {
+		return nil, utils.ErrNotFound
+	}
+	return &reviews[0], nil
+}
+
+// ListReviews returns a page of reviews, newest first.
+func (r *reviewRepository) ListReviews(ctx context.Context, sellerID gocql.UUID, page models.Page) ([]models.Review, []byte, error) {
+	query := "SELECT review_id, product_id, buyer_id, rating, text, reply, replied_at, created_at FROM marketplace_keyspace.reviews_by_seller WHERE seller_id = ?"
+	iter := pageIter(r.session.Query(query, sellerID).WithContext(ctx), page)
+	next := nextPageState(iter)
+	reviews := scanReviews(iter, sellerID)
+	if err := iter.Close(); err != nil {
+		return nil, nil, err
+	}
+	return reviews, next, nil
+}
+
+func scanReviews(iter *gocql.Iter, sellerID gocql.UUID) []models.Review {
+	reviews := []models.Review{}
+	var review models.Review
+	var reply *string
+	var repliedAt time.Time
+	for iter.Scan(&review.ReviewID, &review.ProductID, &review.BuyerID, &review.Rating, &review.Text, &reply, &repliedAt, &review.CreatedAt) {
+		review.SellerID = sellerID
+		review.Reply = nil
+		if reply != nil {
+			review.Reply = &models.ReviewReply{Text: *reply, CreatedAt: repliedAt}
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews
+}
+
+// SetReply publishes the seller's reply. The condition keeps the first reply
+// when two are sent at once; the review must already exist.
+func (r *reviewRepository) SetReply(ctx context.Context, sellerID, reviewID gocql.UUID, reply models.ReviewReply) error {
+	query := "UPDATE marketplace_keyspace.reviews_by_seller SET reply = ?, replied_at = ? WHERE seller_id = ? AND review_id = ? IF reply = null"
+	applied, err := r.session.Query(query, reply.Text, reply.CreatedAt, sellerID, reviewID).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return utils.ErrAlreadyReplied
+	}
+	return nil
+}
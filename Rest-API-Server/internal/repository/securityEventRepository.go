@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/models"
+)
+
+type SecurityEventRepository interface {
+	RecordEvent(ctx context.Context, event *models.SecurityEvent) error
+	ListEvents(ctx context.Context, userID gocql.UUID, page models.Page) ([]models.SecurityEvent, []byte, error)
+}
+
+type securityEventRepository struct {
+	session *gocql.Session
+}
+
+func NewSecurityEventRepository(session *gocql.Session) SecurityEventRepository {
+	return &securityEventRepository{session: session}
+}
+
+func (r *securityEventRepository) RecordEvent(ctx context.Context, event *models.SecurityEvent) error {
+	query := "INSERT INTO marketplace_keyspace.security_events(user_id, event_id, type, ip, created_at) VALUES (?, ?, ?, ?, ?)"
+	return r.session.Query(query, event.UserID, event.EventID, event.Type, event.IP, event.CreatedAt).WithContext(ctx).Exec()
+}
+
+func (r *securityEventRepository) ListEvents(ctx context.Context, userID gocql.UUID, page models.Page) ([]models.SecurityEvent, []byte, error) {
+	query := "SELECT event_id, type, ip, created_at FROM marketplace_keyspace.security_events WHERE user_id = ?"
+	iter := pageIter(r.session.Query(query, userID).WithContext(ctx), page)
+	defer iter.Close()
+	next := nextPageState(iter)
+
+	events := []models.SecurityEvent{}
+	event := models.SecurityEvent{UserID: userID}
+	for iter.Scan(&event.EventID, &event.Type, &event.IP, &event.CreatedAt) {
+		events = append(events, event)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, nil, err
+	}
+	return events, next, nil
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/utils"
+	"time"
+)
+
+type SessionRepository interface {
+	SaveSession(ctx context.Context, session *models.Session, ttl time.Duration) error
+	// UpdateSession saves a session only if it still exists and reports
+	// whether it did, so a session that was signed out stays gone.
+	UpdateSession(ctx context.Context, session *models.Session, ttl time.Duration) (bool, error)
+	GetSession(ctx context.Context, userID gocql.UUID, sessionID gocql.UUID) (*models.Session, error)
+	ListSessions(ctx context.Context, userID gocql.UUID) ([]models.Session, error)
+	SessionsPage(ctx context.Context, userID gocql.UUID, page models.Page) ([]models.Session, []byte, error)
+	DeleteSession(ctx context.Context, userID gocql.UUID, sessionID gocql.UUID) error
+	DeleteAllSessions(ctx context.Context, userID gocql.UUID) error
+	SaveRefreshToken(ctx context.Context, token *models.RefreshToken, ttl time.Duration) error
+	GetRefreshToken(ctx context.Context, tokenID gocql.UUID) (*models.RefreshToken, error)
+	MarkRefreshTokenUsed(ctx context.Context, token *models.RefreshToken) (bool, error)
+}
+
+type sessionRepository struct {
+	session *gocql.Session
+}
+
+func NewSessionRepository(session *gocql.Session) SessionRepository {
+	return &sessionRepository{session: session}
+}
+
+func (r *sessionRepository) SaveSession(ctx context.Context, session *models.Session, ttl time.Duration) error {
+	query := "INSERT INTO marketplace_keyspace.sessions(user_id, session_id, device, ip, created_at, last_used_at) VALUES (?, ?, ?, ?, ?, ?) USING TTL ?"
+	return r.session.Query(query,
+		session.UserID,
+		session.SessionID,
+		session.Device,
+		session.IP,
+		session.CreatedAt,
+		session.LastUsedAt,
+		int(ttl.Seconds()),
+	).WithContext(ctx).Exec()
+}
+
+func (r *sessionRepository) UpdateSession(ctx context.Context, session *models.Session, ttl time.Duration) (bool, error) {
+	query := "UPDATE marketplace_keyspace.sessions USING TTL ? SET device = ?, ip = ?, created_at = ?, last_used_at = ? WHERE user_id = ? AND session_id = ? IF EXISTS"
+	return r.session.Query(query,
+		int(ttl.Seconds()),
+		session.Device,
+		session.IP,
+		session.CreatedAt,
+		session.LastUsedAt,
+		session.UserID,
+		session.SessionID,
+	).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+}
+
+func (r *sessionRepository) GetSession(ctx context.Context, userID gocql.UUID, sessionID gocql.UUID) (*models.Session, error) {
+	query := "SELECT user_id, session_id, device, ip, created_at, last_used_at FROM marketplace_keyspace.sessions WHERE user_id = ? AND session_id = ?"
+	var session models.Session
+	err := r.session.Query(query, userID, sessionID).WithContext(ctx).Scan(
+		&session.UserID,
+		&session.SessionID,
+		&session.Device,
+		&session.IP,
+		&session.CreatedAt,
+		&session.LastUsedAt,
+	)
+	if err != nil {
+		if errors.Is(err, gocql.ErrNotFound) {
+			return nil, utils.ErrNotFound
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+const sessionsQuery = "SELECT user_id, session_id, device, ip, created_at, last_used_at FROM marketplace_keyspace.sessions WHERE user_id = ?"
+
+// ListSessions returns every session of the user. It is meant for revoking
+// them all; listings shown to the user go through SessionsPage.
+func (r *sessionRepository) ListSessions(ctx context.Context, userID gocql.UUID) ([]models.Session, error) {
+	return scanSessions(r.session.Query(sessionsQuery, userID).WithContext(ctx).Iter())
+}
+
+func (r *sessionRepository) SessionsPage(ctx context.Context, userID gocql.UUID, page models.Page) ([]models.Session, []byte, error) {
+	iter := pageIter(r.session.Query(sessionsQuery, userID).WithContext(ctx), page)
+	next := nextPageState(iter)
+	sessions, err := scanSessions(iter)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sessions, next, nil
+}
+
+func scanSessions(iter *gocql.Iter) ([]models.Session, error) {
+	var session models.Session
+	sessions := []models.Session{}
+	for iter.Scan(&session.UserID, &session.SessionID, &session.Device, &session.IP, &session.CreatedAt, &session.LastUsedAt) {
+		sessions = append(sessions, session)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (r *sessionRepository) DeleteSession(ctx context.Context, userID gocql.UUID, sessionID gocql.UUID) error {
+	query := "DELETE FROM marketplace_keyspace.sessions WHERE user_id = ? AND session_id = ?"
+	return r.session.Query(query, userID, sessionID).WithContext(ctx).Exec()
+}
+
+func (r *sessionRepository) DeleteAllSessions(ctx context.Context, userID gocql.UUID) error {
+	query := "DELETE FROM marketplace_keyspace.sessions WHERE user_id = ?"
+	return r.session.Query(query, userID).WithContext(ctx).Exec()
+}
+
+func (r *sessionRepository) SaveRefreshToken(ctx context.Context, token *models.RefreshToken, ttl time.Duration) error {
+	query := "INSERT INTO marketplace_keyspace.refresh_tokens(token_id, session_id, user_id, expires_at, used) VALUES (?, ?, ?, ?, ?) USING TTL ?"
+	return r.session.Query(query,
+		token.TokenID,
+		token.SessionID,
+		token.UserID,
+		token.ExpiresAt,
+		token.Used,
+		int(ttl.Seconds()),
+	).WithContext(ctx).Exec()
+}
+
+func (r *sessionRepository) GetRefreshToken(ctx context.Context, tokenID gocql.UUID) (*models.RefreshToken, error) {
+	query := "SELECT token_id, session_id, user_id, expires_at, used FROM marketplace_keyspace.refresh_tokens WHERE token_id = ?"
+	var token models.RefreshToken
+	err := r.session.Query(query, tokenID).WithContext(ctx).Scan(
+		&token.TokenID,
+		&token.SessionID,
+		&token.UserID,
+		&token.ExpiresAt,
+		&token.Used,
+	)
+	if err != nil {
+		if errors.Is(err, gocql.ErrNotFound) {
+			return nil, utils.ErrNotFound
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkRefreshTokenUsed flips the used flag with a lightweight transaction and
+// reports whether this call was the one that did it, so two concurrent
+// refreshes with the same token cannot both succeed. The row keeps its
+// original expiry so a replayed token is still recognised until then.
+func (r *sessionRepository) MarkRefreshTokenUsed(ctx context.Context, token *models.RefreshToken) (bool, error) {
+	ttl := int(time.Until(token.ExpiresAt).Seconds())
+	if ttl < 1 {
+		ttl = 1
+	}
+	query := "UPDATE marketplace_keyspace.refresh_tokens USING TTL ? SET used = true WHERE token_id = ? IF used = false"
+	return r.session.Query(query, ttl, token.TokenID).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+}
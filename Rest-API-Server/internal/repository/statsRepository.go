@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/models"
+	"time"
+)
+
+// StatsRepository keeps the per-day counters of listings and remembers recent
+// viewers so repeated views are counted once. Conversations are counted by
+// the chat server, so Chats is neither stored nor returned here.
+type StatsRepository interface {
+	// ClaimView reports whether viewer has not viewed the listing within
+	// window, and marks it as having viewed it now.
+	ClaimView(ctx context.Context, productID gocql.UUID, viewer string, window time.Duration) (bool, error)
+	AddToDay(ctx context.Context, productID gocql.UUID, day time.Time, counters models.StatCounters) error
+	// Days returns the counters of the days from from to to that have any,
+	// oldest first.
+	Days(ctx context.Context, productID gocql.UUID, from, to time.Time) ([]models.ListingStatsDay, error)
+	DeleteStats(ctx context.Context, productID gocql.UUID) error
+}
+
+type statsRepository struct {
+	session *gocql.Session
+}
+
+func NewStatsRepository(session *gocql.Session) StatsRepository {
+	return &statsRepository{session: session}
+}
+
+func (r *statsRepository) ClaimView(ctx context.Context, productID gocql.UUID, viewer string, window time.Duration) (bool, error) {
+	query := "INSERT INTO marketplace_keyspace.product_recent_viewers(product_id, viewer) VALUES (?, ?) IF NOT EXISTS USING TTL ?"
+	return r.session.Query(query, productID, viewer, int(window.Seconds())).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+}
+
+func (r *statsRepository) AddToDay(ctx context.Context, productID gocql.UUID, day time.Time, counters models.StatCounters) error {
+	query := "UPDATE marketplace_keyspace.listing_stats_daily SET views = views + ?, favorites = favorites + ?, impressions = impressions + ? WHERE product_id = ? AND day = ?"
+	return r.session.Query(query,
+		counters.Views,
+		counters.Favorites,
+		counters.Impressions,
+		productID,
+		day,
+	).WithContext(ctx).Exec()
+}
+
+func (r *statsRepository) Days(ctx context.Context, productID gocql.UUID, from, to time.Time) ([]models.ListingStatsDay, error) {
+	query := "SELECT day, views, favorites, impressions FROM marketplace_keyspace.listing_stats_daily WHERE product_id = ? AND day >= ? AND day <= ?"
+	iter := r.session.Query(query, productID, from, to).WithContext(ctx).Iter()
+
+	var days []models.ListingStatsDay
+	var day time.Time
+	var counters models.StatCounters
+	for iter.Scan(&day, &counters.Views, &counters.Favorites, &counters.Impressions) {
+		days = append(days, models.ListingStatsDay{Day: day.Format(models.DayLayout), StatCounters: counters})
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return days, nil
+}
+
+func (r *statsRepository) DeleteStats(ctx context.Context, productID gocql.UUID) error {
+	query := "DELETE FROM marketplace_keyspace.listing_stats_daily WHERE product_id = ?"
+	return r.session.Query(query, productID).WithContext(ctx).Exec()
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/utils"
+	"time"
+)
+
+type SubscriptionRepository interface {
+	GetSubscription(ctx context.Context, userID gocql.UUID) (*models.Subscription, error)
+	// SaveSubscription writes sub if the stored period still ends at
+	// expectedPeriodEnd, or if there is no subscription when it is nil. It
+	// returns ErrVersionConflict otherwise.
+	SaveSubscription(ctx context.Context, sub *models.Subscription, expectedPeriodEnd *time.Time) error
+}
+
+type subscriptionRepository struct {
+	session *gocql.Session
+}
+
+func NewSubscriptionRepository(session *gocql.Session) SubscriptionRepository {
+	return &subscriptionRepository{session: session}
+}
+
+func (r *subscriptionRepository) GetSubscription(ctx context.Context, userID gocql.UUID) (*models.Subscription, error) {
+	query := "SELECT plan_id, status, period_start, period_end, grace_until, retry_at, pending_plan_id, provider_ref FROM marketplace_keyspace.subscriptions WHERE user_id = ?"
+	sub := models.Subscription{UserID: userID}
+	err := r.session.Query(query, userID).WithContext(ctx).Scan(&sub.PlanID, &sub.Status, &sub.PeriodStart, &sub.PeriodEnd, &sub.GraceUntil, &sub.RetryAt, &sub.PendingPlanID, &sub.ProviderRef)
+	if err != nil {
+		if errors.Is(err, gocql.ErrNotFound) {
+			return nil, utils.ErrNotFound
+		}
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func (r *subscriptionRepository) SaveSubscription(ctx context.Context, sub *models.Subscription, expectedPeriodEnd *time.Time) error {
+	var applied bool
+	var err error
+	if expectedPeriodEnd == nil {
+		query := "INSERT INTO marketplace_keyspace.subscriptions(user_id, plan_id, status, period_start, period_end, grace_until, retry_at, pending_plan_id, provider_ref) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) IF NOT EXISTS"
+		applied, err = r.session.Query(query, sub.UserID, sub.PlanID, sub.Status, sub.PeriodStart, sub.PeriodEnd, sub.GraceUntil, sub.RetryAt, sub.PendingPlanID, sub.ProviderRef).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+	} else {
+		query := "UPDATE marketplace_keyspace.subscriptions SET plan_id = ?, status = ?, period_start = ?, period_end = ?, grace_until = ?, retry_at = ?, pending_plan_id = ?, provider_ref = ? WHERE user_id = ? IF period_end = ?"
+		applied, err = r.session.Query(query, sub.PlanID, sub.Status, sub.PeriodStart, sub.PeriodEnd, sub.GraceUntil, sub.RetryAt, sub.PendingPlanID, sub.ProviderRef, sub.UserID, *expectedPeriodEnd).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+	}
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return utils.ErrVersionConflict
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/utils"
+)
+
+type TwoFactorRepository interface {
+	GetTwoFactor(ctx context.Context, userID gocql.UUID) (*models.TwoFactor, error)
+	SetPendingSecret(ctx context.Context, userID gocql.UUID, secret string) error
+	EnableTwoFactor(ctx context.Context, userID gocql.UUID) error
+	DisableTwoFactor(ctx context.Context, userID gocql.UUID) error
+	MarkStepUsed(ctx context.Context, userID gocql.UUID, previous *int64, step int64) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID gocql.UUID, codeHashes []string) error
+	ConsumeRecoveryCode(ctx context.Context, userID gocql.UUID, codeHash string) (bool, error)
+}
+
+type twoFactorRepository struct {
+	session *gocql.Session
+}
+
+func NewTwoFactorRepository(session *gocql.Session) TwoFactorRepository {
+	return &twoFactorRepository{session: session}
+}
+
+func (r *twoFactorRepository) GetTwoFactor(ctx context.Context, userID gocql.UUID) (*models.TwoFactor, error) {
+	query := "SELECT totp_secret, totp_enabled, totp_last_step FROM marketplace_keyspace.userdata WHERE id = ?"
+	var state models.TwoFactor
+	if err := r.session.Query(query, userID).WithContext(ctx).Scan(&state.Secret, &state.Enabled, &state.LastStep); err != nil {
+		if errors.Is(err, gocql.ErrNotFound) {
+			return nil, utils.ErrNotFound
+		}
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (r *twoFactorRepository) SetPendingSecret(ctx context.Context, userID gocql.UUID, secret string) error {
+	query := "UPDATE marketplace_keyspace.userdata SET totp_secret = ?, totp_enabled = false, totp_last_step = null WHERE id = ? IF EXISTS"
+	applied, err := r.session.Query(query, secret, userID).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return utils.ErrNotFound
+	}
+	return nil
+}
+
+func (r *twoFactorRepository) EnableTwoFactor(ctx context.Context, userID gocql.UUID) error {
+	query := "UPDATE marketplace_keyspace.userdata SET totp_enabled = true WHERE id = ?"
+	return r.session.Query(query, userID).WithContext(ctx).Exec()
+}
+
+func (r *twoFactorRepository) DisableTwoFactor(ctx context.Context, userID gocql.UUID) error {
+	query := "UPDATE marketplace_keyspace.userdata SET totp_secret = null, totp_enabled = false, totp_last_step = null WHERE id = ?"
+	if err := r.session.Query(query, userID).WithContext(ctx).Exec(); err != nil {
+		return err
+	}
+	return r.ReplaceRecoveryCodes(ctx, userID, nil)
+}
+
+// MarkStepUsed records step as the last accepted time step if the stored
+// value is still previous. A false result means another request used a code
+// in the meantime, so the code must be rejected.
+func (r *twoFactorRepository) MarkStepUsed(ctx context.Context, userID gocql.UUID, previous *int64, step int64) (bool, error) {
+	query := "UPDATE marketplace_keyspace.userdata SET totp_last_step = ? WHERE id = ? IF totp_last_step = ?"
+	return r.session.Query(query, step, userID, previous).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+}
+
+func (r *twoFactorRepository) ReplaceRecoveryCodes(ctx context.Context, userID gocql.UUID, codeHashes []string) error {
+	query := "DELETE FROM marketplace_keyspace.recovery_codes WHERE user_id = ?"
+	if err := r.session.Query(query, userID).WithContext(ctx).Exec(); err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		query := "INSERT INTO marketplace_keyspace.recovery_codes(user_id, code_hash) VALUES (?, ?)"
+		if err := r.session.Query(query, userID, hash).WithContext(ctx).Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConsumeRecoveryCode deletes the code with a lightweight transaction, so each
+// code signs in at most once.
+func (r *twoFactorRepository) ConsumeRecoveryCode(ctx context.Context, userID gocql.UUID, codeHash string) (bool, error) {
+	query := "DELETE FROM marketplace_keyspace.recovery_codes WHERE user_id = ? AND code_hash = ? IF EXISTS"
+	return r.session.Query(query, userID, codeHash).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+}
@@ -4,15 +4,24 @@ import (
 	"context"
 	"errors"
 	"github.com/gocql/gocql"
+	"github.com/shopspring/decimal"
+	"log"
 	"marketplace_project/internal/models"
 	"marketplace_project/internal/utils"
+	"strings"
 )
 
 type UserRepository interface {
 	CreateUser(ctx context.Context, user *models.User) error
 	GetUser(ctx context.Context, id gocql.UUID) (*models.UserWrapContent, error)
 	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
-	DeleteUser(ctx context.Context, id string) error
+	GetUserByID(ctx context.Context, id gocql.UUID) (*models.User, error)
+	SetEmailVerified(ctx context.Context, id gocql.UUID) error
+	UpdatePassword(ctx context.Context, id gocql.UUID, passwordHash string) error
+	UpdateAccountType(ctx context.Context, id gocql.UUID, accountType string) error
+	UpdateProfile(ctx context.Context, user *models.User) error
+	UpdateEmail(ctx context.Context, id gocql.UUID, email string) error
+	DeleteUser(ctx context.Context, id gocql.UUID) error
 }
 
 type userRepository struct {
@@ -23,44 +32,98 @@ func NewUserRepository(session *gocql.Session) UserRepository {
 	return &userRepository{session: session}
 }
 
+// emailKey is the form an address is stored in users_by_email, so that the
+// same mailbox cannot be registered twice with different capitalization.
+func emailKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// CreateUser claims the email address in users_by_email before the account is
+// written. The claim is a lightweight transaction, so of two concurrent
+// registrations with the same address only one succeeds; the other gets
+// ErrEmailExists.
 func (r *userRepository) CreateUser(ctx context.Context, user *models.User) error {
-	// Concatenate countryCode and number into phoneNumber string
-	//phoneNumber := user.PhoneNumber.CountryCode + user.PhoneNumber.Number
+	if err := r.claimEmail(ctx, user.Email, user.UserID); err != nil {
+		return err
+	}
 
-	query := "INSERT INTO marketplace_keyspace.userData(id, firstName, lastName, email, password, phoneNumber, avatar, AccountType, Subscription, createdAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	query := "INSERT INTO marketplace_keyspace.userData(id, firstName, lastName, email, email_verified, password, phoneNumber, avatar, AccountType, Subscription, createdAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
 
-	return r.session.Query(query,
+	if err := r.session.Query(query,
 		user.UserID,
 		user.FirstName,
 		user.LastName,
 		user.Email,
+		user.EmailVerified,
 		user.Password,
 		user.PhoneNumber,
 		user.Avatar,
 		user.AccountType,
 		user.Subscription,
 		user.CreatedAt,
-	).WithContext(ctx).Exec()
+	).WithContext(ctx).Exec(); err != nil {
+		r.releaseEmail(ctx, user.Email, user.UserID)
+		return err
+	}
+	return nil
+}
+
+func (r *userRepository) claimEmail(ctx context.Context, email string, userID gocql.UUID) error {
+	query := "INSERT INTO marketplace_keyspace.users_by_email(email, user_id) VALUES (?, ?) IF NOT EXISTS"
+	applied, err := r.session.Query(query, emailKey(email), userID).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return utils.ErrEmailExists
+	}
+	return nil
+}
+
+// releaseEmail frees an address claimed by userID. The condition keeps it from
+// removing a claim that meanwhile belongs to another account.
+func (r *userRepository) releaseEmail(ctx context.Context, email string, userID gocql.UUID) {
+	query := "DELETE FROM marketplace_keyspace.users_by_email WHERE email = ? IF user_id = ?"
+	if _, err := r.session.Query(query, emailKey(email), userID).WithContext(ctx).MapScanCAS(map[string]interface{}{}); err != nil {
+		log.Printf("Failed to release email claim of user %s: %v", userID, err)
+	}
 }
 
 func (r *userRepository) GetUser(ctx context.Context, id gocql.UUID) (*models.UserWrapContent, error) {
 	//phoneNumber := user.PhoneNumber.CountryCode + user.PhoneNumber.Number
-	query := "SELECT id, firstName, lastName, avatar, accountType, rating FROM marketplace_keyspace.userdata WHERE id = ?"
+	query := "SELECT id, firstName, lastName, avatar, accountType, rating_sum, rating_count FROM marketplace_keyspace.userdata WHERE id = ?"
 	var user models.UserWrapContent
-	err := r.session.Query(query, id).WithContext(ctx).Scan(&user.UserID, &user.FirstName, &user.LastName, &user.Avatar, &user.AccountType, &user.Rating)
+	var ratingSum int
+	err := r.session.Query(query, id).WithContext(ctx).Scan(&user.UserID, &user.FirstName, &user.LastName, &user.Avatar, &user.AccountType, &ratingSum, &user.ReviewCount)
 	if err != nil {
 		if errors.Is(err, gocql.ErrNotFound) {
 			return nil, utils.ErrNotFound
 		}
 		return nil, err
 	}
+	if user.ReviewCount > 0 {
+		rating := decimal.NewFromInt(int64(ratingSum)).DivRound(decimal.NewFromInt(int64(user.ReviewCount)), 2)
+		user.Rating = &rating
+	}
 	return &user, nil
 }
 
 func (r *userRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
-	query := "SELECT id, firstName, lastName, email, password, phonenumber, avatar, accountType, subscription, createdat FROM marketplace_keyspace.userdata WHERE email = ? ALLOW FILTERING "
+	query := "SELECT user_id FROM marketplace_keyspace.users_by_email WHERE email = ?"
+	var userID gocql.UUID
+	if err := r.session.Query(query, emailKey(email)).WithContext(ctx).Scan(&userID); err != nil {
+		if errors.Is(err, gocql.ErrNotFound) {
+			return nil, utils.ErrNotFound
+		}
+		return nil, err
+	}
+	return r.GetUserByID(ctx, userID)
+}
+
+func (r *userRepository) GetUserByID(ctx context.Context, id gocql.UUID) (*models.User, error) {
+	query := "SELECT id, firstName, lastName, email, email_verified, totp_enabled, password, phonenumber, avatar, accountType, subscription, createdat FROM marketplace_keyspace.userdata WHERE id = ?"
 	var user models.User
-	err := r.session.Query(query, email).WithContext(ctx).Scan(&user.UserID, &user.FirstName, &user.LastName, &user.Email, &user.Password, &user.PhoneNumber, &user.Avatar, &user.AccountType, &user.Subscription, &user.CreatedAt)
+	err := r.session.Query(query, id).WithContext(ctx).Scan(&user.UserID, &user.FirstName, &user.LastName, &user.Email, &user.EmailVerified, &user.TwoFactorEnabled, &user.Password, &user.PhoneNumber, &user.Avatar, &user.AccountType, &user.Subscription, &user.CreatedAt)
 	if err != nil {
 		if errors.Is(err, gocql.ErrNotFound) {
 			return nil, utils.ErrNotFound
@@ -70,7 +133,82 @@ func (r *userRepository) GetUserByEmail(ctx context.Context, email string) (*mod
 	return &user, nil
 }
 
-func (r *userRepository) DeleteUser(ctx context.Context, id string) error {
-	query := "DELETE FROM marketplace_keyspace.userdata WHERE id = ?"
+func (r *userRepository) SetEmailVerified(ctx context.Context, id gocql.UUID) error {
+	query := "UPDATE marketplace_keyspace.userdata SET email_verified = true WHERE id = ?"
 	return r.session.Query(query, id).WithContext(ctx).Exec()
 }
+
+func (r *userRepository) UpdatePassword(ctx context.Context, id gocql.UUID, passwordHash string) error {
+	query := "UPDATE marketplace_keyspace.userdata SET password = ? WHERE id = ?"
+	return r.session.Query(query, passwordHash, id).WithContext(ctx).Exec()
+}
+
+func (r *userRepository) UpdateAccountType(ctx context.Context, id gocql.UUID, accountType string) error {
+	query := "UPDATE marketplace_keyspace.userdata SET accountType = ? WHERE id = ? IF EXISTS"
+	applied, err := r.session.Query(query, accountType, id).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return utils.ErrNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) UpdateProfile(ctx context.Context, user *models.User) error {
+	query := "UPDATE marketplace_keyspace.userdata SET firstName = ?, lastName = ?, avatar = ?, phoneNumber = ? WHERE id = ? IF EXISTS"
+	applied, err := r.session.Query(query, user.FirstName, user.LastName, user.Avatar, user.PhoneNumber, user.UserID).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return utils.ErrNotFound
+	}
+	return nil
+}
+
+// UpdateEmail replaces the address of the account. The new address has been
+// confirmed through a link sent to it, so it is stored as verified. It is
+// claimed before the account changes and the old claim is released after.
+func (r *userRepository) UpdateEmail(ctx context.Context, id gocql.UUID, email string) error {
+	user, err := r.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	// A change of capitalization keeps the claim the account already holds.
+	sameKey := emailKey(user.Email) == emailKey(email)
+	if !sameKey {
+		if err := r.claimEmail(ctx, email, id); err != nil {
+			return err
+		}
+	}
+
+	query := "UPDATE marketplace_keyspace.userdata SET email = ?, email_verified = true WHERE id = ? IF EXISTS"
+	applied, err := r.session.Query(query, email, id).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+	if err != nil || !applied {
+		if !sameKey {
+			r.releaseEmail(ctx, email, id)
+		}
+		if err != nil {
+			return err
+		}
+		return utils.ErrNotFound
+	}
+	if !sameKey {
+		r.releaseEmail(ctx, user.Email, id)
+	}
+	return nil
+}
+
+func (r *userRepository) DeleteUser(ctx context.Context, id gocql.UUID) error {
+	user, err := r.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	query := "DELETE FROM marketplace_keyspace.userdata WHERE id = ?"
+	if err := r.session.Query(query, id).WithContext(ctx).Exec(); err != nil {
+		return err
+	}
+	r.releaseEmail(ctx, user.Email, id)
+	return nil
+}
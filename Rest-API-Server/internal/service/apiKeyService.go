@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/repository"
+	"marketplace_project/internal/utils"
+	"time"
+)
+
+type APIKeyService struct {
+	repo          repository.APIKeyRepository
+	userRepo      repository.UserRepository
+	subscriptions *SubscriptionService
+}
+
+func NewAPIKeyService(repo repository.APIKeyRepository, userRepo repository.UserRepository, subscriptions *SubscriptionService) *APIKeyService {
+	return &APIKeyService{repo: repo, userRepo: userRepo, subscriptions: subscriptions}
+}
+
+// Create issues a key for a business account and returns it together with
+// the secret, which is not stored and cannot be shown again.
+func (s *APIKeyService) Create(ctx context.Context, userID gocql.UUID, name string, scopes []string, ttl time.Duration) (*models.APIKey, string, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if models.RoleFromAccountType(user.AccountType) != models.RoleBusiness {
+		return nil, "", utils.ErrInvalidAccountType
+	}
+	if len(scopes) == 0 {
+		return nil, "", utils.ErrInvalidScope
+	}
+	for _, scope := range scopes {
+		if !knownScope(scope) {
+			return nil, "", utils.ErrInvalidScope
+		}
+	}
+
+	plan, err := s.subscriptions.CurrentPlan(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	existing, err := s.repo.ListAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	// Revoked keys are deleted; expired ones stay listed but no longer take
+	// up a place.
+	now := time.Now()
+	active := 0
+	for i := range existing {
+		if !existing[i].Expired(now) {
+			active++
+		}
+	}
+	if active >= plan.MaxAPIKeys {
+		return nil, "", utils.ErrLimitReached
+	}
+
+	secret, prefix, err := utils.GenerateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+	key := models.APIKey{
+		KeyID:     gocql.TimeUUID(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+		KeyHash:   utils.HashAPIKey(secret),
+	}
+	if ttl > 0 {
+		expiresAt := key.CreatedAt.Add(ttl)
+		key.ExpiresAt = &expiresAt
+	}
+	if err := s.repo.CreateAPIKey(ctx, &key); err != nil {
+		return nil, "", err
+	}
+	return &key, secret, nil
+}
+
+func (s *APIKeyService) List(ctx context.Context, userID gocql.UUID) ([]models.APIKey, error) {
+	return s.repo.ListAPIKeys(ctx, userID)
+}
+
+func (s *APIKeyService) Revoke(ctx context.Context, userID gocql.UUID, keyID gocql.UUID) error {
+	return s.repo.DeleteAPIKey(ctx, userID, keyID)
+}
+
+// Authenticate resolves a presented key. Keys stop working when they expire
+// or when their account is no longer a business account.
+func (s *APIKeyService) Authenticate(ctx context.Context, secret string) (*models.APIKeyIdentity, error) {
+	key, err := s.repo.GetAPIKeyByHash(ctx, utils.HashAPIKey(secret))
+	if err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			return nil, utils.ErrInvalidToken
+		}
+		return nil, err
+	}
+	if key.Expired(time.Now()) {
+		return nil, utils.ErrInvalidToken
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, key.UserID)
+	if err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			return nil, utils.ErrInvalidToken
+		}
+		return nil, err
+	}
+	role := models.RoleFromAccountType(user.AccountType)
+	if role != models.RoleBusiness {
+		return nil, utils.ErrInvalidToken
+	}
+	return &models.APIKeyIdentity{
+		Key:           key,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Role:          role,
+	}, nil
+}
+
+func knownScope(scope string) bool {
+	for _, known := range models.APIKeyScopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
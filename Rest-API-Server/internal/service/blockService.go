@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"github.com/gocql/gocql"
+	"log"
+	"marketplace_project/internal/chat"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/repository"
+	"marketplace_project/internal/utils"
+	"time"
+)
+
+// BlockService manages the accounts a user has blocked. The chat server asks
+// for them to keep blocked users from messaging the blocker or seeing when
+// they are online.
+type BlockService struct {
+	repo     repository.BlockRepository
+	userRepo repository.UserRepository
+	feed     *FeedService
+	chat     chat.Client
+}
+
+func NewBlockService(repo repository.BlockRepository, userRepo repository.UserRepository, feed *FeedService, chatClient chat.Client) *BlockService {
+	return &BlockService{repo: repo, userRepo: userRepo, feed: feed, chat: chatClient}
+}
+
+// Block adds blockedID to the block list of blockerID and ends any follow
+// between the two accounts, in either direction.
+func (s *BlockService) Block(ctx context.Context, blockerID, blockedID gocql.UUID) error {
+	if blockerID == blockedID {
+		return utils.ErrSelfAction
+	}
+	if _, err := s.userRepo.GetUser(ctx, blockedID); err != nil {
+		return err
+	}
+	if err := s.repo.Block(ctx, blockerID, blockedID, time.Now()); err != nil {
+		return err
+	}
+	s.notifyChat(ctx, blockerID, blockedID)
+	if err := s.feed.Unfollow(ctx, blockerID, blockedID); err != nil {
+		return err
+	}
+	return s.feed.Unfollow(ctx, blockedID, blockerID)
+}
+
+func (s *BlockService) Unblock(ctx context.Context, blockerID, blockedID gocql.UUID) error {
+	if err := s.repo.Unblock(ctx, blockerID, blockedID); err != nil {
+		return err
+	}
+	s.notifyChat(ctx, blockerID, blockedID)
+	return nil
+}
+
+// notifyChat tells the chat server that the block lists of both users
+// changed. A failure is only logged: the chat server forgets cached lists
+// after a short time anyway.
+func (s *BlockService) notifyChat(ctx context.Context, blockerID, blockedID gocql.UUID) {
+	if err := s.chat.BlocksChanged(ctx, blockerID, blockedID); err != nil {
+		log.Printf("Failed to tell the chat server about the block between %s and %s: %v", blockerID, blockedID, err)
+	}
+}
+
+func (s *BlockService) Blocked(ctx context.Context, blockerID gocql.UUID, page models.Page) ([]models.UserWrapContent, []byte, error) {
+	ids, next, err := s.repo.ListBlocked(ctx, blockerID, page)
+	if err != nil {
+		return nil, nil, err
+	}
+	users, err := s.feed.users(ctx, ids)
+	return users, next, err
+}
+
+// BlockedOwners returns the accounts userID has blocked, as a set for
+// filtering listings.
+func (s *BlockService) BlockedOwners(ctx context.Context, userID gocql.UUID) (map[gocql.UUID]bool, error) {
+	ids, err := s.repo.AllBlocked(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	owners := make(map[gocql.UUID]bool, len(ids))
+	for _, id := range ids {
+		owners[id] = true
+	}
+	return owners, nil
+}
+
+// RelatedUsers returns the accounts userID must not interact with: those it
+// blocked and those that blocked it. The direction is deliberately not
+// reported.
+func (s *BlockService) RelatedUsers(ctx context.Context, userID gocql.UUID) ([]gocql.UUID, error) {
+	blocked, err := s.repo.AllBlocked(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	blockers, err := s.repo.AllBlockers(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[gocql.UUID]bool, len(blocked)+len(blockers))
+	related := make([]gocql.UUID, 0, len(blocked)+len(blockers))
+	for _, id := range append(blocked, blockers...) {
+		if !seen[id] {
+			seen[id] = true
+			related = append(related, id)
+		}
+	}
+	return related, nil
+}
+
+// blockedEitherWay reports whether either account has blocked the other.
+func blockedEitherWay(ctx context.Context, repo repository.BlockRepository, userID, otherID gocql.UUID) (bool, error) {
+	blocked, err := repo.IsBlocked(ctx, userID, otherID)
+	if err != nil || blocked {
+		return blocked, err
+	}
+	return repo.IsBlocked(ctx, otherID, userID)
+}
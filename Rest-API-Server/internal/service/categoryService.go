@@ -41,6 +41,10 @@ func (s *CategoryService) AddSubcategory(subcategoryWithParam *models.Subcategor
 	return s.repo.CreateSubcategory(context.Background(), subcategoryWithParam)
 }
 
+func (s *CategoryService) DeleteCategory(ctx context.Context, categoryID gocql.UUID) error {
+	return s.repo.DeleteCategory(ctx, categoryID)
+}
+
 func (s *CategoryService) GetCategoryDataByName(ctx context.Context, name string) (*models.Category, error) {
 	return s.repo.GetCategoryDataByName(ctx, name)
 }
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/gocql/gocql"
+	"log"
+	"marketplace_project/config"
+	"marketplace_project/internal/mailer"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/repository"
+	"time"
+)
+
+const (
+	expiryLeaseName = "listing-expiry"
+	// deadlineLookbackDays is how many past days are searched for deadlines
+	// that were not handled on time, for example while no replica was up.
+	deadlineLookbackDays = 7
+	// maxDeadlinesPerRun bounds the work of one run so that it finishes well
+	// within the lease.
+	maxDeadlinesPerRun = 500
+)
+
+// ExpiryScheduler tells sellers about listings that are about to expire and
+// expires the ones whose period has run out. Every replica runs it; a
+// Cassandra lease makes sure only one of them handles deadlines at a time.
+// Expiring is conditional on the listing's version, so a replica that lost
+// the lease in the middle of a run cannot undo a renewal.
+type ExpiryScheduler struct {
+	products  *ProductService
+	deadlines repository.ExpiryRepository
+	leases    repository.LeaseRepository
+	userRepo  repository.UserRepository
+	mailer    mailer.Mailer
+	appURL    string
+	cfg       config.ExpiryConfig
+	holder    string
+}
+
+func NewExpiryScheduler(products *ProductService, deadlines repository.ExpiryRepository, leases repository.LeaseRepository, userRepo repository.UserRepository, mail mailer.Mailer, appURL string, cfg config.ExpiryConfig) *ExpiryScheduler {
+	return &ExpiryScheduler{
+		products:  products,
+		deadlines: deadlines,
+		leases:    leases,
+		userRepo:  userRepo,
+		mailer:    mail,
+		appURL:    appURL,
+		cfg:       cfg,
+		holder:    gocql.TimeUUID().String(),
+	}
+}
+
+// Run handles due deadlines every CheckInterval until ctx is done.
+func (s *ExpiryScheduler) Run(ctx context.Context) {
+	if s.cfg.CheckInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.cfg.CheckInterval)
+	defer ticker.Stop()
+	for {
+		s.runOnce(ctx)
+		select {
+		case <-ctx.Done():
+			if err := s.leases.Release(context.Background(), expiryLeaseName, s.holder); err != nil {
+				log.Printf("Failed to release the listing expiry lease: %v", err)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *ExpiryScheduler) runOnce(ctx context.Context) {
+	held, err := s.leases.Acquire(ctx, expiryLeaseName, s.holder, s.cfg.LeaseTTL)
+	if err != nil {
+		log.Printf("Failed to acquire the listing expiry lease: %v", err)
+		return
+	}
+	if !held {
+		return
+	}
+
+	now := time.Now()
+	remaining := maxDeadlinesPerRun
+	for days := deadlineLookbackDays; days >= 0 && remaining > 0; days-- {
+		deadlines, err := s.deadlines.DueDeadlines(ctx, models.DeadlineDay(now.AddDate(0, 0, -days)), now, remaining)
+		if err != nil {
+			log.Printf("Failed to load listing deadlines: %v", err)
+			return
+		}
+		for _, deadline := range deadlines {
+			remaining--
+			// A deadline that fails stays and is tried again in the next run.
+			if err := s.handle(ctx, deadline); err != nil {
+				log.Printf("Failed to handle the %s deadline of listing %s: %v", deadline.Kind, deadline.ProductID, err)
+				continue
+			}
+			if err := s.deadlines.RemoveDeadline(ctx, deadline); err != nil {
+				log.Printf("Failed to remove the %s deadline of listing %s: %v", deadline.Kind, deadline.ProductID, err)
+			}
+		}
+	}
+}
+
+func (s *ExpiryScheduler) handle(ctx context.Context, deadline models.ListingDeadline) error {
+	product, err := s.products.DeadlineListing(ctx, deadline)
+	if err != nil || product == nil {
+		return err
+	}
+	switch deadline.Kind {
+	case models.DeadlineNotice:
+		return s.notify(ctx, product)
+	case models.DeadlineExpire:
+		return s.products.Expire(ctx, product)
+	}
+	return nil
+}
+
+func (s *ExpiryScheduler) notify(ctx context.Context, product *models.Product) error {
+	user, err := s.userRepo.GetUserByID(ctx, product.OwnerID)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your listing is about to expire",
+		Body: fmt.Sprintf("Hi %s,\n\nYour listing \"%s\" expires on %s and will then no longer be shown to buyers. You can renew it with one click from your listings at %s.\n",
+			user.FirstName, product.Title, product.ExpiresAt.UTC().Format(time.RFC1123), s.appURL),
+	})
+}
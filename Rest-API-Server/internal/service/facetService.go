@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"marketplace_project/config"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/repository"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxFacetRows bounds how many filter rows, and separately how many price
+	// rows, one facet computation reads.
+	maxFacetRows = 50000
+	// maxCachedFacets bounds the number of filtered facet results kept.
+	maxCachedFacets = 1000
+)
+
+// FacetService counts active listings per filter value for the filter panel
+// of a subcategory. Without filters the maintained counters are returned as
+// they are. With filters every facet is counted over the listings that meet all
+// the other filters, so the panel still shows how many listings picking
+// another value would give; those results are computed from the
+// subcategory's filter rows and cached for a short time.
+type FacetService struct {
+	repo     repository.FacetRepository
+	cacheTTL time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedFacets
+}
+
+type cachedFacets struct {
+	counts    *models.FacetCounts
+	expiresAt time.Time
+}
+
+func NewFacetService(repo repository.FacetRepository, cfg config.FacetsConfig) *FacetService {
+	return &FacetService{repo: repo, cacheTTL: cfg.CacheTTL, cache: make(map[string]cachedFacets)}
+}
+
+func (s *FacetService) Facets(ctx context.Context, query models.FilterQuery) (*models.FacetCounts, error) {
+	if len(query.Conditions) == 0 && query.PriceMin == nil && query.PriceMax == nil {
+		facets, err := s.repo.FilterCounts(ctx, query.CategoryID, query.SubcategoryID)
+		if err != nil {
+			return nil, err
+		}
+		return &models.FacetCounts{Facets: facets}, nil
+	}
+
+	key := facetCacheKey(query)
+	if counts, ok := s.cached(key); ok {
+		return counts, nil
+	}
+	counts, err := s.count(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	s.store(key, counts)
+	return counts, nil
+}
+
+func (s *FacetService) count(ctx context.Context, query models.FilterQuery) (*models.FacetCounts, error) {
+	listings, filtersComplete, err := s.repo.ListingFilters(ctx, query.CategoryID, query.SubcategoryID, maxFacetRows)
+	if err != nil {
+		return nil, err
+	}
+	// Only active listings have a price ranking, so it also tells which
+	// listings to count.
+	prices, pricesComplete, err := s.repo.ListingPrices(ctx, query.CategoryID, query.SubcategoryID, maxFacetRows)
+	if err != nil {
+		return nil, err
+	}
+
+	facets := models.Facets{}
+	for productID, values := range listings {
+		price, ok := prices[productID]
+		if !ok || (query.PriceMin != nil && price < *query.PriceMin) || (query.PriceMax != nil && price > *query.PriceMax) {
+			continue
+		}
+
+		// A listing that fails no condition counts in every facet; one that
+		// fails only the condition on one filter counts in that filter's
+		// facet, where choosing another value would include it.
+		failed := ""
+		failures := 0
+		for _, condition := range query.Conditions {
+			if !condition.Matches(values[condition.Name]) {
+				failed = condition.Name
+				failures++
+			}
+		}
+		if failures > 1 {
+			continue
+		}
+		for name, nameValues := range values {
+			if failures == 1 && name != failed {
+				continue
+			}
+			for _, value := range nameValues {
+				facets.Add(name, value, 1)
+			}
+		}
+	}
+	return &models.FacetCounts{Facets: facets, Partial: !filtersComplete || !pricesComplete}, nil
+}
+
+func (s *FacetService) cached(key string) (*models.FacetCounts, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.cache[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.counts, true
+}
+
+func (s *FacetService) store(key string, counts *models.FacetCounts) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if len(s.cache) >= maxCachedFacets {
+		for k, entry := range s.cache {
+			if now.After(entry.expiresAt) {
+				delete(s.cache, k)
+			}
+		}
+	}
+	if len(s.cache) >= maxCachedFacets {
+		s.cache = make(map[string]cachedFacets)
+	}
+	s.cache[key] = cachedFacets{counts: counts, expiresAt: now.Add(s.cacheTTL)}
+}
+
+// facetCacheKey identifies a query. Conditions come sorted by name from the
+// handler.
+func facetCacheKey(query models.FilterQuery) string {
+	var key strings.Builder
+	fmt.Fprintf(&key, "%s/%s", query.CategoryID, query.SubcategoryID)
+	if query.PriceMin != nil {
+		fmt.Fprintf(&key, "|price>=%d", *query.PriceMin)
+	}
+	if query.PriceMax != nil {
+		fmt.Fprintf(&key, "|price<=%d", *query.PriceMax)
+	}
+	for _, condition := range query.Conditions {
+		fmt.Fprintf(&key, "|%q=%q", condition.Name, condition.Values)
+		if condition.Min != nil {
+			fmt.Fprintf(&key, ">=%g", *condition.Min)
+		}
+		if condition.Max != nil {
+			fmt.Fprintf(&key, "<=%g", *condition.Max)
+		}
+	}
+	return key.String()
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/repository"
+	"time"
+)
+
+// FavoriteService manages the listings users save for later.
+type FavoriteService struct {
+	repo        repository.FavoriteRepository
+	productRepo repository.ProductRepository
+	stats       *StatsService
+}
+
+func NewFavoriteService(repo repository.FavoriteRepository, productRepo repository.ProductRepository, stats *StatsService) *FavoriteService {
+	return &FavoriteService{repo: repo, productRepo: productRepo, stats: stats}
+}
+
+// Favorite saves the listing for userID. Saving it again changes nothing, and
+// owners saving their own listing are not counted in its statistics.
+func (s *FavoriteService) Favorite(ctx context.Context, userID, productID gocql.UUID) error {
+	ownerID, err := s.productRepo.GetProductOwner(ctx, productID)
+	if err != nil {
+		return err
+	}
+	added, err := s.repo.AddFavorite(ctx, userID, productID, time.Now())
+	if err != nil {
+		return err
+	}
+	if added && ownerID != userID {
+		s.stats.RecordFavorite(ctx, productID)
+	}
+	return nil
+}
+
+func (s *FavoriteService) Unfavorite(ctx context.Context, userID, productID gocql.UUID) error {
+	return s.repo.RemoveFavorite(ctx, userID, productID)
+}
+
+// Favorites lists the saved listings of userID, skipping deleted ones.
+func (s *FavoriteService) Favorites(ctx context.Context, userID gocql.UUID, page models.Page) ([]models.ProductWrapContent, []byte, error) {
+	ids, next, err := s.repo.ListFavorites(ctx, userID, page)
+	if err != nil {
+		return nil, nil, err
+	}
+	products := make([]models.ProductWrapContent, 0, len(ids))
+	for _, id := range ids {
+		product, err := s.productRepo.FindProductsByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, gocql.ErrNotFound) {
+				continue
+			}
+			return nil, nil, err
+		}
+		products = append(products, *product)
+	}
+	return products, next, nil
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/gocql/gocql"
+	"log"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/repository"
+	"marketplace_project/internal/utils"
+	"time"
+)
+
+const (
+	// feedItemTTL drops listings from timelines once they are too old to be
+	// news, which also bounds the size of a timeline partition.
+	feedItemTTL = 30 * 24 * time.Hour
+	// feedBackfillSize is how many of a seller's latest listings are copied
+	// into the timeline of a new follower.
+	feedBackfillSize = 20
+)
+
+// FeedService manages follows and fans new listings out to the timelines of
+// the seller's followers.
+type FeedService struct {
+	followRepo  repository.FollowRepository
+	feedRepo    repository.FeedRepository
+	productRepo repository.ProductRepository
+	userRepo    repository.UserRepository
+	blockRepo   repository.BlockRepository
+}
+
+func NewFeedService(followRepo repository.FollowRepository, feedRepo repository.FeedRepository, productRepo repository.ProductRepository, userRepo repository.UserRepository, blockRepo repository.BlockRepository) *FeedService {
+	return &FeedService{followRepo: followRepo, feedRepo: feedRepo, productRepo: productRepo, userRepo: userRepo, blockRepo: blockRepo}
+}
+
+// Follow subscribes followerID to sellerID and fills the follower's feed with
+// the seller's latest listings. Accounts that blocked each other cannot
+// follow one another.
+func (s *FeedService) Follow(ctx context.Context, followerID, sellerID gocql.UUID) error {
+	if followerID == sellerID {
+		return utils.ErrSelfAction
+	}
+	if _, err := s.userRepo.GetUser(ctx, sellerID); err != nil {
+		return err
+	}
+	blocked, err := blockedEitherWay(ctx, s.blockRepo, followerID, sellerID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return utils.ErrForbidden
+	}
+	if err := s.followRepo.Follow(ctx, followerID, sellerID, time.Now()); err != nil {
+		return err
+	}
+
+	products, err := s.productRepo.LatestByOwner(ctx, sellerID, feedBackfillSize)
+	if err != nil {
+		return err
+	}
+	for _, product := range products {
+		item := models.FeedItem{ProductWrapContent: product, SellerID: sellerID}
+		if err := s.feedRepo.AddToTimeline(ctx, followerID, item, feedItemTTL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unfollow removes the follow and the seller's listings from the feed.
+func (s *FeedService) Unfollow(ctx context.Context, followerID, sellerID gocql.UUID) error {
+	if err := s.followRepo.Unfollow(ctx, followerID, sellerID); err != nil {
+		return err
+	}
+	return s.feedRepo.RemoveSellerFromTimeline(ctx, followerID, sellerID)
+}
+
+func (s *FeedService) Followers(ctx context.Context, sellerID gocql.UUID, page models.Page) ([]models.UserWrapContent, []byte, error) {
+	ids, next, err := s.followRepo.ListFollowers(ctx, sellerID, page)
+	if err != nil {
+		return nil, nil, err
+	}
+	users, err := s.users(ctx, ids)
+	return users, next, err
+}
+
+func (s *FeedService) Following(ctx context.Context, followerID gocql.UUID, page models.Page) ([]models.UserWrapContent, []byte, error) {
+	ids, next, err := s.followRepo.ListFollowing(ctx, followerID, page)
+	if err != nil {
+		return nil, nil, err
+	}
+	users, err := s.users(ctx, ids)
+	return users, next, err
+}
+
+// users loads the public profiles of ids, skipping deleted accounts.
+func (s *FeedService) users(ctx context.Context, ids []gocql.UUID) ([]models.UserWrapContent, error) {
+	users := make([]models.UserWrapContent, 0, len(ids))
+	for _, id := range ids {
+		user, err := s.userRepo.GetUser(ctx, id)
+		if err != nil {
+			if errors.Is(err, utils.ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+	return users, nil
+}
+
+func (s *FeedService) Feed(ctx context.Context, userID gocql.UUID, page models.Page) ([]models.FeedItem, []byte, error) {
+	return s.feedRepo.Timeline(ctx, userID, page)
+}
+
+// PublishProduct writes a new or changed listing into the feeds of the
+// owner's followers. It runs in the background so that saving a listing does
+// not wait for every follower; a failure is logged and does not undo the
+// listing.
+func (s *FeedService) PublishProduct(product *models.Product) {
+	item := models.FeedItem{
+		ProductWrapContent: models.ProductWrapContent{
+			ProductID: product.ProductID,
+			Title:     product.Title,
+			Price:     product.Price,
+		},
+		SellerID: product.OwnerID,
+	}
+	if len(product.Images) > 0 {
+		item.Image = product.Images[0]
+	}
+	ownerID := product.OwnerID
+	go func() {
+		ctx := context.Background()
+		err := s.followRepo.AllFollowers(ctx, ownerID, func(followerID gocql.UUID) error {
+			return s.feedRepo.AddToTimeline(ctx, followerID, item, feedItemTTL)
+		})
+		if err != nil {
+			log.Printf("Failed to publish product %s to feeds: %v", item.ProductID, err)
+		}
+	}()
+}
+
+// RemoveProduct takes a deleted listing out of the feeds of the owner's
+// followers, in the background like PublishProduct.
+func (s *FeedService) RemoveProduct(ownerID, productID gocql.UUID) {
+	go func() {
+		ctx := context.Background()
+		err := s.followRepo.AllFollowers(ctx, ownerID, func(followerID gocql.UUID) error {
+			return s.feedRepo.RemoveFromTimeline(ctx, followerID, productID)
+		})
+		if err != nil {
+			log.Printf("Failed to remove product %s from feeds: %v", productID, err)
+		}
+	}()
+}
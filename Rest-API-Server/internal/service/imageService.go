@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/gocql/gocql"
+	"io"
+	"log"
+	"marketplace_project/config"
+	"marketplace_project/internal/blobstore"
+	"marketplace_project/internal/imaging"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/repository"
+	"marketplace_project/internal/utils"
+	"time"
+)
+
+// ImageService stores uploaded pictures and resolves the image IDs listings
+// and profiles are saved with to the URLs they display.
+type ImageService struct {
+	repo      repository.ImageRepository
+	store     blobstore.BlobStore
+	publicURL string
+	maxBytes  int64
+}
+
+func NewImageService(repo repository.ImageRepository, store blobstore.BlobStore, cfg config.ImagesConfig) *ImageService {
+	return &ImageService{repo: repo, store: store, publicURL: cfg.PublicURL, maxBytes: int64(cfg.MaxBytes)}
+}
+
+// MaxBytes is the size of the largest upload accepted.
+func (s *ImageService) MaxBytes() int64 {
+	return s.maxBytes
+}
+
+// Upload processes a picture into its variants and stores them. It fails
+// with ErrUnsupportedImage when data is not a picture that can be processed.
+func (s *ImageService) Upload(ctx context.Context, ownerID gocql.UUID, data []byte) (*models.Image, error) {
+	variants, err := imaging.Process(data)
+	if err != nil {
+		if errors.Is(err, imaging.ErrUnsupportedFormat) || errors.Is(err, imaging.ErrTooManyPixels) {
+			return nil, utils.ErrUnsupportedImage
+		}
+		return nil, err
+	}
+
+	image := &models.Image{
+		ImageID:   gocql.TimeUUID(),
+		OwnerID:   ownerID,
+		Width:     variants[0].Width,
+		Height:    variants[0].Height,
+		CreatedAt: time.Now(),
+		Keys:      make(map[string]string),
+	}
+	for _, variant := range variants {
+		key := "images/" + image.ImageID.String() + "/" + variant.Name + variant.Extension
+		if err := s.store.Put(ctx, key, variant.ContentType, variant.Data); err != nil {
+			s.removeFiles(ctx, image.Keys)
+			return nil, err
+		}
+		image.Keys[variant.Name] = key
+	}
+	if err := s.repo.AddImage(ctx, image); err != nil {
+		s.removeFiles(ctx, image.Keys)
+		return nil, err
+	}
+	s.setURLs(image)
+	return image, nil
+}
+
+func (s *ImageService) Image(ctx context.Context, imageID gocql.UUID) (*models.Image, error) {
+	image, err := s.repo.GetImage(ctx, imageID)
+	if err != nil {
+		return nil, err
+	}
+	s.setURLs(image)
+	return image, nil
+}
+
+// Open returns a stored file for serving.
+func (s *ImageService) Open(ctx context.Context, key string) (io.ReadCloser, string, error) {
+	file, contentType, err := s.store.Get(ctx, key)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		return nil, "", utils.ErrNotFound
+	}
+	return file, contentType, err
+}
+
+// VariantURLs returns the URL of the given variant of each image. Every image
+// must have been uploaded by ownerID; otherwise it fails with
+// ErrUnknownImage.
+func (s *ImageService) VariantURLs(ctx context.Context, ownerID gocql.UUID, imageIDs []string, variant string) ([]string, error) {
+	urls := make([]string, 0, len(imageIDs))
+	for _, rawID := range imageIDs {
+		imageID, err := gocql.ParseUUID(rawID)
+		if err != nil {
+			return nil, utils.ErrUnknownImage
+		}
+		image, err := s.repo.GetImage(ctx, imageID)
+		if err != nil {
+			if errors.Is(err, utils.ErrNotFound) {
+				return nil, utils.ErrUnknownImage
+			}
+			return nil, err
+		}
+		if image.OwnerID != ownerID {
+			return nil, utils.ErrUnknownImage
+		}
+		key, ok := image.Keys[variant]
+		if !ok {
+			return nil, utils.ErrUnknownImage
+		}
+		urls = append(urls, s.publicURL+"/"+key)
+	}
+	return urls, nil
+}
+
+func (s *ImageService) setURLs(image *models.Image) {
+	image.Variants = make(map[string]string, len(image.Keys))
+	for variant, key := range image.Keys {
+		image.Variants[variant] = s.publicURL + "/" + key
+	}
+}
+
+// removeFiles deletes the files of an upload that could not be completed.
+func (s *ImageService) removeFiles(ctx context.Context, keys map[string]string) {
+	for _, key := range keys {
+		if err := s.store.Delete(ctx, key); err != nil {
+			log.Printf("Failed to remove image file %s: %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"github.com/gocql/gocql"
+	"marketplace_project/config"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/repository"
+	"time"
+)
+
+// ListingExpiry works out when listings expire and schedules the deadlines
+// the ExpiryScheduler acts on.
+type ListingExpiry struct {
+	deadlines     repository.ExpiryRepository
+	subscriptions *SubscriptionService
+	notice        time.Duration
+}
+
+func NewListingExpiry(deadlines repository.ExpiryRepository, subscriptions *SubscriptionService, cfg config.ExpiryConfig) *ListingExpiry {
+	return &ListingExpiry{deadlines: deadlines, subscriptions: subscriptions, notice: cfg.NoticePeriod}
+}
+
+// Start gives product a new period under its owner's plan, beginning now,
+// and schedules the seller's notice and the expiry. It is called before the
+// listing is saved; deadlines of a listing that then is not saved find it
+// unchanged and are dropped.
+func (e *ListingExpiry) Start(ctx context.Context, product *models.Product) error {
+	plan, err := e.subscriptions.CurrentPlan(ctx, product.OwnerID)
+	if err != nil {
+		return err
+	}
+	// Cassandra keeps timestamps to the millisecond, so the deadlines are
+	// compared with the stored expiry at that precision.
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	expiresAt := now.Add(plan.ListingPeriod())
+	if err := e.schedule(ctx, product.ProductID, expiresAt, now); err != nil {
+		return err
+	}
+	product.ExpiresAt = &expiresAt
+	return nil
+}
+
+func (e *ListingExpiry) schedule(ctx context.Context, productID gocql.UUID, expiresAt, now time.Time) error {
+	if noticeAt := expiresAt.Add(-e.notice); noticeAt.After(now) {
+		notice := models.ListingDeadline{ProductID: productID, Kind: models.DeadlineNotice, DueAt: noticeAt, ExpiresAt: expiresAt}
+		if err := e.deadlines.AddDeadline(ctx, notice); err != nil {
+			return err
+		}
+	}
+	expiry := models.ListingDeadline{ProductID: productID, Kind: models.DeadlineExpire, DueAt: expiresAt, ExpiresAt: expiresAt}
+	return e.deadlines.AddDeadline(ctx, expiry)
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gocql/gocql"
+	"log"
+	"marketplace_project/config"
+	"marketplace_project/internal/mailer"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/repository"
+	"marketplace_project/internal/utils"
+	"strings"
+	"time"
+)
+
+// LoginGuard throttles password guessing. Every failed sign-in counts against
+// both the account and the client IP; each further failure doubles the time
+// the next attempt has to wait, and reaching a threshold locks the key out.
+type LoginGuard struct {
+	store    repository.LoginAttemptRepository
+	events   repository.SecurityEventRepository
+	userRepo repository.UserRepository
+	mailer   mailer.Mailer
+	cfg      config.LoginGuardConfig
+}
+
+// TooManyAttemptsError tells the caller how long to wait before trying again.
+type TooManyAttemptsError struct {
+	RetryAfter time.Duration
+}
+
+func (e *TooManyAttemptsError) Error() string {
+	return fmt.Sprintf("too many attempts, retry in %s", e.RetryAfter)
+}
+
+func (e *TooManyAttemptsError) Unwrap() error {
+	return utils.ErrTooManyAttempts
+}
+
+func NewLoginGuard(store repository.LoginAttemptRepository, events repository.SecurityEventRepository, userRepo repository.UserRepository, mail mailer.Mailer, cfg config.LoginGuardConfig) *LoginGuard {
+	return &LoginGuard{store: store, events: events, userRepo: userRepo, mailer: mail, cfg: cfg}
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func registrationKey(ip string) string {
+	return "register:" + ip
+}
+
+// CheckSignIn returns a *TooManyAttemptsError when the account or the IP is
+// locked or still inside its progressive delay.
+func (g *LoginGuard) CheckSignIn(ctx context.Context, email, ip string) error {
+	now := time.Now()
+	account, err := g.store.GetAttempts(ctx, accountKey(email))
+	if err != nil {
+		return err
+	}
+	if !account.LockedUntil.IsZero() && !now.Before(account.LockedUntil) {
+		g.unlockAccount(ctx, email, ip)
+		account = &models.LoginAttempts{}
+	}
+	if wait := g.wait(account, now); wait > 0 {
+		return &TooManyAttemptsError{RetryAfter: wait}
+	}
+
+	client, err := g.store.GetAttempts(ctx, ipKey(ip))
+	if err != nil {
+		return err
+	}
+	if !client.LockedUntil.IsZero() && !now.Before(client.LockedUntil) {
+		if err := g.store.Reset(ctx, ipKey(ip)); err != nil {
+			log.Printf("Failed to clear expired lock: %v", err)
+		}
+		client = &models.LoginAttempts{}
+	}
+	if wait := g.wait(client, now); wait > 0 {
+		return &TooManyAttemptsError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// SignInFailed counts a failed attempt and locks the account or IP once its
+// threshold is reached. Unknown emails are counted the same way, so the
+// responses do not reveal which addresses are registered.
+func (g *LoginGuard) SignInFailed(ctx context.Context, email, ip string) {
+	now := time.Now()
+	account, err := g.store.RecordFailure(ctx, accountKey(email), now, g.cfg.Window)
+	if err != nil {
+		log.Printf("Failed to record sign-in failure: %v", err)
+	} else if account.Failures >= g.cfg.AccountThreshold && !now.Before(account.LockedUntil) {
+		g.lockAccount(ctx, email, ip, now.Add(g.cfg.LockoutDuration))
+	}
+
+	client, err := g.store.RecordFailure(ctx, ipKey(ip), now, g.cfg.Window)
+	if err != nil {
+		log.Printf("Failed to record sign-in failure: %v", err)
+	} else if client.Failures >= g.cfg.IPThreshold && !now.Before(client.LockedUntil) {
+		log.Printf("Locking sign-in from %s after %d failed attempts", ip, client.Failures)
+		if err := g.store.Lock(ctx, ipKey(ip), now.Add(g.cfg.LockoutDuration), g.cfg.Window); err != nil {
+			log.Printf("Failed to lock %s: %v", ip, err)
+		}
+	}
+}
+
+// SignInSucceeded clears the account counter. The IP counter is kept, as one
+// valid account must not reset the budget for guessing others.
+func (g *LoginGuard) SignInSucceeded(ctx context.Context, email string) {
+	if err := g.store.Reset(ctx, accountKey(email)); err != nil {
+		log.Printf("Failed to reset sign-in failures: %v", err)
+	}
+}
+
+// CheckRegistration limits how many accounts one IP can create per window.
+func (g *LoginGuard) CheckRegistration(ctx context.Context, ip string) error {
+	attempts, err := g.store.GetAttempts(ctx, registrationKey(ip))
+	if err != nil {
+		return err
+	}
+	if attempts.Failures >= g.cfg.AccountThreshold {
+		return &TooManyAttemptsError{RetryAfter: time.Until(attempts.LastFailure.Add(g.cfg.Window))}
+	}
+	return nil
+}
+
+func (g *LoginGuard) RegistrationAttempted(ctx context.Context, ip string) {
+	if _, err := g.store.RecordFailure(ctx, registrationKey(ip), time.Now(), g.cfg.Window); err != nil {
+		log.Printf("Failed to record registration from %s: %v", ip, err)
+	}
+}
+
+// wait returns how long a key has to wait before its next attempt.
+func (g *LoginGuard) wait(attempts *models.LoginAttempts, now time.Time) time.Duration {
+	if now.Before(attempts.LockedUntil) {
+		return attempts.LockedUntil.Sub(now)
+	}
+	if attempts.Failures == 0 {
+		return 0
+	}
+	delay := g.cfg.BaseDelay
+	for i := 1; i < attempts.Failures && delay < g.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > g.cfg.MaxDelay {
+		delay = g.cfg.MaxDelay
+	}
+	return attempts.LastFailure.Add(delay).Sub(now)
+}
+
+func (g *LoginGuard) lockAccount(ctx context.Context, email, ip string, until time.Time) {
+	if err := g.store.Lock(ctx, accountKey(email), until, g.cfg.Window); err != nil {
+		log.Printf("Failed to lock account: %v", err)
+		return
+	}
+	user, err := g.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if !errors.Is(err, utils.ErrNotFound) {
+			log.Printf("Failed to load locked account: %v", err)
+		}
+		return
+	}
+	g.recordEvent(ctx, user.UserID, models.SecurityEventAccountLocked, ip)
+
+	if err := g.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Sign-in to your account was paused",
+		Body: fmt.Sprintf("Hi %s,\n\nThere were several failed attempts to sign in to your account, so signing in is paused until %s. The last attempt came from %s.\n\nIf this was not you, consider resetting your password.\n",
+			user.FirstName, until.UTC().Format(time.RFC1123), ip),
+	}); err != nil {
+		log.Printf("Failed to notify user %s about the lockout: %v", user.UserID, err)
+	}
+}
+
+// unlockAccount clears an expired lock and records that it ended.
+func (g *LoginGuard) unlockAccount(ctx context.Context, email, ip string) {
+	if err := g.store.Reset(ctx, accountKey(email)); err != nil {
+		log.Printf("Failed to clear expired lock: %v", err)
+	}
+	user, err := g.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		return
+	}
+	g.recordEvent(ctx, user.UserID, models.SecurityEventAccountUnlocked, ip)
+}
+
+func (g *LoginGuard) recordEvent(ctx context.Context, userID gocql.UUID, eventType, ip string) {
+	event := models.SecurityEvent{
+		EventID:   gocql.TimeUUID(),
+		UserID:    userID,
+		Type:      eventType,
+		IP:        ip,
+		CreatedAt: time.Now(),
+	}
+	if err := g.events.RecordEvent(ctx, &event); err != nil {
+		log.Printf("Failed to record %s event for user %s: %v", eventType, userID, err)
+	}
+}
+
+func (g *LoginGuard) SecurityEvents(ctx context.Context, userID gocql.UUID, page models.Page) ([]models.SecurityEvent, []byte, error) {
+	return g.events.ListEvents(ctx, userID, page)
+}
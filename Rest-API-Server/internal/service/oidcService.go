@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/gocql/gocql"
+	"log"
+	"marketplace_project/config"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/oidc"
+	"marketplace_project/internal/repository"
+	"marketplace_project/internal/utils"
+	"strings"
+	"time"
+)
+
+// OIDCService signs users in with external OpenID Connect providers and maps
+// provider identities to local accounts.
+type OIDCService struct {
+	providers map[string]*oidc.Provider
+	repo      repository.OIDCRepository
+	userRepo  repository.UserRepository
+	stateTTL  time.Duration
+}
+
+func NewOIDCService(repo repository.OIDCRepository, userRepo repository.UserRepository, cfg config.OIDCConfig) *OIDCService {
+	providers := make(map[string]*oidc.Provider, len(cfg.Providers))
+	for name, providerCfg := range cfg.Providers {
+		providers[name] = oidc.NewProvider(name, providerCfg)
+	}
+	return &OIDCService{providers: providers, repo: repo, userRepo: userRepo, stateTTL: cfg.StateTTL}
+}
+
+// Providers returns the names of the configured providers.
+func (s *OIDCService) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	return names
+}
+
+// AuthorizationURL starts a sign-in and returns the provider page to send the
+// browser to. The state, nonce and PKCE verifier are kept server-side.
+func (s *OIDCService) AuthorizationURL(ctx context.Context, providerName string) (string, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", utils.ErrUnknownProvider
+	}
+
+	state, err := oidc.RandomToken()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := oidc.RandomToken()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := oidc.RandomToken()
+	if err != nil {
+		return "", err
+	}
+
+	loginState := models.OIDCLoginState{State: state, Provider: providerName, CodeVerifier: verifier, Nonce: nonce}
+	if err := s.repo.SaveLoginState(ctx, &loginState, s.stateTTL); err != nil {
+		return "", err
+	}
+	return provider.AuthCodeURL(ctx, state, nonce, oidc.CodeChallenge(verifier))
+}
+
+// Callback completes a sign-in with the code the provider returned and
+// resolves the local account: an already linked one, an existing account
+// with the same verified email, or a newly created one.
+func (s *OIDCService) Callback(ctx context.Context, providerName, state, code string) (*models.User, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, utils.ErrUnknownProvider
+	}
+	loginState, err := s.repo.ConsumeLoginState(ctx, state)
+	if err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			return nil, utils.ErrInvalidToken
+		}
+		return nil, err
+	}
+	if loginState.Provider != providerName {
+		return nil, utils.ErrInvalidToken
+	}
+
+	identity, err := provider.Exchange(ctx, code, loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	return s.resolveUser(ctx, providerName, identity)
+}
+
+func (s *OIDCService) resolveUser(ctx context.Context, providerName string, identity *oidc.Identity) (*models.User, error) {
+	userID, err := s.repo.GetIdentityUser(ctx, providerName, identity.Subject)
+	if err == nil {
+		user, err := s.userRepo.GetUserByID(ctx, userID)
+		if err == nil {
+			return user, nil
+		}
+		if !errors.Is(err, utils.ErrNotFound) {
+			return nil, err
+		}
+		// The account was deleted; drop the stale link and start over.
+		if err := s.repo.UnlinkIdentity(ctx, providerName, identity.Subject); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, utils.ErrNotFound) {
+		return nil, err
+	}
+
+	// Without a verified address there is no safe way to match or create an
+	// account, since the email is the only thing tying the two together.
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, utils.ErrEmailNotVerified
+	}
+
+	user, err := s.userRepo.GetUserByEmail(ctx, identity.Email)
+	switch {
+	case err == nil:
+		// Linking to an account whose owner never proved the address would
+		// let whoever registered it first take over the provider identity.
+		if !user.EmailVerified {
+			return nil, utils.ErrEmailNotVerified
+		}
+	case errors.Is(err, utils.ErrNotFound):
+		user, err = s.createUser(ctx, identity)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	linked, err := s.repo.LinkIdentity(ctx, providerName, identity.Subject, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !linked {
+		// A concurrent callback linked the identity first; use its account.
+		userID, err := s.repo.GetIdentityUser(ctx, providerName, identity.Subject)
+		if err != nil {
+			return nil, err
+		}
+		return s.userRepo.GetUserByID(ctx, userID)
+	}
+	return user, nil
+}
+
+// createUser registers an account for a first-time provider sign-in. It has
+// no password; the owner can set one through the password reset flow.
+func (s *OIDCService) createUser(ctx context.Context, identity *oidc.Identity) (*models.User, error) {
+	user := models.User{
+		UserID:        gocql.TimeUUID(),
+		FirstName:     identity.GivenName,
+		LastName:      identity.FamilyName,
+		Avatar:        identity.Picture,
+		Email:         strings.TrimSpace(identity.Email),
+		EmailVerified: true,
+		AccountType:   models.RoleUser,
+		CreatedAt:     time.Now(),
+	}
+	if user.Avatar == "" {
+		user.Avatar = models.DefaultAvatar
+	}
+	if err := s.userRepo.CreateUser(ctx, &user); err != nil {
+		if !errors.Is(err, utils.ErrEmailExists) {
+			return nil, err
+		}
+		// Registered concurrently by another request; link to that one
+		// under the same rule as any existing account.
+		existing, err := s.userRepo.GetUserByEmail(ctx, user.Email)
+		if err != nil {
+			return nil, err
+		}
+		if !existing.EmailVerified {
+			return nil, utils.ErrEmailNotVerified
+		}
+		return existing, nil
+	}
+	log.Printf("Created user %s from an external identity", user.UserID)
+	return &user, nil
+}
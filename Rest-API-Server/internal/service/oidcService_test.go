@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/gocql/gocql"
+	"marketplace_project/config"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/oidc/oidctest"
+	"marketplace_project/internal/utils"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryOIDCRepository struct {
+	mu         sync.Mutex
+	states     map[string]models.OIDCLoginState
+	identities map[string]gocql.UUID
+}
+
+func newMemoryOIDCRepository() *memoryOIDCRepository {
+	return &memoryOIDCRepository{states: make(map[string]models.OIDCLoginState), identities: make(map[string]gocql.UUID)}
+}
+
+func (r *memoryOIDCRepository) SaveLoginState(ctx context.Context, state *models.OIDCLoginState, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.states[state.State] = *state
+	return nil
+}
+
+func (r *memoryOIDCRepository) ConsumeLoginState(ctx context.Context, state string) (*models.OIDCLoginState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	loginState, ok := r.states[state]
+	if !ok {
+		return nil, utils.ErrNotFound
+	}
+	delete(r.states, state)
+	return &loginState, nil
+}
+
+func (r *memoryOIDCRepository) GetIdentityUser(ctx context.Context, provider, subject string) (gocql.UUID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	userID, ok := r.identities[provider+"/"+subject]
+	if !ok {
+		return gocql.UUID{}, utils.ErrNotFound
+	}
+	return userID, nil
+}
+
+func (r *memoryOIDCRepository) LinkIdentity(ctx context.Context, provider, subject string, userID gocql.UUID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.identities[provider+"/"+subject]; ok {
+		return false, nil
+	}
+	r.identities[provider+"/"+subject] = userID
+	return true, nil
+}
+
+func (r *memoryOIDCRepository) UnlinkIdentity(ctx context.Context, provider, subject string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.identities, provider+"/"+subject)
+	return nil
+}
+
+// memoryUserRepository keeps accounts in memory; only the methods the OIDC
+// service uses do anything.
+type memoryUserRepository struct {
+	mu    sync.Mutex
+	users map[gocql.UUID]models.User
+}
+
+func newMemoryUserRepository(users ...models.User) *memoryUserRepository {
+	r := &memoryUserRepository{users: make(map[gocql.UUID]models.User)}
+	for _, user := range users {
+		r.users[user.UserID] = user
+	}
+	return r
+}
+
+func (r *memoryUserRepository) CreateUser(ctx context.Context, user *models.User) error {
+	if _, err := r.GetUserByEmail(ctx, user.Email); err == nil {
+		return utils.ErrEmailExists
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[user.UserID] = *user
+	return nil
+}
+
+func (r *memoryUserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if strings.EqualFold(user.Email, strings.TrimSpace(email)) {
+			return &user, nil
+		}
+	}
+	return nil, utils.ErrNotFound
+}
+
+func (r *memoryUserRepository) GetUserByID(ctx context.Context, id gocql.UUID) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return nil, utils.ErrNotFound
+	}
+	return &user, nil
+}
+
+func (r *memoryUserRepository) GetUser(ctx context.Context, id gocql.UUID) (*models.UserWrapContent, error) {
+	return nil, utils.ErrNotFound
+}
+func (r *memoryUserRepository) SetEmailVerified(ctx context.Context, id gocql.UUID) error { return nil }
+func (r *memoryUserRepository) UpdatePassword(ctx context.Context, id gocql.UUID, passwordHash string) error {
+	return nil
+}
+func (r *memoryUserRepository) UpdateAccountType(ctx context.Context, id gocql.UUID, accountType string) error {
+	return nil
+}
+func (r *memoryUserRepository) UpdateProfile(ctx context.Context, user *models.User) error {
+	return nil
+}
+func (r *memoryUserRepository) UpdateEmail(ctx context.Context, id gocql.UUID, email string) error {
+	return nil
+}
+func (r *memoryUserRepository) DeleteUser(ctx context.Context, id gocql.UUID) error { return nil }
+
+func newTestOIDCService(issuer *oidctest.Issuer, users *memoryUserRepository) *OIDCService {
+	return NewOIDCService(newMemoryOIDCRepository(), users, config.OIDCConfig{
+		Providers: map[string]config.OIDCProviderConfig{
+			"mock": {
+				Issuer:      issuer.URL,
+				ClientID:    "marketplace",
+				RedirectURL: "http://localhost:5173/oauth/callback",
+				Scopes:      []string{"openid", "email", "profile"},
+			},
+		},
+		StateTTL: time.Minute,
+	})
+}
+
+// signInWith runs a whole sign-in: the service builds the authorization URL,
+// the issuer signs the user in with claims and the callback redeems the code.
+func signInWith(t *testing.T, service *OIDCService, issuer *oidctest.Issuer, claims map[string]interface{}) (*models.User, error) {
+	t.Helper()
+	ctx := context.Background()
+	authURL, err := service.AuthorizationURL(ctx, "mock")
+	if err != nil {
+		t.Fatalf("AuthorizationURL: %v", err)
+	}
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := issuer.Authorize(t, authURL, claims)
+	return service.Callback(ctx, "mock", parsed.Query().Get("state"), code)
+}
+
+func TestOIDCCallbackCreatesAccountOnFirstSignIn(t *testing.T) {
+	issuer := oidctest.NewIssuer(t)
+	users := newMemoryUserRepository()
+	service := newTestOIDCService(issuer, users)
+	claims := map[string]interface{}{
+		"sub":            "subject-1",
+		"email":          "ann@example.com",
+		"email_verified": true,
+		"given_name":     "Ann",
+		"family_name":    "Lee",
+	}
+
+	user, err := signInWith(t, service, issuer, claims)
+	if err != nil {
+		t.Fatalf("first sign-in: %v", err)
+	}
+	if user.Email != "ann@example.com" || !user.EmailVerified || user.FirstName != "Ann" || user.Password != "" {
+		t.Errorf("unexpected account %+v", user)
+	}
+
+	again, err := signInWith(t, service, issuer, claims)
+	if err != nil {
+		t.Fatalf("second sign-in: %v", err)
+	}
+	if again.UserID != user.UserID {
+		t.Errorf("second sign-in resolved to %s, want the linked account %s", again.UserID, user.UserID)
+	}
+}
+
+func TestOIDCCallbackLinksAccountWithVerifiedEmail(t *testing.T) {
+	issuer := oidctest.NewIssuer(t)
+	existing := models.User{UserID: gocql.TimeUUID(), Email: "Ann@Example.com", EmailVerified: true}
+	service := newTestOIDCService(issuer, newMemoryUserRepository(existing))
+
+	user, err := signInWith(t, service, issuer, map[string]interface{}{
+		"sub":            "subject-1",
+		"email":          "ann@example.com",
+		"email_verified": true,
+	})
+	if err != nil {
+		t.Fatalf("Callback: %v", err)
+	}
+	if user.UserID != existing.UserID {
+		t.Errorf("signed in as %s, want existing account %s", user.UserID, existing.UserID)
+	}
+}
+
+func TestOIDCCallbackRefusesUnverifiedEmail(t *testing.T) {
+	tests := []struct {
+		name          string
+		localVerified bool
+		claimVerified bool
+	}{
+		{name: "provider did not verify the address", localVerified: true, claimVerified: false},
+		{name: "local account did not verify the address", localVerified: false, claimVerified: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			issuer := oidctest.NewIssuer(t)
+			existing := models.User{UserID: gocql.TimeUUID(), Email: "ann@example.com", EmailVerified: test.localVerified}
+			users := newMemoryUserRepository(existing)
+			service := newTestOIDCService(issuer, users)
+
+			_, err := signInWith(t, service, issuer, map[string]interface{}{
+				"sub":            "subject-1",
+				"email":          "ann@example.com",
+				"email_verified": test.claimVerified,
+			})
+			if !errors.Is(err, utils.ErrEmailNotVerified) {
+				t.Fatalf("got %v, want ErrEmailNotVerified", err)
+			}
+			if len(users.users) != 1 {
+				t.Errorf("an account was created: %d accounts", len(users.users))
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackIsSingleUse(t *testing.T) {
+	issuer := oidctest.NewIssuer(t)
+	service := newTestOIDCService(issuer, newMemoryUserRepository())
+	ctx := context.Background()
+
+	authURL, err := service.AuthorizationURL(ctx, "mock")
+	if err != nil {
+		t.Fatalf("AuthorizationURL: %v", err)
+	}
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := parsed.Query().Get("state")
+	claims := map[string]interface{}{"sub": "subject-1", "email": "ann@example.com", "email_verified": true}
+	if _, err := service.Callback(ctx, "mock", state, issuer.Authorize(t, authURL, claims)); err != nil {
+		t.Fatalf("Callback: %v", err)
+	}
+	if _, err := service.Callback(ctx, "mock", state, issuer.Authorize(t, authURL, claims)); !errors.Is(err, utils.ErrInvalidToken) {
+		t.Fatalf("replayed state: got %v, want ErrInvalidToken", err)
+	}
+}
@@ -2,55 +2,391 @@ package service
 
 import (
 	"context"
+	"errors"
 	"github.com/gocql/gocql"
+	"log"
+	"marketplace_project/internal/imaging"
 	"marketplace_project/internal/models"
 	"marketplace_project/internal/repository"
+	"marketplace_project/internal/utils"
+	"time"
 )
 
 type ProductService struct {
-	repo repository.ProductRepository
+	repo          repository.ProductRepository
+	auditRepo     repository.AuditRepository
+	subscriptions *SubscriptionService
+	feed          *FeedService
+	blocks        *BlockService
+	stats         *StatsService
+	images        *ImageService
+	expiry        *ListingExpiry
 }
 
-func NewProductService(repo repository.ProductRepository) *ProductService {
-	return &ProductService{repo: repo}
+func NewProductService(repo repository.ProductRepository, auditRepo repository.AuditRepository, subscriptions *SubscriptionService, feed *FeedService, blocks *BlockService, stats *StatsService, images *ImageService, expiry *ListingExpiry) *ProductService {
+	return &ProductService{repo: repo, auditRepo: auditRepo, subscriptions: subscriptions, feed: feed, blocks: blocks, stats: stats, images: images, expiry: expiry}
 }
 
-func (s *ProductService) AddProduct(product *models.Product, filters *[]map[string]string) error {
-	return s.repo.AddProduct(context.Background(), product, filters)
+// AddProduct saves a listing as a draft or publishes it, unless the owner
+// already has as many active listings as their plan allows.
+// product.Images holds the IDs of images the owner uploaded and is replaced
+// by their URLs.
+func (s *ProductService) AddProduct(ctx context.Context, product *models.Product, filters *[]map[string]string) error {
+	if product.Status != models.StatusDraft && product.Status != models.StatusActive {
+		return utils.ErrInvalidTransition
+	}
+	// Only publishing starts the listing period; drafts never expire.
+	product.ExpiresAt = nil
+	images, err := s.images.VariantURLs(ctx, product.OwnerID, product.Images, imaging.VariantOriginal)
+	if err != nil {
+		return err
+	}
+	product.Images = images
+
+	if product.Status.Counted() {
+		if err := s.checkListingLimit(ctx, product.OwnerID); err != nil {
+			return err
+		}
+	}
+	if product.Status.Listed() {
+		if err := s.expiry.Start(ctx, product); err != nil {
+			return err
+		}
+	}
+	if err := s.repo.AddProduct(ctx, product, filters); err != nil {
+		return err
+	}
+	if product.Status.Listed() {
+		s.feed.PublishProduct(product)
+	}
+	return nil
+}
+
+// checkListingLimit fails with ErrLimitReached when the owner has no room
+// for another active listing in their plan.
+func (s *ProductService) checkListingLimit(ctx context.Context, ownerID gocql.UUID) error {
+	plan, err := s.subscriptions.CurrentPlan(ctx, ownerID)
+	if err != nil {
+		return err
+	}
+	count, err := s.repo.CountActiveProductsByOwner(ctx, ownerID)
+	if err != nil {
+		return err
+	}
+	if count >= plan.MaxActiveListings {
+		return utils.ErrLimitReached
+	}
+	return nil
+}
+
+func (s *ProductService) DeleteProduct(ctx context.Context, actor models.Actor, productID gocql.UUID) error {
+	ownerID, err := s.authorizeProductChange(ctx, actor, productID)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeleteProduct(ctx, productID); err != nil {
+		return err
+	}
+	s.feed.RemoveProduct(ownerID, productID)
+	s.stats.RemoveProduct(ctx, productID)
+	s.recordStaffAction(ctx, actor, "delete", productID, ownerID)
+	return nil
+}
+
+// UpdateProduct applies a partial update to a listing. expectedVersion is the
+// version the client last read; the update fails with ErrVersionConflict when
+// somebody else changed the listing in the meantime.
+func (s *ProductService) UpdateProduct(ctx context.Context, actor models.Actor, productID gocql.UUID, update models.ProductUpdate, expectedVersion int) (*models.Product, *[]models.Filter, error) {
+	ownerID, err := s.authorizeProductChange(ctx, actor, productID)
+	if err != nil {
+		return nil, nil, err
+	}
+	product, filters, err := s.repo.ProductInfoByID(ctx, productID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if product.Version != expectedVersion {
+		return nil, nil, utils.ErrVersionConflict
+	}
+
+	if update.Title != nil {
+		product.Title = *update.Title
+		product.Keywords = update.Keywords
+	}
+	if update.Description != nil {
+		product.Description = *update.Description
+	}
+	if update.Price != nil {
+		product.Price = *update.Price
+	}
+	if update.Images != nil {
+		images, err := s.updatedImages(ctx, ownerID, product.Images, *update.Images)
+		if err != nil {
+			return nil, nil, err
+		}
+		product.Images = images
+	}
+
+	if err := s.repo.UpdateProduct(ctx, product, expectedVersion, update.Filters); err != nil {
+		return nil, nil, err
+	}
+	if update.Filters != nil {
+		updated := make([]models.Filter, 0, len(*update.Filters))
+		for _, filterMap := range *update.Filters {
+			for name, value := range filterMap {
+				updated = append(updated, models.Filter{ID: gocql.TimeUUID(), Name: name, Value: value})
+			}
+		}
+		filters = &updated
+	}
+	if product.Status.Listed() && (update.Title != nil || update.Price != nil || update.Images != nil) {
+		s.feed.PublishProduct(product)
+	}
+
+	s.recordStaffAction(ctx, actor, "update", productID, ownerID)
+	return product, filters, nil
+}
+
+// ChangeStatus moves a listing to another stage of its life, such as
+// reserved or sold. It fails with ErrInvalidTransition for a change the
+// listing's current status does not allow, and with ErrLimitReached when
+// putting it up again would exceed the owner's plan.
+func (s *ProductService) ChangeStatus(ctx context.Context, actor models.Actor, productID gocql.UUID, status models.ListingStatus) (*models.Product, error) {
+	ownerID, err := s.authorizeProductChange(ctx, actor, productID)
+	if err != nil {
+		return nil, err
+	}
+	product, _, err := s.repo.ProductInfoByID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if !product.Status.CanBecome(status) {
+		return nil, utils.ErrInvalidTransition
+	}
+	if !product.Status.Counted() && status.Counted() {
+		if err := s.checkListingLimit(ctx, ownerID); err != nil {
+			return nil, err
+		}
+	}
+
+	wasListed := product.Status.Listed()
+	if !wasListed && status.Listed() {
+		if err := s.expiry.Start(ctx, product); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.repo.SetStatus(ctx, product, status); err != nil {
+		return nil, err
+	}
+	switch {
+	case !wasListed && status.Listed():
+		s.feed.PublishProduct(product)
+	case wasListed && !status.Listed():
+		s.feed.RemoveProduct(ownerID, productID)
+	}
+
+	s.recordStaffAction(ctx, actor, "status:"+string(status), productID, ownerID)
+	return product, nil
+}
+
+// Renew starts a new period for an active listing, or puts an expired one up
+// again, under the owner's current plan. It fails with ErrInvalidTransition
+// for listings in other statuses, and with ErrLimitReached when an expired
+// listing no longer fits in the owner's plan.
+func (s *ProductService) Renew(ctx context.Context, actor models.Actor, productID gocql.UUID) (*models.Product, error) {
+	ownerID, err := s.authorizeProductChange(ctx, actor, productID)
+	if err != nil {
+		return nil, err
+	}
+	product, _, err := s.repo.ProductInfoByID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if product.Status != models.StatusActive && product.Status != models.StatusExpired {
+		return nil, utils.ErrInvalidTransition
+	}
+	if !product.Status.Counted() {
+		if err := s.checkListingLimit(ctx, ownerID); err != nil {
+			return nil, err
+		}
+	}
+
+	wasListed := product.Status.Listed()
+	if err := s.expiry.Start(ctx, product); err != nil {
+		return nil, err
+	}
+	if err := s.repo.SetStatus(ctx, product, models.StatusActive); err != nil {
+		return nil, err
+	}
+	if !wasListed {
+		s.feed.PublishProduct(product)
+	}
+
+	s.recordStaffAction(ctx, actor, "renew", productID, ownerID)
+	return product, nil
+}
+
+// DeadlineListing returns the listing a deadline was scheduled for, or nil
+// when the deadline no longer applies because the listing has since been
+// renewed, taken down or deleted.
+func (s *ProductService) DeadlineListing(ctx context.Context, deadline models.ListingDeadline) (*models.Product, error) {
+	product, _, err := s.repo.ProductInfoByID(ctx, deadline.ProductID)
+	if err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if product.Status != models.StatusActive || product.ExpiresAt == nil || !product.ExpiresAt.Equal(deadline.ExpiresAt) {
+		return nil, nil
+	}
+	return product, nil
+}
+
+// Expire takes down an active listing whose period has run out. It fails
+// with ErrVersionConflict when the listing changed since it was read.
+func (s *ProductService) Expire(ctx context.Context, product *models.Product) error {
+	if err := s.repo.SetStatus(ctx, product, models.StatusExpired); err != nil {
+		return err
+	}
+	s.feed.RemoveProduct(product.OwnerID, product.ProductID)
+	return nil
+}
+
+// updatedImages resolves the images of an update. Each entry is either the
+// ID of an image the owner uploaded or a URL the listing already shows, so
+// existing pictures, including ones saved before uploads existed, can be kept
+// and reordered.
+func (s *ProductService) updatedImages(ctx context.Context, ownerID gocql.UUID, current, requested []string) ([]string, error) {
+	kept := make(map[string]bool, len(current))
+	for _, image := range current {
+		kept[image] = true
+	}
+	images := make([]string, 0, len(requested))
+	for _, image := range requested {
+		if kept[image] {
+			images = append(images, image)
+			continue
+		}
+		urls, err := s.images.VariantURLs(ctx, ownerID, []string{image}, imaging.VariantOriginal)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, urls...)
+	}
+	return images, nil
+}
+
+// ProductOwner returns the account that published the listing.
+func (s *ProductService) ProductOwner(ctx context.Context, productID gocql.UUID) (gocql.UUID, error) {
+	return s.repo.GetProductOwner(ctx, productID)
 }
 
-func (s *ProductService) DeleteProduct(productID gocql.UUID) error {
-	return s.repo.DeleteProduct(context.Background(), productID)
+// authorizeProductChange returns the owner of the product when actor may
+// modify it: either the actor owns it or is staff.
+func (s *ProductService) authorizeProductChange(ctx context.Context, actor models.Actor, productID gocql.UUID) (gocql.UUID, error) {
+	ownerID, err := s.repo.GetProductOwner(ctx, productID)
+	if err != nil {
+		return gocql.UUID{}, err
+	}
+	if ownerID != actor.UserID && !actor.IsStaff() {
+		return gocql.UUID{}, utils.ErrForbidden
+	}
+	return ownerID, nil
 }
 
-func (s *ProductService) ProductsWrapsByCategory(categoryID gocql.UUID, lastProductID gocql.UUID) ([]models.ProductWrapContent, gocql.UUID, error) {
-	return s.repo.ProductsWrapsByCategory(context.Background(), categoryID, lastProductID)
+// recordStaffAction writes an audit entry when staff changed a listing that
+// belongs to someone else. Failures are logged rather than returned because
+// the change itself has already been applied.
+func (s *ProductService) recordStaffAction(ctx context.Context, actor models.Actor, action string, productID, ownerID gocql.UUID) {
+	if ownerID == actor.UserID {
+		return
+	}
+	entry := models.AdminAction{
+		ActionID:   gocql.TimeUUID(),
+		ActorID:    actor.UserID,
+		ActorRole:  actor.Role,
+		Action:     action,
+		TargetType: "product",
+		TargetID:   productID,
+		OwnerID:    ownerID,
+		CreatedAt:  time.Now(),
+	}
+	if err := s.auditRepo.RecordAdminAction(ctx, &entry); err != nil {
+		log.Printf("Failed to record %s of product %s by %s: %v", action, productID, actor.UserID, err)
+	}
+}
+
+func (s *ProductService) ProductsWrapsByCategory(ctx context.Context, categoryID gocql.UUID, sort models.ListingSort, page models.Page) ([]models.ProductWrapContent, []byte, error) {
+	return s.repo.ProductsWrapsByCategory(ctx, categoryID, sort, page)
 }
 
 func (s *ProductService) ProductInfoByID(productID gocql.UUID) (*models.Product, *[]models.Filter, error) {
 	return s.repo.ProductInfoByID(context.Background(), productID)
 }
 
-func (s *ProductService) GetProductsByOwnerID(userID gocql.UUID) ([]models.ProductWrapContent, error) {
-	return s.repo.GetProductByOwnerID(context.Background(), userID)
+// GetProductsByOwnerID returns a page of the user's listings: all of them
+// when allStatuses is set, as for the owner, and the active ones otherwise.
+func (s *ProductService) GetProductsByOwnerID(ctx context.Context, userID gocql.UUID, allStatuses bool, page models.Page) ([]models.ProductWrapContent, []byte, error) {
+	return s.repo.ProductsByOwner(ctx, userID, !allStatuses, page)
+}
+
+// CanView reports whether viewer, nil for anonymous visitors, may open the
+// listing. Drafts are only visible to their owner and staff.
+func (s *ProductService) CanView(product *models.Product, viewer *models.Actor) bool {
+	if product.Status != models.StatusDraft {
+		return true
+	}
+	return viewer != nil && (viewer.UserID == product.OwnerID || viewer.IsStaff())
 }
 
 func (s *ProductService) GetProductsByCategory(categoryID gocql.UUID) ([]models.ProductWrapContent, error) {
 	return s.repo.ProductWrapByCategory(context.Background(), categoryID)
 }
 
-func (s *ProductService) GetProducts() ([]models.ProductWrapContent, error) {
-	return s.repo.Products(context.Background())
+func (s *ProductService) GetProducts(ctx context.Context, page models.Page) ([]models.ProductWrapContent, []byte, error) {
+	return s.repo.Products(ctx, page)
+}
+
+// SearchProducts finds listings by keyword. When viewerID is set, listings of
+// the sellers the viewer has blocked are left out.
+func (s *ProductService) SearchProducts(ctx context.Context, searchQuery string, viewerID *gocql.UUID, sort models.ListingSort, page models.Page) ([]models.ProductWrapContent, []byte, error) {
+	var excludeOwners map[gocql.UUID]bool
+	if viewerID != nil {
+		var err error
+		excludeOwners, err = s.blocks.BlockedOwners(ctx, *viewerID)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	products, next, err := s.repo.SearchByKeywords(ctx, searchQuery, excludeOwners, sort, page)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.stats.RecordImpressions(products)
+	return products, next, nil
+}
+
+// RecordView counts a view of the listing by viewer, see
+// StatsService.RecordView.
+func (s *ProductService) RecordView(ctx context.Context, productID gocql.UUID, viewer string) {
+	s.stats.RecordView(ctx, productID, viewer)
 }
 
-func (s *ProductService) SearchProducts(searchQuery string) ([]models.ProductWrapContent, error) {
-	return s.repo.SearchByKeywords(context.Background(), searchQuery)
+// ListingStats returns the daily statistics of a listing to its owner or to
+// staff.
+func (s *ProductService) ListingStats(ctx context.Context, actor models.Actor, productID gocql.UUID, days int) (*models.ListingStats, error) {
+	if _, err := s.authorizeProductChange(ctx, actor, productID); err != nil {
+		return nil, err
+	}
+	return s.stats.ListingStats(ctx, productID, days)
 }
 
-func (s *ProductService) FindProductsByFilters(categoryID gocql.UUID, subcategoryID gocql.UUID, filter models.Filter, limit int) ([]gocql.UUID, error) {
-	return s.repo.FindProductsByFilters(context.Background(), categoryID, subcategoryID, filter, limit)
+// FindProductsByFilters returns a page of the listings matching query.
+func (s *ProductService) FindProductsByFilters(ctx context.Context, query models.FilterQuery, sort models.ListingSort, page models.Page) ([]models.ProductWrapContent, []byte, error) {
+	return s.repo.FindProductsByFilters(ctx, query, sort, page)
 }
 
-func (s *ProductService) FindProductsByIDs(ctx context.Context, productID gocql.UUID) (*models.ProductWrapContent, error) {
-	return s.repo.FindProductsByID(ctx, productID)
+func (s *ProductService) AdminActions(ctx context.Context, day time.Time, page models.Page) ([]models.AdminAction, []byte, error) {
+	return s.auditRepo.AdminActionsByDay(ctx, day, page)
 }
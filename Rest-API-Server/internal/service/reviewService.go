@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"github.com/gocql/gocql"
+	"marketplace_project/internal/chat"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/repository"
+	"marketplace_project/internal/utils"
+	"time"
+)
+
+type ReviewService struct {
+	repo        repository.ReviewRepository
+	productRepo repository.ProductRepository
+	chat        chat.Client
+}
+
+func NewReviewService(repo repository.ReviewRepository, productRepo repository.ProductRepository, chatClient chat.Client) *ReviewService {
+	return &ReviewService{repo: repo, productRepo: productRepo, chat: chatClient}
+}
+
+// Create reviews the seller of productID. Only a buyer who has written to the
+// seller about that listing may do so, and only once per listing.
+func (s *ReviewService) Create(ctx context.Context, buyerID, productID gocql.UUID, rating int, text string) (*models.Review, error) {
+	sellerID, err := s.productRepo.GetProductOwner(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if sellerID == buyerID {
+		return nil, utils.ErrForbidden
+	}
+	chatted, err := s.chat.HasListingChat(ctx, productID, buyerID, sellerID)
+	if err != nil {
+		return nil, err
+	}
+	if !chatted {
+		return nil, utils.ErrForbidden
+	}
+
+	review := models.Review{
+		ReviewID:  gocql.TimeUUID(),
+		SellerID:  sellerID,
+		ProductID: productID,
+		BuyerID:   buyerID,
+		Rating:    rating,
+		Text:      text,
+		CreatedAt: time.Now(),
+	}
+	if err := s.repo.CreateReview(ctx, &review); err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// Reply publishes the seller's answer to one of their reviews. A review can
+// be answered once.
+func (s *ReviewService) Reply(ctx context.Context, sellerID, reviewID gocql.UUID, text string) (*models.Review, error) {
+	review, err := s.repo.GetReview(ctx, sellerID, reviewID)
+	if err != nil {
+		return nil, err
+	}
+	if review.Reply != nil {
+		return nil, utils.ErrAlreadyReplied
+	}
+	reply := models.ReviewReply{Text: text, CreatedAt: time.Now()}
+	if err := s.repo.SetReply(ctx, sellerID, reviewID, reply); err != nil {
+		return nil, err
+	}
+	review.Reply = &reply
+	return review, nil
+}
+
+func (s *ReviewService) List(ctx context.Context, sellerID gocql.UUID, page models.Page) ([]models.Review, []byte, error) {
+	return s.repo.ListReviews(ctx, sellerID, page)
+}
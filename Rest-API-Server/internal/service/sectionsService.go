@@ -11,10 +11,11 @@ type SectionsService struct {
 	userRepo     repository.UserRepository
 	productRepo  repository.ProductRepository
 	categoryRepo repository.CategoryRepository
+	feedRepo     repository.FeedRepository
 }
 
-func NewSectionsService(productRepo repository.ProductRepository, categoryRepo repository.CategoryRepository, userRepo repository.UserRepository) *SectionsService {
-	return &SectionsService{userRepo: userRepo, productRepo: productRepo, categoryRepo: categoryRepo}
+func NewSectionsService(productRepo repository.ProductRepository, categoryRepo repository.CategoryRepository, userRepo repository.UserRepository, feedRepo repository.FeedRepository) *SectionsService {
+	return &SectionsService{userRepo: userRepo, productRepo: productRepo, categoryRepo: categoryRepo, feedRepo: feedRepo}
 }
 
 func (s *SectionsService) MainCategoriesSection(ctx context.Context) (*models.Section, error) {
@@ -67,15 +68,42 @@ func (s *SectionsService) MainProductsSections(ctx context.Context) ([]models.Se
 	return sections, nil
 }
 
+// FeedSection shows the newest listings of the sellers userID follows. It is
+// nil when there is nothing to show.
+func (s *SectionsService) FeedSection(ctx context.Context, userID gocql.UUID) (*models.Section, error) {
+	items, _, err := s.feedRepo.Timeline(ctx, userID, models.Page{Limit: 8})
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	itemsInterface := make([]interface{}, len(items))
+	for i, item := range items {
+		itemsInterface[i] = item
+	}
+
+	section := models.Section{
+		SectionID:      gocql.TimeUUID(),
+		SectionType:    "feed",
+		SectionHeading: "From sellers you follow",
+		Content:        itemsInterface,
+	}
+	return &section, nil
+}
+
 func (s *SectionsService) GetUserProducts(ctx context.Context, ownerID gocql.UUID) (*models.Section, error) {
 	products, err := s.productRepo.GetProductByOwnerID(ctx, ownerID)
 	if err != nil {
 		return nil, err
 	}
 
-	productsInterface := make([]interface{}, len(products))
-	for i, product := range products {
-		productsInterface[i] = product
+	productsInterface := make([]interface{}, 0, len(products))
+	for _, product := range products {
+		if product.Status.Listed() {
+			productsInterface = append(productsInterface, product)
+		}
 	}
 
 	section := models.Section{
@@ -87,14 +115,16 @@ func (s *SectionsService) GetUserProducts(ctx context.Context, ownerID gocql.UUI
 	return &section, nil
 }
 
-func (s *SectionsService) GetProfileInfo(ctx context.Context, userID gocql.UUID) (*models.UserWrapContent, []models.ProductWrapContent, error) {
+// GetProfileInfo returns the public profile of userID with a page of their
+// listings. Visitors see the active listings, the owner all of them.
+func (s *SectionsService) GetProfileInfo(ctx context.Context, userID gocql.UUID, ownProfile bool, page models.Page) (*models.UserWrapContent, []models.ProductWrapContent, []byte, error) {
 	user, err := s.userRepo.GetUser(ctx, userID)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
-	products, err := s.productRepo.GetProductByOwnerID(ctx, userID)
+	products, next, err := s.productRepo.ProductsByOwner(ctx, userID, !ownProfile, page)
 	if err != nil {
-		return user, nil, err
+		return user, nil, nil, err
 	}
-	return user, products, nil
+	return user, products, next, nil
 }
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/gocql/gocql"
+	"log"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/repository"
+	"marketplace_project/internal/utils"
+	"time"
+)
+
+type SessionService struct {
+	repo     repository.SessionRepository
+	userRepo repository.UserRepository
+}
+
+func NewSessionService(repo repository.SessionRepository, userRepo repository.UserRepository) *SessionService {
+	return &SessionService{repo: repo, userRepo: userRepo}
+}
+
+// StartSession opens a new session for the device and returns its first
+// access and refresh token pair.
+func (s *SessionService) StartSession(ctx context.Context, user *models.User, device, ip string) (string, string, error) {
+	now := time.Now()
+	session := models.Session{
+		UserID:     user.UserID,
+		SessionID:  gocql.TimeUUID(),
+		Device:     device,
+		IP:         ip,
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}
+	if err := s.repo.SaveSession(ctx, &session, utils.RefreshTokenTTL()); err != nil {
+		return "", "", err
+	}
+	return s.issueTokens(ctx, user, session.SessionID)
+}
+
+// Refresh exchanges a refresh token for a new pair. Every refresh token can be
+// used once; presenting one that was already exchanged means it leaked, so
+// the whole session is revoked.
+func (s *SessionService) Refresh(ctx context.Context, refreshToken, device, ip string) (string, string, error) {
+	claims, err := utils.ParseRefreshToken(refreshToken)
+	if err != nil {
+		return "", "", utils.ErrInvalidToken
+	}
+	tokenID, err := gocql.ParseUUID(claims.Id)
+	if err != nil {
+		return "", "", utils.ErrInvalidToken
+	}
+
+	stored, err := s.repo.GetRefreshToken(ctx, tokenID)
+	if err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			return "", "", utils.ErrInvalidToken
+		}
+		return "", "", err
+	}
+	if stored.UserID != claims.UserID || stored.SessionID != claims.SessionID {
+		return "", "", utils.ErrInvalidToken
+	}
+	if stored.Used {
+		s.revokeReusedSession(ctx, stored)
+		return "", "", utils.ErrTokenReused
+	}
+
+	session, err := s.repo.GetSession(ctx, stored.UserID, stored.SessionID)
+	if err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			return "", "", utils.ErrInvalidToken
+		}
+		return "", "", err
+	}
+
+	applied, err := s.repo.MarkRefreshTokenUsed(ctx, stored)
+	if err != nil {
+		return "", "", err
+	}
+	if !applied {
+		s.revokeReusedSession(ctx, stored)
+		return "", "", utils.ErrTokenReused
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			return "", "", utils.ErrInvalidToken
+		}
+		return "", "", err
+	}
+
+	session.Device = device
+	session.IP = ip
+	session.LastUsedAt = time.Now()
+	updated, err := s.repo.UpdateSession(ctx, session, utils.RefreshTokenTTL())
+	if err != nil {
+		return "", "", err
+	}
+	if !updated {
+		// The session was signed out while the token was being refreshed.
+		return "", "", utils.ErrInvalidToken
+	}
+	return s.issueTokens(ctx, user, session.SessionID)
+}
+
+func (s *SessionService) revokeReusedSession(ctx context.Context, token *models.RefreshToken) {
+	log.Printf("Refresh token %s of session %s was reused, revoking the session", token.TokenID, token.SessionID)
+	if err := s.repo.DeleteSession(ctx, token.UserID, token.SessionID); err != nil {
+		log.Printf("Failed to revoke session %s: %v", token.SessionID, err)
+	}
+}
+
+func (s *SessionService) issueTokens(ctx context.Context, user *models.User, sessionID gocql.UUID) (string, string, error) {
+	refreshToken := models.RefreshToken{
+		TokenID:   gocql.TimeUUID(),
+		SessionID: sessionID,
+		UserID:    user.UserID,
+		ExpiresAt: time.Now().Add(utils.RefreshTokenTTL()),
+	}
+	if err := s.repo.SaveRefreshToken(ctx, &refreshToken, utils.RefreshTokenTTL()); err != nil {
+		return "", "", err
+	}
+	subject := utils.TokenSubject{
+		UserID:        user.UserID,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Role:          models.RoleFromAccountType(user.AccountType),
+	}
+	return utils.GenerateToken(subject, sessionID, refreshToken.TokenID)
+}
+
+func (s *SessionService) ListSessions(ctx context.Context, userID gocql.UUID, currentSessionID gocql.UUID, page models.Page) ([]models.Session, []byte, error) {
+	sessions, next, err := s.repo.SessionsPage(ctx, userID, page)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].SessionID == currentSessionID
+	}
+	return sessions, next, nil
+}
+
+func (s *SessionService) Logout(ctx context.Context, userID gocql.UUID, sessionID gocql.UUID) error {
+	return s.repo.DeleteSession(ctx, userID, sessionID)
+}
+
+func (s *SessionService) LogoutAll(ctx context.Context, userID gocql.UUID) error {
+	return s.repo.DeleteAllSessions(ctx, userID)
+}
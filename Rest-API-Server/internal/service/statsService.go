@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"github.com/gocql/gocql"
+	"log"
+	"marketplace_project/config"
+	"marketplace_project/internal/chat"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/repository"
+	"time"
+)
+
+// StatsService counts how listings are seen and used, in daily buckets, and
+// reports the counts to their owners.
+type StatsService struct {
+	repo        repository.StatsRepository
+	productRepo repository.ProductRepository
+	chat        chat.Client
+	viewWindow  time.Duration
+}
+
+func NewStatsService(repo repository.StatsRepository, productRepo repository.ProductRepository, chatClient chat.Client, cfg config.StatsConfig) *StatsService {
+	return &StatsService{repo: repo, productRepo: productRepo, chat: chatClient, viewWindow: cfg.ViewDedupWindow}
+}
+
+// RecordView counts a view of the listing unless viewer already viewed it
+// within the dedup window. viewer identifies a signed-in user or, for
+// anonymous visitors, their address. Failures are logged so that they never
+// keep a listing from being shown.
+func (s *StatsService) RecordView(ctx context.Context, productID gocql.UUID, viewer string) {
+	claimed, err := s.repo.ClaimView(ctx, productID, viewer, s.viewWindow)
+	if err != nil {
+		log.Printf("Failed to record view of product %s: %v", productID, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+	if err := s.productRepo.IncrementViews(ctx, productID); err != nil {
+		log.Printf("Failed to record view of product %s: %v", productID, err)
+	}
+	s.add(ctx, productID, models.StatCounters{Views: 1})
+}
+
+func (s *StatsService) RecordFavorite(ctx context.Context, productID gocql.UUID) {
+	s.add(ctx, productID, models.StatCounters{Favorites: 1})
+}
+
+// RecordImpressions counts one search impression for each listing. It runs
+// in the background so that searches do not wait for the writes.
+func (s *StatsService) RecordImpressions(products []models.ProductWrapContent) {
+	if len(products) == 0 {
+		return
+	}
+	ids := make([]gocql.UUID, len(products))
+	for i, product := range products {
+		ids[i] = product.ProductID
+	}
+	go func() {
+		ctx := context.Background()
+		for _, id := range ids {
+			s.add(ctx, id, models.StatCounters{Impressions: 1})
+		}
+	}()
+}
+
+func (s *StatsService) add(ctx context.Context, productID gocql.UUID, counters models.StatCounters) {
+	if err := s.repo.AddToDay(ctx, productID, models.StatsDay(time.Now()), counters); err != nil {
+		log.Printf("Failed to update statistics of product %s: %v", productID, err)
+	}
+}
+
+// ListingStats returns the counters of the last days days, today included.
+// Chat counts come from the chat server; when it cannot be reached they are
+// reported as zero rather than failing the whole report.
+func (s *StatsService) ListingStats(ctx context.Context, productID gocql.UUID, days int) (*models.ListingStats, error) {
+	to := models.StatsDay(time.Now())
+	from := to.AddDate(0, 0, -(days - 1))
+
+	stored, err := s.repo.Days(ctx, productID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	byDay := make(map[string]models.StatCounters, len(stored))
+	for _, day := range stored {
+		byDay[day.Day] = day.StatCounters
+	}
+	chats, err := s.chat.ListingChats(ctx, productID, from, to)
+	if err != nil {
+		log.Printf("Failed to load chat statistics of product %s: %v", productID, err)
+	}
+
+	stats := &models.ListingStats{ProductID: productID, Days: make([]models.ListingStatsDay, 0, days)}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		key := day.Format(models.DayLayout)
+		counters := byDay[key]
+		counters.Chats += chats[key]
+		stats.Days = append(stats.Days, models.ListingStatsDay{Day: key, StatCounters: counters})
+		stats.Totals.Add(counters)
+	}
+	return stats, nil
+}
+
+func (s *StatsService) RemoveProduct(ctx context.Context, productID gocql.UUID) {
+	if err := s.repo.DeleteStats(ctx, productID); err != nil {
+		log.Printf("Failed to delete statistics of product %s: %v", productID, err)
+	}
+}
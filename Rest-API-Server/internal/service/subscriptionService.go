@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gocql/gocql"
+	"log"
+	"marketplace_project/config"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/payment"
+	"marketplace_project/internal/repository"
+	"marketplace_project/internal/utils"
+	"time"
+)
+
+// renewalRetryInterval spaces out renewal attempts after a declined payment.
+const renewalRetryInterval = time.Hour
+
+// SubscriptionService manages paid plans. Renewals happen lazily: the first
+// time a subscription is looked at after its period ended, the next period
+// is charged. Upgrades start a new period at once and are charged the price
+// difference for the rest of the current one; downgrades and cancellations
+// take effect when the paid period ends.
+type SubscriptionService struct {
+	repo     repository.SubscriptionRepository
+	provider payment.Provider
+	period   time.Duration
+	grace    time.Duration
+}
+
+func NewSubscriptionService(repo repository.SubscriptionRepository, provider payment.Provider, cfg config.PaymentConfig) *SubscriptionService {
+	return &SubscriptionService{repo: repo, provider: provider, period: cfg.Period, grace: cfg.GracePeriod}
+}
+
+func (s *SubscriptionService) Plans() []models.Plan {
+	return models.Plans
+}
+
+// CurrentPlan returns the plan whose limits apply to the user right now.
+func (s *SubscriptionService) CurrentPlan(ctx context.Context, userID gocql.UUID) (models.Plan, error) {
+	status, err := s.Status(ctx, userID)
+	if err != nil {
+		return models.Plan{}, err
+	}
+	return status.Plan, nil
+}
+
+func (s *SubscriptionService) Status(ctx context.Context, userID gocql.UUID) (*models.SubscriptionStatus, error) {
+	sub, err := s.repo.GetSubscription(ctx, userID)
+	if errors.Is(err, utils.ErrNotFound) {
+		return s.entitlement(nil, time.Now()), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if s.renewalDue(sub, now) {
+		renewed, err := s.renew(ctx, sub, now)
+		if err != nil {
+			log.Printf("Failed to renew subscription of user %s: %v", userID, err)
+		} else {
+			sub = renewed
+		}
+	}
+	return s.entitlement(sub, now), nil
+}
+
+// ChangePlan moves the user to planID. Choosing the current plan again
+// undoes a pending downgrade or cancellation.
+func (s *SubscriptionService) ChangePlan(ctx context.Context, userID gocql.UUID, planID string) (*models.SubscriptionStatus, error) {
+	plan, ok := models.PlanByID(planID)
+	if !ok {
+		return nil, utils.ErrUnknownPlan
+	}
+	status, err := s.Status(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Truncate(time.Millisecond)
+	current := status.Plan
+	sub := status.Subscription
+
+	switch {
+	case plan.ID == current.ID:
+		if sub == nil || (sub.Status != models.SubscriptionCanceled && sub.PendingPlanID == "") {
+			return status, nil
+		}
+		next := *sub
+		next.PendingPlanID = ""
+		if next.Status == models.SubscriptionCanceled {
+			next.Status = models.SubscriptionActive
+		}
+		return s.save(ctx, &next, sub, now)
+
+	case plan.PriceCents < current.PriceCents:
+		next := *sub
+		next.PendingPlanID = plan.ID
+		return s.save(ctx, &next, sub, now)
+
+	default:
+		return s.upgrade(ctx, userID, plan, current, sub, now)
+	}
+}
+
+// Cancel stops renewal. The plan stays usable until the paid period ends.
+func (s *SubscriptionService) Cancel(ctx context.Context, userID gocql.UUID) (*models.SubscriptionStatus, error) {
+	status, err := s.Status(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	sub := status.Subscription
+	if sub == nil || status.Plan.ID == models.PlanFree {
+		return nil, utils.ErrNotFound
+	}
+	next := *sub
+	next.Status = models.SubscriptionCanceled
+	next.PendingPlanID = ""
+	return s.save(ctx, &next, sub, time.Now())
+}
+
+func (s *SubscriptionService) upgrade(ctx context.Context, userID gocql.UUID, plan, current models.Plan, sub *models.Subscription, now time.Time) (*models.SubscriptionStatus, error) {
+	amount := plan.PriceCents
+	if sub != nil && current.ID != models.PlanFree && now.Before(sub.PeriodEnd) {
+		remaining := float64(sub.PeriodEnd.Sub(now)) / float64(sub.PeriodEnd.Sub(sub.PeriodStart))
+		amount -= int(float64(current.PriceCents) * remaining)
+	}
+
+	var expected *time.Time
+	key := fmt.Sprintf("%s:upgrade:%s:0", userID, plan.ID)
+	if sub != nil {
+		expected = &sub.PeriodEnd
+		key = fmt.Sprintf("%s:upgrade:%s:%d", userID, plan.ID, sub.PeriodEnd.UnixMilli())
+	}
+	reference, err := s.provider.Charge(ctx, payment.Charge{
+		UserID:         userID,
+		AmountCents:    amount,
+		Description:    plan.Name + " plan",
+		IdempotencyKey: key,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	next := s.newPeriod(userID, plan.ID, reference, now)
+	if err := s.repo.SaveSubscription(ctx, next, expected); err != nil {
+		s.refundUnlessStored(ctx, userID, reference)
+		return nil, err
+	}
+	return s.entitlement(next, now), nil
+}
+
+// renewalDue reports whether the paid period is over and the next one
+// should be charged now.
+func (s *SubscriptionService) renewalDue(sub *models.Subscription, now time.Time) bool {
+	if sub.Status == models.SubscriptionCanceled || now.Before(sub.PeriodEnd) || !now.Before(sub.GraceUntil) {
+		return false
+	}
+	return !now.Before(sub.RetryAt)
+}
+
+// renew charges the next period, switching to a pending downgrade first. A
+// declined payment marks the subscription past due; the plan then stays
+// usable until GraceUntil while the charge is retried.
+func (s *SubscriptionService) renew(ctx context.Context, sub *models.Subscription, now time.Time) (*models.Subscription, error) {
+	now = now.Truncate(time.Millisecond)
+	planID := sub.PlanID
+	if sub.PendingPlanID != "" {
+		planID = sub.PendingPlanID
+	}
+	plan, ok := models.PlanByID(planID)
+	if !ok || plan.ID == models.PlanFree {
+		next := *sub
+		next.Status = models.SubscriptionCanceled
+		next.PendingPlanID = ""
+		return &next, s.repo.SaveSubscription(ctx, &next, &sub.PeriodEnd)
+	}
+
+	// Every instance renewing the same period uses the same key, so the
+	// provider charges it once.
+	reference, err := s.provider.Charge(ctx, payment.Charge{
+		UserID:         sub.UserID,
+		AmountCents:    plan.PriceCents,
+		Description:    plan.Name + " plan renewal",
+		IdempotencyKey: fmt.Sprintf("%s:renew:%d", sub.UserID, sub.PeriodEnd.UnixMilli()),
+	})
+	if err != nil {
+		if !errors.Is(err, payment.ErrPaymentDeclined) {
+			return nil, err
+		}
+		next := *sub
+		next.Status = models.SubscriptionPastDue
+		next.RetryAt = now.Add(renewalRetryInterval)
+		return &next, s.repo.SaveSubscription(ctx, &next, &sub.PeriodEnd)
+	}
+
+	next := s.newPeriod(sub.UserID, plan.ID, reference, now)
+	if err := s.repo.SaveSubscription(ctx, next, &sub.PeriodEnd); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+func (s *SubscriptionService) newPeriod(userID gocql.UUID, planID, reference string, now time.Time) *models.Subscription {
+	end := now.Add(s.period)
+	return &models.Subscription{
+		UserID:      userID,
+		PlanID:      planID,
+		Status:      models.SubscriptionActive,
+		PeriodStart: now,
+		PeriodEnd:   end,
+		GraceUntil:  end.Add(s.grace),
+		ProviderRef: reference,
+	}
+}
+
+func (s *SubscriptionService) save(ctx context.Context, next, previous *models.Subscription, now time.Time) (*models.SubscriptionStatus, error) {
+	if err := s.repo.SaveSubscription(ctx, next, &previous.PeriodEnd); err != nil {
+		return nil, err
+	}
+	return s.entitlement(next, now), nil
+}
+
+// refundUnlessStored returns a charge that lost a race with another change,
+// unless the stored subscription is the one it paid for.
+func (s *SubscriptionService) refundUnlessStored(ctx context.Context, userID gocql.UUID, reference string) {
+	if stored, err := s.repo.GetSubscription(ctx, userID); err == nil && stored.ProviderRef == reference {
+		return
+	}
+	if err := s.provider.Refund(ctx, reference); err != nil {
+		log.Printf("Failed to refund charge %s of user %s: %v", reference, userID, err)
+	}
+}
+
+// entitlement works out which plan sub grants at now.
+func (s *SubscriptionService) entitlement(sub *models.Subscription, now time.Time) *models.SubscriptionStatus {
+	free, _ := models.PlanByID(models.PlanFree)
+	status := &models.SubscriptionStatus{Plan: free, Subscription: sub}
+	if sub == nil {
+		return status
+	}
+	plan, ok := models.PlanByID(sub.PlanID)
+	if !ok {
+		return status
+	}
+	switch {
+	case now.Before(sub.PeriodEnd):
+		status.Plan = plan
+	case sub.Status != models.SubscriptionCanceled && now.Before(sub.GraceUntil):
+		status.Plan = plan
+		status.InGrace = true
+	}
+	return status
+}
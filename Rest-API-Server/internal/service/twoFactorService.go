@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/gocql/gocql"
+	"log"
+	"marketplace_project/config"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/repository"
+	"marketplace_project/internal/utils"
+	"time"
+)
+
+// maxChallengeFailures is how many wrong codes one sign-in challenge accepts.
+const maxChallengeFailures = 5
+
+// TwoFactorService manages TOTP enrollment and the second step of sign-in.
+type TwoFactorService struct {
+	repo         repository.TwoFactorRepository
+	userRepo     repository.UserRepository
+	tokenRepo    repository.ActionTokenRepository
+	issuer       string
+	challengeTTL time.Duration
+}
+
+func NewTwoFactorService(repo repository.TwoFactorRepository, userRepo repository.UserRepository, tokenRepo repository.ActionTokenRepository, cfg config.TwoFactorConfig) *TwoFactorService {
+	return &TwoFactorService{
+		repo:         repo,
+		userRepo:     userRepo,
+		tokenRepo:    tokenRepo,
+		issuer:       cfg.Issuer,
+		challengeTTL: cfg.ChallengeTTL,
+	}
+}
+
+// Setup starts enrollment with a new secret. Sign-in is not affected until
+// Enable confirms a code generated from it.
+func (s *TwoFactorService) Setup(ctx context.Context, userID gocql.UUID) (*models.TwoFactorSetup, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled {
+		return nil, utils.ErrTwoFactorEnabled
+	}
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SetPendingSecret(ctx, userID, secret); err != nil {
+		return nil, err
+	}
+	return &models.TwoFactorSetup{
+		Secret: secret,
+		URI:    utils.TOTPProvisioningURI(s.issuer, user.Email, secret),
+	}, nil
+}
+
+// Enable turns two-factor sign-in on once code matches the pending secret and
+// returns the recovery codes. They are shown only this once.
+func (s *TwoFactorService) Enable(ctx context.Context, userID gocql.UUID, code string) ([]string, error) {
+	state, err := s.repo.GetTwoFactor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if state.Enabled {
+		return nil, utils.ErrTwoFactorEnabled
+	}
+	if state.Secret == "" {
+		return nil, utils.ErrTwoFactorDisabled
+	}
+	if err := s.checkTOTP(ctx, userID, state, code); err != nil {
+		return nil, err
+	}
+
+	codes, err := s.replaceRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.EnableTwoFactor(ctx, userID); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *TwoFactorService) Disable(ctx context.Context, userID gocql.UUID, code string) error {
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return err
+	}
+	return s.repo.DisableTwoFactor(ctx, userID)
+}
+
+// RegenerateRecoveryCodes invalidates the remaining recovery codes and
+// returns a new set.
+func (s *TwoFactorService) RegenerateRecoveryCodes(ctx context.Context, userID gocql.UUID, code string) ([]string, error) {
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return nil, err
+	}
+	return s.replaceRecoveryCodes(ctx, userID)
+}
+
+// Verify accepts either a current authenticator code or an unused recovery
+// code for an account that has two-factor sign-in enabled.
+func (s *TwoFactorService) Verify(ctx context.Context, userID gocql.UUID, code string) error {
+	state, err := s.repo.GetTwoFactor(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !state.Enabled {
+		return utils.ErrTwoFactorDisabled
+	}
+	if err := s.checkTOTP(ctx, userID, state, code); !errors.Is(err, utils.ErrInvalidCode) {
+		return err
+	}
+
+	consumed, err := s.repo.ConsumeRecoveryCode(ctx, userID, utils.HashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return utils.ErrInvalidCode
+	}
+	return nil
+}
+
+// StartChallenge issues the short-lived token that stands in for the password
+// between the two sign-in steps.
+func (s *TwoFactorService) StartChallenge(ctx context.Context, user *models.User) (string, error) {
+	token, tokenID, err := utils.GenerateActionToken(utils.TokenTypeTwoFactor, user.UserID, user.Email, s.challengeTTL)
+	if err != nil {
+		return "", err
+	}
+	if err := s.tokenRepo.SaveActionToken(ctx, tokenID, user.UserID, utils.TokenTypeTwoFactor, s.challengeTTL); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ChallengeEmail returns the email of the account a challenge was issued
+// to, so the second step can be throttled like the first.
+func (s *TwoFactorService) ChallengeEmail(challenge string) (string, error) {
+	claims, err := utils.ParseActionToken(challenge, utils.TokenTypeTwoFactor)
+	if err != nil {
+		return "", utils.ErrInvalidToken
+	}
+	return claims.Email, nil
+}
+
+// CompleteChallenge checks the code for the user the challenge was issued to
+// and consumes the challenge. After maxChallengeFailures wrong codes the
+// challenge is consumed as well and the password has to be entered again.
+func (s *TwoFactorService) CompleteChallenge(ctx context.Context, challenge, code string) (*models.User, error) {
+	claims, err := utils.ParseActionToken(challenge, utils.TokenTypeTwoFactor)
+	if err != nil {
+		return nil, utils.ErrInvalidToken
+	}
+	tokenID, err := gocql.ParseUUID(claims.Id)
+	if err != nil {
+		return nil, utils.ErrInvalidToken
+	}
+
+	if err := s.Verify(ctx, claims.UserID, code); err != nil {
+		if errors.Is(err, utils.ErrInvalidCode) {
+			s.countChallengeFailure(ctx, tokenID, claims.UserID)
+		}
+		return nil, err
+	}
+
+	consumed, err := s.tokenRepo.ConsumeActionToken(ctx, tokenID, claims.UserID, utils.TokenTypeTwoFactor)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, utils.ErrInvalidToken
+	}
+	return s.userRepo.GetUserByID(ctx, claims.UserID)
+}
+
+// countChallengeFailure records a wrong code and withdraws the challenge once
+// it has had too many.
+func (s *TwoFactorService) countChallengeFailure(ctx context.Context, tokenID, userID gocql.UUID) {
+	failures, err := s.tokenRepo.RecordActionTokenFailure(ctx, tokenID, utils.TokenTypeTwoFactor)
+	if err != nil {
+		if !errors.Is(err, utils.ErrNotFound) {
+			log.Printf("Failed to count a wrong code for challenge %s: %v", tokenID, err)
+		}
+		return
+	}
+	if failures < maxChallengeFailures {
+		return
+	}
+	if _, err := s.tokenRepo.ConsumeActionToken(ctx, tokenID, userID, utils.TokenTypeTwoFactor); err != nil {
+		log.Printf("Failed to withdraw challenge %s: %v", tokenID, err)
+	}
+}
+
+// checkTOTP validates code and records its time step, so a code that was
+// already accepted, or one older than the last accepted, is refused.
+func (s *TwoFactorService) checkTOTP(ctx context.Context, userID gocql.UUID, state *models.TwoFactor, code string) error {
+	step, ok := utils.ValidateTOTP(state.Secret, code, time.Now())
+	if !ok {
+		return utils.ErrInvalidCode
+	}
+	if state.LastStep != nil && step <= *state.LastStep {
+		return utils.ErrInvalidCode
+	}
+	applied, err := s.repo.MarkStepUsed(ctx, userID, state.LastStep, step)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return utils.ErrInvalidCode
+	}
+	return nil
+}
+
+func (s *TwoFactorService) replaceRecoveryCodes(ctx context.Context, userID gocql.UUID) ([]string, error) {
+	codes, err := utils.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = utils.HashRecoveryCode(code)
+	}
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
@@ -2,29 +2,43 @@ package service
 
 import (
 	"context"
-	"errors"
 	"github.com/gocql/gocql"
+	"log"
+	"marketplace_project/internal/imaging"
 	"marketplace_project/internal/models"
 	"marketplace_project/internal/repository"
 	"marketplace_project/internal/utils"
+	"strings"
 )
 
 type UserService struct {
-	repo repository.UserRepository
+	repo        repository.UserRepository
+	sessionRepo repository.SessionRepository
+	images      *ImageService
 }
 
-func NewUserService(repo repository.UserRepository) *UserService {
-	return &UserService{repo: repo}
+func NewUserService(repo repository.UserRepository, sessionRepo repository.SessionRepository, images *ImageService) *UserService {
+	return &UserService{repo: repo, sessionRepo: sessionRepo, images: images}
 }
 
+// Register creates the account. Uniqueness of the email address is enforced by
+// the repository, which returns ErrEmailExists when it is already taken.
 func (s *UserService) Register(user *models.User) error {
-	existingUser, err := s.repo.GetUserByEmail(context.Background(), user.Email)
-	if err != nil && !errors.Is(err, utils.ErrNotFound) {
-		return err
+	user.Email = strings.TrimSpace(user.Email)
+
+	if user.PhoneNumber != "" {
+		phone, err := utils.NormalizePhoneNumber("", user.PhoneNumber)
+		if err != nil {
+			return err
+		}
+		user.PhoneNumber = phone
 	}
-	if existingUser != nil {
-		return utils.ErrEmailExists
+
+	passwordHash, err := utils.HashPassword(user.Password)
+	if err != nil {
+		return err
 	}
+	user.Password = passwordHash
 
 	return s.repo.CreateUser(context.Background(), user)
 }
@@ -35,8 +49,24 @@ func (s *UserService) SignIn(email, password string) (*models.User, error) {
 		return nil, err
 	}
 
-	if user.Password != password {
-		return nil, errors.New("invalid password")
+	match, needsRehash, err := utils.VerifyPassword(user.Password, password)
+	if err != nil {
+		return nil, err
+	}
+	if !match {
+		return nil, utils.ErrInvalidPassword
+	}
+
+	if needsRehash {
+		passwordHash, err := utils.HashPassword(password)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.repo.UpdatePassword(context.Background(), user.UserID, passwordHash); err != nil {
+			log.Printf("Failed to rehash password for user %s: %v", user.UserID, err)
+		} else {
+			user.Password = passwordHash
+		}
 	}
 
 	return user, nil
@@ -49,3 +79,114 @@ func (s *UserService) GetUserByID(userID gocql.UUID) (*models.UserWrapContent, e
 	}
 	return user, nil
 }
+
+// SetAccountType changes the account type and therefore the role of a user.
+// The new role is picked up the next time the user's tokens are refreshed.
+func (s *UserService) SetAccountType(ctx context.Context, userID gocql.UUID, accountType string) error {
+	if models.RoleFromAccountType(accountType) != accountType {
+		return utils.ErrInvalidAccountType
+	}
+	return s.repo.UpdateAccountType(ctx, userID, accountType)
+}
+
+// ConfirmPassword checks the current password of a signed-in user before a
+// sensitive change such as a new email address.
+func (s *UserService) ConfirmPassword(ctx context.Context, userID gocql.UUID, password string) error {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	match, _, err := utils.VerifyPassword(user.Password, password)
+	if err != nil {
+		return err
+	}
+	if !match {
+		return utils.ErrInvalidPassword
+	}
+	return nil
+}
+
+func (s *UserService) UpdateProfile(ctx context.Context, userID gocql.UUID, update models.ProfileUpdate) (*models.User, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.FirstName != nil {
+		user.FirstName = *update.FirstName
+	}
+	if update.LastName != nil {
+		user.LastName = *update.LastName
+	}
+	// The avatar is the ID of an image the user uploaded; sending the current
+	// avatar URL back leaves it unchanged.
+	if update.Avatar != nil && *update.Avatar != user.Avatar {
+		avatar, err := s.images.VariantURLs(ctx, userID, []string{*update.Avatar}, imaging.VariantMedium)
+		if err != nil {
+			return nil, err
+		}
+		user.Avatar = avatar[0]
+	}
+	if update.PhoneNumber != nil {
+		if update.PhoneNumber.CountryCode == "" && update.PhoneNumber.Number == "" {
+			user.PhoneNumber = ""
+		} else {
+			phone, err := utils.NormalizePhoneNumber(update.PhoneNumber.CountryCode, update.PhoneNumber.Number)
+			if err != nil {
+				return nil, err
+			}
+			user.PhoneNumber = phone
+		}
+	}
+
+	if err := s.repo.UpdateProfile(ctx, user); err != nil {
+		return nil, err
+	}
+	user.Password = ""
+	return user, nil
+}
+
+// ChangePassword replaces the password after checking the current one and
+// signs out every other session of the user.
+func (s *UserService) ChangePassword(ctx context.Context, userID, currentSessionID gocql.UUID, currentPassword, newPassword string) error {
+	if err := s.ConfirmPassword(ctx, userID, currentPassword); err != nil {
+		return err
+	}
+	passwordHash, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.UpdatePassword(ctx, userID, passwordHash); err != nil {
+		return err
+	}
+
+	sessions, err := s.sessionRepo.ListSessions(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to list sessions of user %s after password change: %v", userID, err)
+		return nil
+	}
+	for _, session := range sessions {
+		if session.SessionID == currentSessionID {
+			continue
+		}
+		if err := s.sessionRepo.DeleteSession(ctx, userID, session.SessionID); err != nil {
+			log.Printf("Failed to revoke session %s after password change: %v", session.SessionID, err)
+		}
+	}
+	return nil
+}
+
+// DeleteAccount removes the user after checking their password and revokes
+// all of their sessions.
+func (s *UserService) DeleteAccount(ctx context.Context, userID gocql.UUID, password string) error {
+	if err := s.ConfirmPassword(ctx, userID, password); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteUser(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.sessionRepo.DeleteAllSessions(ctx, userID); err != nil {
+		log.Printf("Failed to revoke sessions of deleted user %s: %v", userID, err)
+	}
+	return nil
+}
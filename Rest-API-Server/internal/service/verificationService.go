@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gocql/gocql"
+	"log"
+	"marketplace_project/config"
+	"marketplace_project/internal/mailer"
+	"marketplace_project/internal/models"
+	"marketplace_project/internal/repository"
+	"marketplace_project/internal/utils"
+	"net/url"
+	"time"
+)
+
+// VerificationService sends and redeems the one-time links used to confirm
+// an email address and to reset a forgotten password.
+type VerificationService struct {
+	userRepo        repository.UserRepository
+	sessionRepo     repository.SessionRepository
+	tokenRepo       repository.ActionTokenRepository
+	mailer          mailer.Mailer
+	appURL          string
+	verificationTTL time.Duration
+	resetTTL        time.Duration
+}
+
+func NewVerificationService(userRepo repository.UserRepository, sessionRepo repository.SessionRepository, tokenRepo repository.ActionTokenRepository, mail mailer.Mailer, appURL string, mailCfg config.MailConfig) *VerificationService {
+	return &VerificationService{
+		userRepo:        userRepo,
+		sessionRepo:     sessionRepo,
+		tokenRepo:       tokenRepo,
+		mailer:          mail,
+		appURL:          appURL,
+		verificationTTL: mailCfg.VerificationLinkTTL,
+		resetTTL:        mailCfg.ResetLinkTTL,
+	}
+}
+
+func (s *VerificationService) SendVerificationEmail(ctx context.Context, user *models.User) error {
+	token, err := s.issueToken(ctx, utils.TokenTypeVerifyEmail, user, s.verificationTTL)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nThe link expires in %s.\n",
+			user.FirstName, s.link("/verify-email", token), s.verificationTTL),
+	})
+}
+
+func (s *VerificationService) ResendVerificationEmail(ctx context.Context, userID gocql.UUID) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return utils.ErrEmailAlreadyVerified
+	}
+	return s.SendVerificationEmail(ctx, user)
+}
+
+func (s *VerificationService) VerifyEmail(ctx context.Context, token string) error {
+	claims, err := s.redeemToken(ctx, utils.TokenTypeVerifyEmail, token)
+	if err != nil {
+		return err
+	}
+	user, err := s.userRepo.GetUserByID(ctx, claims.UserID)
+	if err != nil {
+		return err
+	}
+	if user.Email != claims.Email {
+		return utils.ErrInvalidToken
+	}
+	return s.userRepo.SetEmailVerified(ctx, user.UserID)
+}
+
+// RequestPasswordReset mails a reset link when the address belongs to an
+// account. Unknown addresses are ignored so the endpoint cannot be used to
+// find out who is registered.
+func (s *VerificationService) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	token, err := s.issueToken(ctx, utils.TokenTypeResetPassword, user, s.resetTTL)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your account. If it was you, open the link below to choose a new one:\n\n%s\n\nThe link expires in %s. If you did not ask for this, you can ignore this email.\n",
+			user.FirstName, s.link("/reset-password", token), s.resetTTL),
+	})
+}
+
+// ResetPassword sets a new password and signs the account out everywhere.
+func (s *VerificationService) ResetPassword(ctx context.Context, token, password string) error {
+	claims, err := s.redeemToken(ctx, utils.TokenTypeResetPassword, token)
+	if err != nil {
+		return err
+	}
+	passwordHash, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdatePassword(ctx, claims.UserID, passwordHash); err != nil {
+		return err
+	}
+	if err := s.sessionRepo.DeleteAllSessions(ctx, claims.UserID); err != nil {
+		log.Printf("Failed to revoke sessions of user %s after password reset: %v", claims.UserID, err)
+	}
+	return nil
+}
+
+// RequestEmailChange mails a confirmation link to newEmail. The address of the
+// account only changes once that link is opened.
+func (s *VerificationService) RequestEmailChange(ctx context.Context, userID gocql.UUID, newEmail string) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.ensureEmailAvailable(ctx, newEmail, user.UserID); err != nil {
+		return err
+	}
+
+	token, tokenID, err := utils.GenerateActionToken(utils.TokenTypeChangeEmail, user.UserID, newEmail, s.verificationTTL)
+	if err != nil {
+		return err
+	}
+	if err := s.tokenRepo.SaveActionToken(ctx, tokenID, user.UserID, utils.TokenTypeChangeEmail, s.verificationTTL); err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      newEmail,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm that you want to use this address for your account by opening the link below:\n\n%s\n\nThe link expires in %s.\n",
+			user.FirstName, s.link("/confirm-email-change", token), s.verificationTTL),
+	})
+}
+
+// ConfirmEmailChange switches the account to the address the token was sent
+// to and lets the previous address know about it.
+func (s *VerificationService) ConfirmEmailChange(ctx context.Context, token string) error {
+	claims, err := s.redeemToken(ctx, utils.TokenTypeChangeEmail, token)
+	if err != nil {
+		return err
+	}
+	user, err := s.userRepo.GetUserByID(ctx, claims.UserID)
+	if err != nil {
+		return err
+	}
+	if err := s.ensureEmailAvailable(ctx, claims.Email, user.UserID); err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdateEmail(ctx, user.UserID, claims.Email); err != nil {
+		return err
+	}
+
+	if err := s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your email address was changed",
+		Body: fmt.Sprintf("Hi %s,\n\nThe email address of your account was changed to %s. If you did not do this, reset your password and contact support.\n",
+			user.FirstName, claims.Email),
+	}); err != nil {
+		log.Printf("Failed to notify user %s about the email change: %v", user.UserID, err)
+	}
+	return nil
+}
+
+// ensureEmailAvailable fails with ErrEmailExists when another account uses
+// email; userID may keep its own address with different capitalization.
+func (s *VerificationService) ensureEmailAvailable(ctx context.Context, email string, userID gocql.UUID) error {
+	owner, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err == nil {
+		if owner.UserID == userID {
+			return nil
+		}
+		return utils.ErrEmailExists
+	}
+	if errors.Is(err, utils.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (s *VerificationService) issueToken(ctx context.Context, purpose string, user *models.User, ttl time.Duration) (string, error) {
+	token, tokenID, err := utils.GenerateActionToken(purpose, user.UserID, user.Email, ttl)
+	if err != nil {
+		return "", err
+	}
+	if err := s.tokenRepo.SaveActionToken(ctx, tokenID, user.UserID, purpose, ttl); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// redeemToken checks the signature and expiry of token and consumes its
+// server-side record, so each link works only once.
+func (s *VerificationService) redeemToken(ctx context.Context, purpose, token string) (*utils.Claims, error) {
+	claims, err := utils.ParseActionToken(token, purpose)
+	if err != nil {
+		return nil, utils.ErrInvalidToken
+	}
+	tokenID, err := gocql.ParseUUID(claims.Id)
+	if err != nil {
+		return nil, utils.ErrInvalidToken
+	}
+	consumed, err := s.tokenRepo.ConsumeActionToken(ctx, tokenID, claims.UserID, purpose)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, utils.ErrInvalidToken
+	}
+	return claims, nil
+}
+
+func (s *VerificationService) link(path, token string) string {
+	return s.appURL + path + "?token=" + url.QueryEscape(token)
+}
@@ -9,30 +9,64 @@ import (
 	"time"
 )
 
-var jwtKey = []byte("Baylus")
+const (
+	TokenTypeAccess        = "access"
+	TokenTypeRefresh       = "refresh"
+	TokenTypeVerifyEmail   = "verify_email"
+	TokenTypeResetPassword = "reset_password"
+	TokenTypeChangeEmail   = "change_email"
+	TokenTypeTwoFactor     = "2fa_challenge"
+)
 
 type Claims struct {
-	UserID gocql.UUID `json:"user_id"`
-	Email  string     `json:"email"`
+	UserID        gocql.UUID `json:"user_id"`
+	Email         string     `json:"email"`
+	EmailVerified bool       `json:"email_verified,omitempty"`
+	Role          string     `json:"role,omitempty"`
+	TokenType     string     `json:"typ"`
+	SessionID     gocql.UUID `json:"sid,omitempty"`
 	jwt.StandardClaims
 }
 
-func GenerateToken(userID gocql.UUID, email string) (string, string, error) {
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID.String(),
-		"email":   email,
-		"exp":     time.Now().Add(time.Second * 10).Unix(), // 7 days expiration
-	})
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID.String(),
-		"email":   email,
-		"exp":     time.Now().Add(time.Hour * 24 * 7).Unix(), // 7 days expiration
+// TokenSubject is the user data carried in access tokens.
+type TokenSubject struct {
+	UserID        gocql.UUID
+	Email         string
+	EmailVerified bool
+	Role          string
+}
+
+// GenerateToken issues an access and a refresh token for the session
+// sessionID. refreshTokenID becomes the jti of the refresh token so the
+// server-side record can be looked up when the token is presented again.
+func GenerateToken(subject TokenSubject, sessionID gocql.UUID, refreshTokenID gocql.UUID) (string, string, error) {
+	now := time.Now()
+	accessTokenString, err := signToken(Claims{
+		UserID:        subject.UserID,
+		Email:         subject.Email,
+		EmailVerified: subject.EmailVerified,
+		Role:          subject.Role,
+		TokenType:     TokenTypeAccess,
+		SessionID:     sessionID,
+		StandardClaims: jwt.StandardClaims{
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(tokenKeys.accessTTL).Unix(),
+		},
 	})
-	accessTokenString, err := accessToken.SignedString(jwtKey)
 	if err != nil {
 		return "", "", err
 	}
-	refreshTokenString, err := refreshToken.SignedString(jwtKey)
+	refreshTokenString, err := signToken(Claims{
+		UserID:    subject.UserID,
+		Email:     subject.Email,
+		TokenType: TokenTypeRefresh,
+		SessionID: sessionID,
+		StandardClaims: jwt.StandardClaims{
+			Id:        refreshTokenID.String(),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(tokenKeys.refreshTTL).Unix(),
+		},
+	})
 	if err != nil {
 		return "", "", err
 	}
@@ -40,22 +74,61 @@ func GenerateToken(userID gocql.UUID, email string) (string, string, error) {
 	return accessTokenString, refreshTokenString, nil
 }
 
-func ExtractUserIdAndEmailFromContext(tokenString string) (gocql.UUID, string, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
+// GenerateActionToken issues a single-purpose token, such as an email
+// verification link. The returned ID is the jti the caller stores to make
+// the token usable only once.
+func GenerateActionToken(tokenType string, userID gocql.UUID, email string, ttl time.Duration) (string, gocql.UUID, error) {
+	now := time.Now()
+	tokenID := gocql.TimeUUID()
+	token, err := signToken(Claims{
+		UserID:    userID,
+		Email:     email,
+		TokenType: tokenType,
+		StandardClaims: jwt.StandardClaims{
+			Id:        tokenID.String(),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
+		},
 	})
 	if err != nil {
-		return gocql.UUID{}, "", err
+		return "", gocql.UUID{}, err
 	}
+	return token, tokenID, nil
+}
 
-	claims, ok := token.Claims.(*Claims)
-	if !ok || !token.Valid {
-		return gocql.UUID{}, "", errors.New("invalid token")
+// signToken signs claims with the active key and records its kid in the header.
+func signToken(claims jwt.Claims) (string, error) {
+	if tokenKeys == nil {
+		return "", errors.New("token keys are not configured")
 	}
+	token := jwt.NewWithClaims(tokenKeys.active.method, claims)
+	token.Header["kid"] = tokenKeys.active.id
+	return token.SignedString(tokenKeys.active.signKey)
+}
+
+func ParseAccessToken(tokenString string) (*Claims, error) {
+	return parseToken(tokenString, TokenTypeAccess)
+}
 
-	userID := claims.UserID
-	email := claims.Email
-	return userID, email, nil
+func ParseRefreshToken(tokenString string) (*Claims, error) {
+	return parseToken(tokenString, TokenTypeRefresh)
+}
+
+func ParseActionToken(tokenString string, tokenType string) (*Claims, error) {
+	return parseToken(tokenString, tokenType)
+}
+
+func parseToken(tokenString string, tokenType string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, lookupKey)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid || claims.TokenType != tokenType {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
 }
 
 func ExtractBearerToken(r *http.Request) (string, error) {
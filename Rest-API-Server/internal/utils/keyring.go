@@ -0,0 +1,275 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"log"
+	"marketplace_project/config"
+	"math/big"
+	"os"
+	"time"
+)
+
+var ErrUnknownSigningKey = errors.New("unknown signing key")
+
+type signingKey struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+	// notAfter limits how long a retired key is accepted; zero means forever.
+	notAfter time.Time
+}
+
+// keyring holds the key new tokens are signed with and the retired keys that
+// are still accepted while tokens signed by them are in circulation.
+type keyring struct {
+	active     *signingKey
+	keys       map[string]*signingKey
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+var tokenKeys *keyring
+
+// ConfigureTokens loads the signing keys and token lifetimes. It must be called
+// before any token is generated or verified.
+func ConfigureTokens(cfg config.JWTConfig) error {
+	ring := &keyring{
+		keys:       make(map[string]*signingKey),
+		accessTTL:  cfg.AccessTokenTTL,
+		refreshTTL: cfg.RefreshTokenTTL,
+	}
+
+	active, err := loadSigningKey(cfg.ActiveKey)
+	if err != nil {
+		return err
+	}
+	if active.signKey == nil {
+		return fmt.Errorf("active key %q has no private key", active.id)
+	}
+	ring.active = active
+	ring.keys[active.id] = active
+
+	// Each retired key keeps its own algorithm, so tokens signed before a
+	// switch of algorithm stay valid for the rest of the window.
+	retiredUntil := cfg.RotatedAt.Add(cfg.RotationWindow)
+	for _, keyCfg := range cfg.PreviousKeys {
+		if _, exists := ring.keys[keyCfg.ID]; exists {
+			return fmt.Errorf("duplicate key id %q", keyCfg.ID)
+		}
+		key, err := loadSigningKey(keyCfg)
+		if err != nil {
+			return err
+		}
+		key.signKey = nil
+		key.notAfter = retiredUntil
+		ring.keys[key.id] = key
+	}
+
+	tokenKeys = ring
+	return nil
+}
+
+func loadSigningKey(cfg config.KeyConfig) (*signingKey, error) {
+	method := jwt.GetSigningMethod(cfg.Algorithm)
+	if method == nil || !isSupportedAlgorithm(method.Alg()) {
+		return nil, fmt.Errorf("unsupported JWT algorithm %q for key %q", cfg.Algorithm, cfg.ID)
+	}
+	key := &signingKey{id: cfg.ID, method: method}
+
+	if method.Alg() == jwt.SigningMethodHS256.Alg() {
+		secret := []byte(cfg.Material)
+		if len(secret) == 0 {
+			log.Printf("No secret configured for JWT key %q, using a random one; tokens will not survive a restart", cfg.ID)
+			secret = make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				return nil, err
+			}
+		}
+		key.signKey = secret
+		key.verifyKey = secret
+		return key, nil
+	}
+
+	if cfg.Material == "" {
+		if method.Alg() != SigningMethodEdDSA.Alg() {
+			return nil, fmt.Errorf("no key file configured for JWT key %q", cfg.ID)
+		}
+		log.Printf("No key file configured for JWT key %q, using a random Ed25519 key; tokens will not survive a restart", cfg.ID)
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		key.signKey, key.verifyKey = private, public
+		return key, nil
+	}
+	data, err := os.ReadFile(cfg.Material)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key file for %q is not PEM encoded", cfg.ID)
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse key %q: %w", cfg.ID, err)
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.signKey, key.verifyKey = k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.verifyKey = k
+	case ed25519.PrivateKey:
+		key.signKey, key.verifyKey = k, k.Public()
+	case ed25519.PublicKey:
+		key.verifyKey = k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T for %q", parsed, cfg.ID)
+	}
+
+	_, isRSA := key.verifyKey.(*rsa.PublicKey)
+	if isRSA != (method.Alg() == jwt.SigningMethodRS256.Alg()) {
+		return nil, fmt.Errorf("key %q does not match algorithm %s", cfg.ID, method.Alg())
+	}
+	return key, nil
+}
+
+func isSupportedAlgorithm(alg string) bool {
+	return alg == jwt.SigningMethodHS256.Alg() || alg == jwt.SigningMethodRS256.Alg() || alg == SigningMethodEdDSA.Alg()
+}
+
+// lookupKey is the jwt.Keyfunc used when parsing tokens. It selects the key by
+// the kid header and refuses tokens whose alg does not match that key.
+func lookupKey(token *jwt.Token) (interface{}, error) {
+	if tokenKeys == nil {
+		return nil, errors.New("token keys are not configured")
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, ok := tokenKeys.keys[kid]
+	if !ok {
+		return nil, ErrUnknownSigningKey
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+	if !key.notAfter.IsZero() && time.Now().After(key.notAfter) {
+		return nil, ErrUnknownSigningKey
+	}
+	return key.verifyKey, nil
+}
+
+func AccessTokenTTL() time.Duration {
+	return tokenKeys.accessTTL
+}
+
+func RefreshTokenTTL() time.Duration {
+	return tokenKeys.refreshTTL
+}
+
+// JSONWebKey is the public part of a signing key as published in a JWKS
+// document. Symmetric keys are never published.
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// PublicKeys returns the verification keys that other services, such as the
+// Websocket-Server, need to validate tokens without the private key.
+func PublicKeys() []JSONWebKey {
+	keys := []JSONWebKey{}
+	if tokenKeys == nil {
+		return keys
+	}
+	now := time.Now()
+	for _, key := range tokenKeys.keys {
+		if !key.notAfter.IsZero() && now.After(key.notAfter) {
+			continue
+		}
+		jwk := JSONWebKey{Kid: key.id, Alg: key.method.Alg(), Use: "sig"}
+		switch pub := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		keys = append(keys, jwk)
+	}
+	return keys
+}
+
+// signingMethodEdDSA adds Ed25519 signatures to jwt-go, which only ships with
+// HMAC, RSA and ECDSA methods.
+type signingMethodEdDSA struct{}
+
+var SigningMethodEdDSA = &signingMethodEdDSA{}
+
+func init() {
+	jwt.RegisterSigningMethod(SigningMethodEdDSA.Alg(), func() jwt.SigningMethod {
+		return SigningMethodEdDSA
+	})
+}
+
+func (m *signingMethodEdDSA) Alg() string {
+	return "EdDSA"
+}
+
+func (m *signingMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+	signature, err := privateKey.Sign(rand.Reader, []byte(signingString), crypto.Hash(0))
+	if err != nil {
+		return "", err
+	}
+	return jwt.EncodeSegment(signature), nil
+}
+
+func (m *signingMethodEdDSA) Verify(signingString, signature string, key interface{}) error {
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(publicKey, []byte(signingString), sig) {
+		return jwt.ErrSignatureInvalid
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package utils
+
+import "strings"
+
+// ExtractKeywords splits text into the lower-cased words listings are indexed
+// and searched by, in order of first appearance and without repeats.
+func ExtractKeywords(text string) []string {
+	splitFunc := func(r rune) bool {
+		return r == ' ' || r == '_' || r == '-' || r == '.' || r == ','
+	}
+
+	seen := make(map[string]bool)
+	var keywords []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), splitFunc) {
+		if !seen[word] {
+			seen[word] = true
+			keywords = append(keywords, word)
+		}
+	}
+	return keywords
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalidPhoneNumber = errors.New("invalid phone number")
+
+// NormalizePhoneNumber returns the number in E.164 format, e.g. +37491123456.
+// countryCode may be empty when number is already written in international
+// form with a leading "+". Spaces, dashes, dots and parentheses are ignored,
+// and the national trunk prefix 0 is dropped from the subscriber number.
+func NormalizePhoneNumber(countryCode, number string) (string, error) {
+	countryCode = stripPhoneSeparators(countryCode)
+	number = stripPhoneSeparators(number)
+
+	if countryCode == "" {
+		if !strings.HasPrefix(number, "+") {
+			return "", ErrInvalidPhoneNumber
+		}
+		return validateE164(number)
+	}
+
+	countryCode = strings.TrimPrefix(countryCode, "+")
+	if len(countryCode) == 0 || len(countryCode) > 3 || countryCode[0] == '0' || !isDigits(countryCode) {
+		return "", ErrInvalidPhoneNumber
+	}
+	// Italian numbers keep their leading zero in international format.
+	if countryCode != "39" {
+		number = strings.TrimPrefix(number, "0")
+	}
+	return validateE164("+" + countryCode + number)
+}
+
+func validateE164(number string) (string, error) {
+	digits := strings.TrimPrefix(number, "+")
+	if len(digits) < 7 || len(digits) > 15 || digits[0] == '0' || !isDigits(digits) {
+		return "", ErrInvalidPhoneNumber
+	}
+	return "+" + digits, nil
+}
+
+func stripPhoneSeparators(value string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(value))
+}
+
+func isDigits(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return value != ""
+}
@@ -6,9 +6,31 @@ import (
 )
 
 var (
-	ErrEmailExists    = errors.New("email already exists")
-	ErrCategoryExists = errors.New("category already exists")
-	ErrNotFound       = errors.New("record not found")
+	ErrEmailExists          = errors.New("email already exists")
+	ErrCategoryExists       = errors.New("category already exists")
+	ErrNotFound             = errors.New("record not found")
+	ErrInvalidToken         = errors.New("invalid token")
+	ErrTokenReused          = errors.New("refresh token reuse detected")
+	ErrEmailAlreadyVerified = errors.New("email already verified")
+	ErrInvalidAccountType   = errors.New("invalid account type")
+	ErrForbidden            = errors.New("forbidden")
+	ErrVersionConflict      = errors.New("version conflict")
+	ErrInvalidPassword      = errors.New("invalid password")
+	ErrInvalidCode          = errors.New("invalid verification code")
+	ErrTwoFactorEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorDisabled    = errors.New("two-factor authentication is not enabled")
+	ErrUnknownProvider      = errors.New("unknown identity provider")
+	ErrEmailNotVerified     = errors.New("email address is not verified")
+	ErrInvalidScope         = errors.New("invalid scope")
+	ErrLimitReached         = errors.New("limit reached")
+	ErrTooManyAttempts      = errors.New("too many attempts")
+	ErrAlreadyReviewed      = errors.New("listing already reviewed")
+	ErrAlreadyReplied       = errors.New("review already has a reply")
+	ErrUnknownPlan          = errors.New("unknown plan")
+	ErrSelfAction           = errors.New("not possible on your own account")
+	ErrUnsupportedImage     = errors.New("unsupported image")
+	ErrUnknownImage         = errors.New("unknown image")
+	ErrInvalidTransition    = errors.New("status change not allowed")
 )
 
 func RespondWithJSON(c *gin.Context, statusCode int, data interface{}) {
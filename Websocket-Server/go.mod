@@ -3,6 +3,7 @@ module marketplace_websocket
 go 1.20
 
 require (
+	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/gin-gonic/gin v1.10.0
 	github.com/gocql/gocql v1.6.0
 	github.com/gorilla/websocket v1.5.3
@@ -0,0 +1,207 @@
+// Package auth verifies the access tokens the API server issues, with the
+// public keys it publishes at /.well-known/jwks.json.
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gocql/gocql"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// keyRefreshInterval limits how often an unknown kid triggers a JWKS reload.
+const keyRefreshInterval = time.Minute
+
+// accessTokenType is the typ claim of access tokens; refresh and action
+// tokens are signed with the same keys and must not open a connection.
+const accessTokenType = "access"
+
+type claims struct {
+	UserID    gocql.UUID `json:"user_id"`
+	TokenType string     `json:"typ"`
+	jwt.StandardClaims
+}
+
+type publicKey struct {
+	alg string
+	key interface{}
+}
+
+// Verifier checks access tokens against the API server's published keys.
+// Keys are cached and reloaded when a token names one that is not known yet,
+// so key rotation on the API server is picked up.
+type Verifier struct {
+	jwksURL string
+	client  *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]publicKey
+	fetchedAt time.Time
+}
+
+// NewVerifier returns a Verifier for the JWKS document at jwksURL.
+func NewVerifier(jwksURL string) *Verifier {
+	return &Verifier{jwksURL: jwksURL, client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+// UserID verifies an access token and returns the user it was issued to.
+func (v *Verifier) UserID(ctx context.Context, token string) (gocql.UUID, error) {
+	var tokenClaims claims
+	parsed, err := jwt.ParseWithClaims(token, &tokenClaims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := v.key(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != key.alg {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return key.key, nil
+	})
+	if err != nil || !parsed.Valid || tokenClaims.TokenType != accessTokenType {
+		return gocql.UUID{}, ErrInvalidToken
+	}
+	return tokenClaims.UserID, nil
+}
+
+// FromRequest returns the token of a WebSocket handshake. Browsers cannot set
+// headers on it, so the token is taken from the token query parameter unless
+// an Authorization header is present.
+func FromRequest(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer ")
+	}
+	return r.URL.Query().Get("token")
+}
+
+func (v *Verifier) key(ctx context.Context, kid string) (publicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(v.fetchedAt) < keyRefreshInterval {
+		return publicKey{}, fmt.Errorf("unknown key %q", kid)
+	}
+	keys, err := v.fetch(ctx)
+	if err != nil {
+		v.fetchedAt = time.Now()
+		log.Printf("Failed to load token keys from %s: %v", v.jwksURL, err)
+		return publicKey{}, err
+	}
+	v.keys = keys
+	v.fetchedAt = time.Now()
+
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	return publicKey{}, fmt.Errorf("unknown key %q", kid)
+}
+
+func (v *Verifier) fetch(ctx context.Context) (map[string]publicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.jwksURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api server: %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Alg string `json:"alg"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]publicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		switch {
+		case jwk.Kty == "RSA" && jwk.Alg == jwt.SigningMethodRS256.Alg():
+			n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+			if err != nil {
+				continue
+			}
+			e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+			if err != nil {
+				continue
+			}
+			keys[jwk.Kid] = publicKey{alg: jwk.Alg, key: &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}}
+		case jwk.Kty == "OKP" && jwk.Crv == "Ed25519" && jwk.Alg == signingMethodEdDSA.Alg():
+			x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+			if err != nil || len(x) != ed25519.PublicKeySize {
+				continue
+			}
+			keys[jwk.Kid] = publicKey{alg: jwk.Alg, key: ed25519.PublicKey(x)}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no RS256 or EdDSA key is published; the API server must not sign with HS256")
+	}
+	return keys, nil
+}
+
+// edDSA adds verification of Ed25519 signatures to jwt-go, which only ships
+// with HMAC, RSA and ECDSA methods. Tokens are never signed here.
+type edDSA struct{}
+
+var signingMethodEdDSA = &edDSA{}
+
+func init() {
+	jwt.RegisterSigningMethod(signingMethodEdDSA.Alg(), func() jwt.SigningMethod {
+		return signingMethodEdDSA
+	})
+}
+
+func (m *edDSA) Alg() string {
+	return "EdDSA"
+}
+
+func (m *edDSA) Sign(signingString string, key interface{}) (string, error) {
+	return "", errors.New("EdDSA signing is not supported")
+}
+
+func (m *edDSA) Verify(signingString, signature string, key interface{}) error {
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(publicKey, []byte(signingString), sig) {
+		return jwt.ErrSignatureInvalid
+	}
+	return nil
+}
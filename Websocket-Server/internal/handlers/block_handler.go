@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gocql/gocql"
+	"marketplace_websocket/internal/marketplace"
+	"net/http"
+)
+
+type BlockHandler struct {
+	users *marketplace.Cache
+}
+
+func NewBlockHandler(users *marketplace.Cache) *BlockHandler {
+	return &BlockHandler{users: users}
+}
+
+// BlocksChanged drops the cached block lists of the users. The API server
+// calls it when one of them blocks or unblocks the other.
+func (h *BlockHandler) BlocksChanged(c *gin.Context) {
+	var req struct {
+		UserIDs []gocql.UUID `json:"userIDs"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	h.users.Invalidate(req.UserIDs...)
+	c.Status(http.StatusNoContent)
+}
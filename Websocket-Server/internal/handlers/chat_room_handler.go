@@ -3,11 +3,13 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/gocql/gocql"
 	"io/ioutil"
 	"marketplace_websocket/internal/models"
+	"marketplace_websocket/internal/repository"
 	"marketplace_websocket/internal/service"
 	"marketplace_websocket/internal/websocket"
 	"net/http"
@@ -29,12 +31,16 @@ func (h *ChatRoomHandler) GetMessagesFromChatID(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
 		return
 	}
-	messages, err := h.messageService.GetMessagesFromChatRoom(c.Request.Context(), chatID)
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	messages, next, err := h.messageService.GetMessagesFromChatRoom(c.Request.Context(), chatID, page)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve messages"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"messages": messages})
+	respondWithPage(c, "messages", messages, next)
 }
 
 func (h *ChatRoomHandler) GetChatIDByUsers(c *gin.Context) {
@@ -100,12 +106,28 @@ func (h *ChatRoomHandler) GetUserChats(c *gin.Context) {
 		return
 	}
 
-	chatRooms, err := h.chatRoomService.GetUserChats(c.Request.Context(), userID)
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	chatRooms, next, err := h.chatRoomService.GetUserChats(c.Request.Context(), userID, page)
 	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve chat rooms"})
 		return
 	}
 
+	// Users on either side of a block do not see each other online.
+	blocked, err := h.messageService.BlockedUsers(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve blocked users"})
+		return
+	}
+
 	chatDetails := make([]models.UserProfile, len(chatRooms))
 
 	for i, chatRoom := range chatRooms {
@@ -134,10 +156,10 @@ func (h *ChatRoomHandler) GetUserChats(c *gin.Context) {
 			FirstName:   userProfile.FirstName,
 			LastName:    userProfile.LastName,
 			Avatar:      userProfile.Avatar,
-			Status:      h.hub.IsUserConnected(userProfile.UserID),
+			Status:      !blocked[userProfile.UserID] && h.hub.IsUserConnected(userProfile.UserID),
 			LastMessage: lastMessage,
 			ChatID:      chatRoom.ID,
 		}
 	}
-	c.JSON(http.StatusOK, chatDetails)
+	respondWithPage(c, "chats", chatDetails, next)
 }
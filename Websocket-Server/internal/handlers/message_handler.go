@@ -4,8 +4,10 @@ import (
 	"context"
 	"github.com/gin-gonic/gin"
 	"github.com/gocql/gocql"
+	"marketplace_websocket/internal/models"
 	"marketplace_websocket/internal/service"
 	"net/http"
+	"time"
 )
 
 type MessageHandler struct {
@@ -23,11 +25,67 @@ func (h *MessageHandler) GetMessagesFromChatID(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
 		return
 	}
-	messages, err := h.messageService.GetMessagesFromChatRoom(context.Background(), chatID)
+	page, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	messages, next, err := h.messageService.GetMessagesFromChatRoom(context.Background(), chatID, page)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve messages"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"messages": messages})
+	respondWithPage(c, "messages", messages, next)
+}
+
+// HasListingChat answers whether a buyer has contacted a seller about a
+// listing. The API server asks it before accepting a review.
+func (h *MessageHandler) HasListingChat(c *gin.Context) {
+	productID, err := gocql.ParseUUID(c.Query("productID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+	buyerID, err := gocql.ParseUUID(c.Query("buyerID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid buyer ID"})
+		return
+	}
+	sellerID, err := gocql.ParseUUID(c.Query("sellerID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid seller ID"})
+		return
+	}
+	chatted, err := h.messageService.HasChattedAboutListing(c.Request.Context(), productID, buyerID, sellerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up chats"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"chatted": chatted})
+}
+
+// ListingChats answers how many conversations a listing started per day. The
+// API server includes them in the listing statistics shown to its owner.
+func (h *MessageHandler) ListingChats(c *gin.Context) {
+	productID, err := gocql.ParseUUID(c.Query("productID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+	from, err := time.Parse(models.DayLayout, c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from day"})
+		return
+	}
+	to, err := time.Parse(models.DayLayout, c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to day"})
+		return
+	}
+	days, err := h.messageService.ListingChatsByDay(c.Request.Context(), productID, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up chats"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"days": days})
 }
@@ -0,0 +1,67 @@
+package marketplace
+
+import (
+	"context"
+	"github.com/gocql/gocql"
+	"sync"
+	"time"
+)
+
+// Cache keeps the block lists the API server returns for ttl, so sending a
+// message or announcing presence does not cost a request each time. The API
+// server calls Invalidate when a block is added or lifted.
+type Cache struct {
+	Client
+	ttl time.Duration
+
+	mu      sync.Mutex
+	blocked map[gocql.UUID]blockList
+	// invalidations counts calls of Invalidate, so that a list fetched
+	// while one happened is not cached.
+	invalidations uint64
+}
+
+type blockList struct {
+	users     map[gocql.UUID]bool
+	fetchedAt time.Time
+}
+
+// NewCache returns a Cache in front of client.
+func NewCache(client Client, ttl time.Duration) *Cache {
+	return &Cache{Client: client, ttl: ttl, blocked: make(map[gocql.UUID]blockList)}
+}
+
+// BlockedUsers returns the cached block list of userID, asking the API
+// server when there is none or it is older than the TTL. The returned map
+// must not be modified.
+func (c *Cache) BlockedUsers(ctx context.Context, userID gocql.UUID) (map[gocql.UUID]bool, error) {
+	c.mu.Lock()
+	list, ok := c.blocked[userID]
+	invalidations := c.invalidations
+	c.mu.Unlock()
+	if ok && time.Since(list.fetchedAt) < c.ttl {
+		return list.users, nil
+	}
+
+	fetchedAt := time.Now()
+	users, err := c.Client.BlockedUsers(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if c.invalidations == invalidations {
+		c.blocked[userID] = blockList{users: users, fetchedAt: fetchedAt}
+	}
+	c.mu.Unlock()
+	return users, nil
+}
+
+// Invalidate drops the cached block lists of the users.
+func (c *Cache) Invalidate(userIDs ...gocql.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.invalidations++
+	for _, userID := range userIDs {
+		delete(c.blocked, userID)
+	}
+}
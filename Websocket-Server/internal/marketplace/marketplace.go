@@ -0,0 +1,99 @@
+// Package marketplace queries the API server about users and listings.
+package marketplace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gocql/gocql"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrUnknownProduct is returned for a listing the API server does not know.
+var ErrUnknownProduct = errors.New("unknown product")
+
+var errNotFound = errors.New("not found")
+
+// Client answers questions about users and listings that the API server
+// owns.
+type Client interface {
+	// BlockedUsers returns the accounts userID has blocked or was blocked
+	// by. Neither side may message the other or see the other's presence.
+	BlockedUsers(ctx context.Context, userID gocql.UUID) (map[gocql.UUID]bool, error)
+	// ProductOwner returns the account that published the listing.
+	ProductOwner(ctx context.Context, productID gocql.UUID) (gocql.UUID, error)
+}
+
+type httpClient struct {
+	baseURL string
+	secret  string
+	client  *http.Client
+}
+
+// NewClient returns a Client for the API server at baseURL. secret is the
+// shared secret the API server's internal routes require.
+func NewClient(baseURL, secret string) Client {
+	return &httpClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		secret:  secret,
+		client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (c *httpClient) BlockedUsers(ctx context.Context, userID gocql.UUID) (map[gocql.UUID]bool, error) {
+	params := url.Values{}
+	params.Set("userID", userID.String())
+
+	var result struct {
+		UserIDs []gocql.UUID `json:"userIDs"`
+	}
+	if err := c.get(ctx, "/blockedUsers", params, &result); err != nil {
+		return nil, err
+	}
+	blocked := make(map[gocql.UUID]bool, len(result.UserIDs))
+	for _, id := range result.UserIDs {
+		blocked[id] = true
+	}
+	return blocked, nil
+}
+
+func (c *httpClient) ProductOwner(ctx context.Context, productID gocql.UUID) (gocql.UUID, error) {
+	params := url.Values{}
+	params.Set("productID", productID.String())
+
+	var result struct {
+		OwnerID gocql.UUID `json:"ownerID"`
+	}
+	if err := c.get(ctx, "/productOwner", params, &result); err != nil {
+		if errors.Is(err, errNotFound) {
+			return gocql.UUID{}, ErrUnknownProduct
+		}
+		return gocql.UUID{}, err
+	}
+	return result.OwnerID, nil
+}
+
+func (c *httpClient) get(ctx context.Context, path string, params url.Values, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Internal-Secret", c.secret)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return json.NewDecoder(resp.Body).Decode(target)
+	case http.StatusNotFound:
+		return errNotFound
+	default:
+		return fmt.Errorf("api server: %s", resp.Status)
+	}
+}
@@ -11,6 +11,7 @@ type Message struct {
 	SenderID    gocql.UUID `json:"senderID"`    // ID of the user who sent the message
 	RecipientID gocql.UUID `json:"recipientID"` // ID of the user who receives the message
 	ChatRoomID  gocql.UUID `json:"chatRoomID"`
+	ProductID   gocql.UUID `json:"productID"`   // Listing the message is about, if any
 	Timestamp   time.Time  `json:"timestamp"`   // Unix timestamp of when the message was sent
 	MessageType string     `json:"messageType"` // Type of the message (text, image, etc.)
 }
@@ -29,3 +30,13 @@ type ChatRoomNotification struct {
 	RecipientID gocql.UUID `json:"recipientID"`
 	ChatRoomID  gocql.UUID `json:"chatRoomID"`
 }
+
+// DayLayout is how days are written in listing statistics.
+const DayLayout = "2006-01-02"
+
+// ListingChatDay is the number of conversations a listing started on one
+// UTC day.
+type ListingChatDay struct {
+	Day   string `json:"day"`
+	Chats int64  `json:"chats"`
+}
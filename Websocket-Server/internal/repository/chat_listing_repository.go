@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/gocql/gocql"
+	"marketplace_websocket/internal/models"
+	"time"
+)
+
+// ChatListingRepository remembers which listings a buyer has written to a
+// seller about, so the API server can tell whether a review is genuine, and
+// counts the conversations each listing started per day.
+type ChatListingRepository interface {
+	// RecordListingChat reports whether this is the buyer's first message
+	// to the seller about the listing.
+	RecordListingChat(ctx context.Context, productID, buyerID, sellerID, chatID gocql.UUID) (bool, error)
+	HasListingChat(ctx context.Context, productID, buyerID, sellerID gocql.UUID) (bool, error)
+	IncrementListingChats(ctx context.Context, productID gocql.UUID, day time.Time) error
+	ListingChatsByDay(ctx context.Context, productID gocql.UUID, from, to time.Time) ([]models.ListingChatDay, error)
+}
+
+type chatListingRepository struct {
+	session *gocql.Session
+}
+
+func NewChatListingRepository(session *gocql.Session) ChatListingRepository {
+	return &chatListingRepository{session: session}
+}
+
+func (r *chatListingRepository) RecordListingChat(ctx context.Context, productID, buyerID, sellerID, chatID gocql.UUID) (bool, error) {
+	query := "INSERT INTO messenger_keyspace.chat_listings(product_id, buyer_id, seller_id, chat_id) VALUES (?, ?, ?, ?) IF NOT EXISTS"
+	return r.session.Query(query, productID, buyerID, sellerID, chatID).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+}
+
+func (r *chatListingRepository) HasListingChat(ctx context.Context, productID, buyerID, sellerID gocql.UUID) (bool, error) {
+	var chatID gocql.UUID
+	query := "SELECT chat_id FROM messenger_keyspace.chat_listings WHERE product_id = ? AND buyer_id = ? AND seller_id = ?"
+	if err := r.session.Query(query, productID, buyerID, sellerID).WithContext(ctx).Scan(&chatID); err != nil {
+		if errors.Is(err, gocql.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *chatListingRepository) IncrementListingChats(ctx context.Context, productID gocql.UUID, day time.Time) error {
+	query := "UPDATE messenger_keyspace.listing_chat_stats SET chats = chats + 1 WHERE product_id = ? AND day = ?"
+	return r.session.Query(query, productID, day).WithContext(ctx).Exec()
+}
+
+func (r *chatListingRepository) ListingChatsByDay(ctx context.Context, productID gocql.UUID, from, to time.Time) ([]models.ListingChatDay, error) {
+	query := "SELECT day, chats FROM messenger_keyspace.listing_chat_stats WHERE product_id = ? AND day >= ? AND day <= ?"
+	iter := r.session.Query(query, productID, from, to).WithContext(ctx).Iter()
+
+	var days []models.ListingChatDay
+	var day time.Time
+	var chats int64
+	for iter.Scan(&day, &chats) {
+		days = append(days, models.ListingChatDay{Day: day.Format(models.DayLayout), Chats: chats})
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return days, nil
+}
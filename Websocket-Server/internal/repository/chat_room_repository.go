@@ -10,7 +10,7 @@ import (
 type ChatRoomRepository interface {
 	GetChatRoomByUsers(ctx context.Context, firstUserID gocql.UUID, secondUserID gocql.UUID) (*gocql.UUID, error)
 	CreateChatRoom(ctx context.Context, chatRoom models.ChatRoom) error
-	GetChatsByUserID(ctx context.Context, userID gocql.UUID) ([]models.ChatRoom, error)
+	GetChatsByUserID(ctx context.Context, userID gocql.UUID, page models.Page) ([]models.ChatRoom, []byte, error)
 }
 
 type chatRoomRepository struct {
@@ -52,39 +52,47 @@ func (r *chatRoomRepository) CreateChatRoom(ctx context.Context, chatRoom models
 	return nil
 }
 
-func (r *chatRoomRepository) GetChatsByUserID(ctx context.Context, userID gocql.UUID) ([]models.ChatRoom, error) {
-	var chatRooms []models.ChatRoom
-
-	query1 := `SELECT chatid, user1, user2 FROM messenger_keyspace.chatroom WHERE user1 = ?`
-	iter1 := r.session.Query(query1, userID).WithContext(ctx).Iter()
-	var chatID, user1, user2 gocql.UUID
-
-	for iter1.Scan(&chatID, &user1, &user2) {
-		chatRooms = append(chatRooms, models.ChatRoom{
-			ID:            chatID,
-			CurrentUserID: user1,
-			TargetUserID:  user2,
-		})
-	}
-
-	if err := iter1.Close(); err != nil {
-		return nil, err
+// chatRoomUserColumns are the columns a user's chat rooms are looked up by,
+// in the order GetChatsByUserID reads them.
+var chatRoomUserColumns = []string{"user1", "user2"}
+
+// GetChatsByUserID returns a page of the chat rooms userID takes part in. The
+// rooms are read from one user column after the other, so the returned state
+// starts with the index of the column to continue in, followed by the gocql
+// paging state within it.
+func (r *chatRoomRepository) GetChatsByUserID(ctx context.Context, userID gocql.UUID, page models.Page) ([]models.ChatRoom, []byte, error) {
+	column, state := 0, page.State
+	if len(state) > 0 {
+		column, state = int(state[0]), state[1:]
+		if column >= len(chatRoomUserColumns) {
+			return nil, nil, ErrInvalidCursor
+		}
 	}
 
-	query2 := `SELECT chatid, user1, user2 FROM messenger_keyspace.chatroom WHERE user2 = ?`
-	iter2 := r.session.Query(query2, userID).WithContext(ctx).Iter()
-
-	for iter2.Scan(&chatID, &user1, &user2) {
-		chatRooms = append(chatRooms, models.ChatRoom{
-			ID:            chatID,
-			CurrentUserID: user1,
-			TargetUserID:  user2,
-		})
-	}
-
-	if err := iter2.Close(); err != nil {
-		return nil, err
+	chatRooms := []models.ChatRoom{}
+	for ; column < len(chatRoomUserColumns); column++ {
+		query := "SELECT chatid, user1, user2 FROM messenger_keyspace.chatroom WHERE " + chatRoomUserColumns[column] + " = ?"
+		iter := pageIter(r.session.Query(query, userID).WithContext(ctx), models.Page{Limit: page.Limit - len(chatRooms), State: state})
+		next := nextPageState(iter)
+		var chatID, user1, user2 gocql.UUID
+		for iter.Scan(&chatID, &user1, &user2) {
+			chatRooms = append(chatRooms, models.ChatRoom{
+				ID:            chatID,
+				CurrentUserID: user1,
+				TargetUserID:  user2,
+			})
+		}
+		if err := iter.Close(); err != nil {
+			return nil, nil, err
+		}
+
+		if next != nil {
+			return chatRooms, append([]byte{byte(column)}, next...), nil
+		}
+		state = nil
+		if len(chatRooms) >= page.Limit && column+1 < len(chatRoomUserColumns) {
+			return chatRooms, []byte{byte(column + 1)}, nil
+		}
 	}
-
-	return chatRooms, nil
+	return chatRooms, nil, nil
 }
@@ -10,7 +10,7 @@ import (
 
 type MessageRepository interface {
 	SaveMessage(ctx context.Context, message *models.Message) error
-	GetMessagesFromChatRoom(ctx context.Context, chatRoomID gocql.UUID) ([]models.MessageWrap, error)
+	GetMessagesFromChatRoom(ctx context.Context, chatRoomID gocql.UUID, page models.Page) ([]models.MessageWrap, []byte, error)
 	GetLastMessageFromChatRoom(ctx context.Context, chatRoomID gocql.UUID) (string, error)
 }
 
@@ -37,11 +37,14 @@ func (r *messageRepository) SaveMessage(ctx context.Context, message *models.Mes
 	return nil
 }
 
-func (r *messageRepository) GetMessagesFromChatRoom(ctx context.Context, chatRoomID gocql.UUID) ([]models.MessageWrap, error) {
-	var messages []models.MessageWrap
+// GetMessagesFromChatRoom returns a page of the messages of the chat room,
+// newest first.
+func (r *messageRepository) GetMessagesFromChatRoom(ctx context.Context, chatRoomID gocql.UUID, page models.Page) ([]models.MessageWrap, []byte, error) {
+	messages := []models.MessageWrap{}
 	var message models.MessageWrap
 	query := "SELECT id, content, senderid, chatid, timestamp FROM messenger_keyspace.message WHERE chatid = ?"
-	iter := r.session.Query(query, chatRoomID).WithContext(ctx).Iter()
+	iter := pageIter(r.session.Query(query, chatRoomID).WithContext(ctx), page)
+	next := nextPageState(iter)
 
 	for iter.Scan(&message.ID, &message.Content, &message.SenderID, &message.ChatRoomID, &message.Timestamp) {
 		messages = append(messages, models.MessageWrap{
@@ -53,10 +56,10 @@ func (r *messageRepository) GetMessagesFromChatRoom(ctx context.Context, chatRoo
 		})
 	}
 	if err := iter.Close(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return messages, nil
+	return messages, next, nil
 }
 
 func (r *messageRepository) GetLastMessageFromChatRoom(ctx context.Context, chatRoomID gocql.UUID) (string, error) {
@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"errors"
+	"github.com/gocql/gocql"
+	"marketplace_websocket/internal/models"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// pageIter runs query for a single page. Setting the page state, even a nil
+// one, turns off gocql's automatic fetching of further pages, so the iterator
+// stops after page.Limit rows and PageState tells where to continue.
+func pageIter(query *gocql.Query, page models.Page) *gocql.Iter {
+	return query.PageSize(page.Limit).PageState(page.State).Iter()
+}
+
+// nextPageState returns the state of the page after the one iter read, or
+// nil when there is none.
+func nextPageState(iter *gocql.Iter) []byte {
+	state := iter.PageState()
+	if len(state) == 0 {
+		return nil
+	}
+	return state
+}
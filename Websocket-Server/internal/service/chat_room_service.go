@@ -23,6 +23,6 @@ func (s *ChatRoomService) CreateChatRoom(ctx context.Context, chatRoom models.Ch
 	return s.chatRoomRepo.CreateChatRoom(ctx, chatRoom)
 }
 
-func (s *ChatRoomService) GetUserChats(ctx context.Context, userID gocql.UUID) ([]models.ChatRoom, error) {
-	return s.chatRoomRepo.GetChatsByUserID(ctx, userID)
+func (s *ChatRoomService) GetUserChats(ctx context.Context, userID gocql.UUID, page models.Page) ([]models.ChatRoom, []byte, error) {
+	return s.chatRoomRepo.GetChatsByUserID(ctx, userID, page)
 }
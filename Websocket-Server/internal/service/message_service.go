@@ -2,24 +2,49 @@ package service
 
 import (
 	"context"
+	"errors"
 	"github.com/gocql/gocql"
+	"marketplace_websocket/internal/marketplace"
 	"marketplace_websocket/internal/models"
 	"marketplace_websocket/internal/repository"
+	"time"
 )
 
+// ErrBlocked is returned when the sender and the recipient of a message have
+// blocked one another.
+var ErrBlocked = errors.New("recipient is not available")
+
 type MessageService struct {
-	messageRepo  repository.MessageRepository
-	chatRoomRepo repository.ChatRoomRepository
+	messageRepo     repository.MessageRepository
+	chatRoomRepo    repository.ChatRoomRepository
+	chatListingRepo repository.ChatListingRepository
+	users           marketplace.Client
 }
 
-func NewMessageService(messageRepo repository.MessageRepository, chatRoomRepo repository.ChatRoomRepository) *MessageService {
+func NewMessageService(messageRepo repository.MessageRepository, chatRoomRepo repository.ChatRoomRepository, chatListingRepo repository.ChatListingRepository, users marketplace.Client) *MessageService {
 	return &MessageService{
-		messageRepo:  messageRepo,
-		chatRoomRepo: chatRoomRepo,
+		messageRepo:     messageRepo,
+		chatRoomRepo:    chatRoomRepo,
+		chatListingRepo: chatListingRepo,
+		users:           users,
 	}
 }
 
+// SaveMessage stores the message, opening a chat room for the two users when
+// they have none yet. It returns ErrBlocked when either user has blocked the
+// other.
 func (s *MessageService) SaveMessage(ctx context.Context, message *models.Message) (*models.ChatRoom, error) {
+	blocked, err := s.users.BlockedUsers(ctx, message.SenderID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked[message.RecipientID] {
+		return nil, ErrBlocked
+	}
+	if err := s.checkListing(ctx, message); err != nil {
+		return nil, err
+	}
+
 	chatRoomID := message.ChatRoomID
 	if message.ChatRoomID == (gocql.UUID{}) {
 		chatID, err := s.chatRoomRepo.GetChatRoomByUsers(ctx, message.SenderID, message.RecipientID)
@@ -38,16 +63,72 @@ func (s *MessageService) SaveMessage(ctx context.Context, message *models.Messag
 				return nil, err
 			}
 			message.ChatRoomID = chatRoomID
-			return &chatRoom, s.messageRepo.SaveMessage(ctx, message)
+			return &chatRoom, s.saveMessage(ctx, message)
 		} else {
 			message.ChatRoomID = *chatID
 		}
 	}
-	return nil, s.messageRepo.SaveMessage(ctx, message)
+	return nil, s.saveMessage(ctx, message)
+}
+
+// saveMessage stores the message and, when it is about a listing, records
+// that the sender has contacted the recipient about it.
+func (s *MessageService) saveMessage(ctx context.Context, message *models.Message) error {
+	if err := s.messageRepo.SaveMessage(ctx, message); err != nil {
+		return err
+	}
+	if message.ProductID == (gocql.UUID{}) {
+		return nil
+	}
+	first, err := s.chatListingRepo.RecordListingChat(ctx, message.ProductID, message.SenderID, message.RecipientID, message.ChatRoomID)
+	if err != nil || !first {
+		return err
+	}
+	return s.chatListingRepo.IncrementListingChats(ctx, message.ProductID, statsDay(time.Now()))
+}
+
+// checkListing keeps the listing on a message only when the recipient
+// published it, as the API server tells, so that a client cannot claim a
+// contact about someone else's listing. Without the listing the message is
+// an ordinary one.
+func (s *MessageService) checkListing(ctx context.Context, message *models.Message) error {
+	if message.ProductID == (gocql.UUID{}) {
+		return nil
+	}
+	ownerID, err := s.users.ProductOwner(ctx, message.ProductID)
+	if err != nil && !errors.Is(err, marketplace.ErrUnknownProduct) {
+		return err
+	}
+	if err != nil || ownerID != message.RecipientID {
+		message.ProductID = gocql.UUID{}
+	}
+	return nil
+}
+
+// statsDay returns the UTC day t falls on.
+func statsDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// HasChattedAboutListing reports whether buyerID has written to sellerID
+// about the listing.
+func (s *MessageService) HasChattedAboutListing(ctx context.Context, productID, buyerID, sellerID gocql.UUID) (bool, error) {
+	return s.chatListingRepo.HasListingChat(ctx, productID, buyerID, sellerID)
+}
+
+// BlockedUsers returns the accounts userID has blocked or was blocked by.
+func (s *MessageService) BlockedUsers(ctx context.Context, userID gocql.UUID) (map[gocql.UUID]bool, error) {
+	return s.users.BlockedUsers(ctx, userID)
+}
+
+// ListingChatsByDay returns how many conversations the listing started on
+// each day from from to to, leaving out days without any.
+func (s *MessageService) ListingChatsByDay(ctx context.Context, productID gocql.UUID, from, to time.Time) ([]models.ListingChatDay, error) {
+	return s.chatListingRepo.ListingChatsByDay(ctx, productID, statsDay(from), statsDay(to))
 }
 
-func (s *MessageService) GetMessagesFromChatRoom(ctx context.Context, chatRoomID gocql.UUID) ([]models.MessageWrap, error) {
-	return s.messageRepo.GetMessagesFromChatRoom(ctx, chatRoomID)
+func (s *MessageService) GetMessagesFromChatRoom(ctx context.Context, chatRoomID gocql.UUID, page models.Page) ([]models.MessageWrap, []byte, error) {
+	return s.messageRepo.GetMessagesFromChatRoom(ctx, chatRoomID, page)
 }
 
 func (s *MessageService) GetLastMessage(ctx context.Context, chatRoomID gocql.UUID) (string, error) {
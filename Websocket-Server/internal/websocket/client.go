@@ -3,6 +3,7 @@ package websocket
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"github.com/gocql/gocql"
 	"github.com/gorilla/websocket"
 	"log"
@@ -48,8 +49,22 @@ func (c *Client) ReadMessages(messageService *service.MessageService) {
 		}
 
 		message.ID = gocql.TimeUUID()
+		message.SenderID = c.id
 
 		chatRoom, err := messageService.SaveMessage(context.Background(), &message)
+		if errors.Is(err, service.ErrBlocked) {
+			notification := Notification{
+				Type:    "error",
+				UserID:  message.RecipientID,
+				Message: err.Error(),
+			}
+			select {
+			case c.send <- notification:
+			default:
+				log.Println("Error sending message to client: client send channel full or closed")
+			}
+			continue
+		}
 		if err != nil {
 			log.Println("Error saving message:", err)
 			continue
@@ -2,6 +2,7 @@ package websocket
 
 import (
 	"github.com/gocql/gocql"
+	"marketplace_websocket/internal/marketplace"
 	"marketplace_websocket/internal/models"
 	"sync"
 )
@@ -11,6 +12,7 @@ type Hub struct {
 	broadcast  chan models.Message
 	register   chan *Client
 	unregister chan *Client
+	users      marketplace.Client
 	mu         sync.Mutex
 }
 
@@ -20,12 +22,13 @@ type Notification struct {
 	UserID  gocql.UUID `json:"userID"`
 }
 
-func NewHub() *Hub {
+func NewHub(users marketplace.Client) *Hub {
 	return &Hub{
 		clients:    make(map[gocql.UUID]*Client),
 		broadcast:  make(chan models.Message, 1000),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		users:      users,
 		//messageHandler: messageHandler,
 	}
 }
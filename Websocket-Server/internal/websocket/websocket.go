@@ -1,9 +1,11 @@
 package websocket
 
 import (
+	"context"
 	"github.com/gocql/gocql"
 	"github.com/gorilla/websocket"
 	"log"
+	"marketplace_websocket/internal/auth"
 	"marketplace_websocket/internal/models"
 	"marketplace_websocket/internal/service"
 	"net/http"
@@ -15,24 +17,18 @@ var upgrader = websocket.Upgrader{
 	WriteBufferSize: 1024,
 }
 
-func HandleConnection(hub *Hub, w http.ResponseWriter, r *http.Request, messageService *service.MessageService) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+// HandleConnection upgrades a request that carries a valid access token and
+// connects it as the user the token was issued to.
+func HandleConnection(hub *Hub, verifier *auth.Verifier, w http.ResponseWriter, r *http.Request, messageService *service.MessageService) {
+	clientID, err := verifier.UserID(r.Context(), auth.FromRequest(r))
 	if err != nil {
-		log.Println("Error while upgrading connection:", err)
-		return
-	}
-
-	clientIDStr := r.URL.Query().Get("id")
-	if clientIDStr == "" {
-		log.Println("Client ID is missing")
-		conn.Close()
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	clientID, err := gocql.ParseUUID(clientIDStr)
+	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Println("Invalid Client ID:", err)
-		conn.Close()
+		log.Println("Error while upgrading connection:", err)
 		return
 	}
 
@@ -73,6 +69,11 @@ func (h *Hub) NotifyChatRoomCreation(chatRoom *models.ChatRoom) {
 }
 
 func (h *Hub) NotifyUserOnline(userID gocql.UUID) {
+	blocked, ok := h.blockedUsers(userID)
+	if !ok {
+		return
+	}
+
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
@@ -83,6 +84,9 @@ func (h *Hub) NotifyUserOnline(userID gocql.UUID) {
 	}
 
 	for _, client := range h.clients {
+		if blocked[client.id] {
+			continue
+		}
 		select {
 		case client.send <- notification:
 		default:
@@ -94,6 +98,11 @@ func (h *Hub) NotifyUserOnline(userID gocql.UUID) {
 }
 
 func (h *Hub) NotifyUserOffline(userID gocql.UUID) {
+	blocked, ok := h.blockedUsers(userID)
+	if !ok {
+		return
+	}
+
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
@@ -104,6 +113,9 @@ func (h *Hub) NotifyUserOffline(userID gocql.UUID) {
 	}
 
 	for _, client := range h.clients {
+		if blocked[client.id] {
+			continue
+		}
 		select {
 		case client.send <- notification:
 		default:
@@ -113,3 +125,15 @@ func (h *Hub) NotifyUserOffline(userID gocql.UUID) {
 		}
 	}
 }
+
+// blockedUsers looks up who userID must stay hidden from. Presence is not
+// announced at all when the lookup fails, rather than risk showing it to a
+// user it was hidden from.
+func (h *Hub) blockedUsers(userID gocql.UUID) (map[gocql.UUID]bool, bool) {
+	blocked, err := h.users.BlockedUsers(context.Background(), userID)
+	if err != nil {
+		log.Printf("Failed to look up blocked users of %s: %v", userID, err)
+		return nil, false
+	}
+	return blocked, true
+}
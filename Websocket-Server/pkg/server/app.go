@@ -3,20 +3,37 @@ package server
 import (
 	"github.com/gin-gonic/gin"
 	"log"
+	"marketplace_websocket/internal/auth"
 	"marketplace_websocket/internal/db"
 	"marketplace_websocket/internal/handlers"
+	"marketplace_websocket/internal/marketplace"
 	"marketplace_websocket/internal/repository"
 	"marketplace_websocket/internal/service"
 	"marketplace_websocket/internal/websocket"
+	"os"
+	"time"
 )
 
+// apiServerURL is where the API server that owns user accounts listens.
+const apiServerURL = "http://localhost:3001"
+
+// blockListTTL is how long block lists are cached. The API server also
+// reports changes, so it only matters when such a report is lost.
+const blockListTTL = time.Minute
+
 type App struct {
 	hub    *websocket.Hub
 	router *gin.Engine
 }
 
 func (a *App) Initialize() {
-	a.hub = websocket.NewHub()
+	internalSecret := os.Getenv("INTERNAL_API_SECRET")
+	if internalSecret == "" {
+		log.Fatal("INTERNAL_API_SECRET is not set; it must match the API server's to look up block lists and listings")
+	}
+	users := marketplace.NewCache(marketplace.NewClient(apiServerURL, internalSecret), blockListTTL)
+
+	a.hub = websocket.NewHub(users)
 	go a.hub.Run()
 
 	a.router = gin.Default()
@@ -25,13 +42,15 @@ func (a *App) Initialize() {
 	session := db.Connection()
 	messageRepo := repository.NewMessageRepository(session)
 	chatRoomRepo := repository.NewChatRoomRepository(session)
-	messageService := service.NewMessageService(messageRepo, chatRoomRepo)
+	chatListingRepo := repository.NewChatListingRepository(session)
+	messageService := service.NewMessageService(messageRepo, chatRoomRepo, chatListingRepo, users)
 	chatRoomService := service.NewChatRoomService(chatRoomRepo)
 	chatRoomHandler := handlers.NewChatRoomHandler(chatRoomService, messageService, a.hub)
 	messageHandler := handlers.NewMessageHandler(messageService, chatRoomService)
 
-	a.setupRouterSocket(messageService)
-	a.setupRouterChat(messageHandler, chatRoomHandler)
+	a.setupRouterSocket(messageService, auth.NewVerifier(apiServerURL+"/.well-known/jwks.json"))
+	a.setupRouterChat(messageHandler, chatRoomHandler, internalSecret)
+	a.setupRouterBlocks(handlers.NewBlockHandler(users), internalSecret)
 }
 
 func (a *App) Run(addr string) {
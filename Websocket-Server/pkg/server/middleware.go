@@ -1,6 +1,10 @@
 package server
 
-import "github.com/gin-gonic/gin"
+import (
+	"crypto/subtle"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
 
 func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -17,3 +21,19 @@ func CORSMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// InternalMiddleware admits only the API server, which sends the shared
+// secret in X-Internal-Secret. Every request is refused while no secret is
+// configured.
+func InternalMiddleware(secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		presented := c.GetHeader("X-Internal-Secret")
+		if secret == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(secret)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "internal endpoint"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
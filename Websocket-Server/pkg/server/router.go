@@ -2,21 +2,28 @@ package server
 
 import (
 	"github.com/gin-gonic/gin"
+	"marketplace_websocket/internal/auth"
 	"marketplace_websocket/internal/handlers"
 	"marketplace_websocket/internal/service"
 	"marketplace_websocket/internal/websocket"
 )
 
-func (a *App) setupRouterSocket(messageService *service.MessageService) {
+func (a *App) setupRouterSocket(messageService *service.MessageService, verifier *auth.Verifier) {
 
 	a.router.GET("/ws", func(c *gin.Context) {
-		websocket.HandleConnection(a.hub, c.Writer, c.Request, messageService)
+		websocket.HandleConnection(a.hub, verifier, c.Writer, c.Request, messageService)
 	})
 
 }
 
-func (a *App) setupRouterChat(messageHandler *handlers.MessageHandler, chatRoomHandler *handlers.ChatRoomHandler) {
+func (a *App) setupRouterChat(messageHandler *handlers.MessageHandler, chatRoomHandler *handlers.ChatRoomHandler, internalSecret string) {
 	a.router.GET("/getChatMessages", chatRoomHandler.GetMessagesFromChatID)
 	a.router.GET("/getChatRoomID", chatRoomHandler.GetChatIDByUsers)
 	a.router.GET("/getUserChats", chatRoomHandler.GetUserChats)
+	a.router.GET("/listingChat", InternalMiddleware(internalSecret), messageHandler.HasListingChat)
+	a.router.GET("/listingChats", InternalMiddleware(internalSecret), messageHandler.ListingChats)
+}
+
+func (a *App) setupRouterBlocks(blockHandler *handlers.BlockHandler, internalSecret string) {
+	a.router.POST("/blocksChanged", InternalMiddleware(internalSecret), blockHandler.BlocksChanged)
 }